@@ -6,23 +6,32 @@ import (
 	"os"
 	"path/filepath"
 
+	"github.com/Adirelle/mcvisor/pkg/backup"
 	"github.com/Adirelle/mcvisor/pkg/discord"
+	"github.com/Adirelle/mcvisor/pkg/jobs"
 	"github.com/Adirelle/mcvisor/pkg/logging"
 	"github.com/Adirelle/mcvisor/pkg/minecraft"
+	"github.com/Adirelle/mcvisor/pkg/rconproxy"
+	"github.com/Adirelle/mcvisor/pkg/scheduler"
 	"github.com/apex/log"
 	"github.com/go-playground/validator/v10"
 )
 
 const (
 	DefaultConfigFilename = "mcvisor.json"
+	LockFilename          = "mcvisor.lock"
 )
 
 type (
 	Config struct {
-		Path      string            `json:"-"`
-		Minecraft *minecraft.Config `json:"minecraft" validate:"required"`
-		Discord   *discord.Config   `json:"discord" validate:"required"`
-		Logging   *logging.Config   `json:"logging"`
+		Path      string             `json:"-"`
+		Minecraft *minecraft.Configs `json:"minecraft" validate:"required"`
+		Discord   *discord.Config    `json:"discord" validate:"required"`
+		Logging   *logging.Config    `json:"logging"`
+		Scheduler *scheduler.Config  `json:"scheduler"`
+		Jobs      jobs.Config        `json:"jobs" validate:"dive"`
+		Backup    *backup.Config     `json:"backup" validate:"required"`
+		RconProxy *rconproxy.Config  `json:"rcon_proxy" validate:"required"`
 	}
 )
 
@@ -56,9 +65,13 @@ func NewConfig(path string) (c *Config) {
 	baseDir := filepath.Dir(path)
 	return &Config{
 		Path:      path,
-		Minecraft: minecraft.NewConfig(baseDir),
+		Minecraft: minecraft.NewConfigs(baseDir),
 		Discord:   discord.NewConfig(),
 		Logging:   logging.NewConfig(baseDir),
+		Scheduler: scheduler.NewConfig(baseDir),
+		Jobs:      jobs.NewConfig(),
+		Backup:    backup.NewConfig(baseDir),
+		RconProxy: rconproxy.NewConfig(),
 	}
 }
 
@@ -75,6 +88,11 @@ func LoadConfig(path string) (c *Config, err error) {
 		return
 	}
 
+	err = c.Minecraft.Validate()
+	if err != nil {
+		return
+	}
+
 	if writeError := c.Write(); writeError != nil {
 		log.WithField("path", path).WithError(writeError).Error("log.file.write")
 	}
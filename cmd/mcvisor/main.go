@@ -5,10 +5,16 @@ import (
 	stdlog "log"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"sync"
 
+	"github.com/Adirelle/mcvisor/pkg/commands"
 	"github.com/Adirelle/mcvisor/pkg/discord"
 	"github.com/Adirelle/mcvisor/pkg/events"
 	"github.com/Adirelle/mcvisor/pkg/minecraft"
+	"github.com/Adirelle/mcvisor/pkg/rconproxy"
+	"github.com/Adirelle/mcvisor/pkg/scheduler"
+	"github.com/Adirelle/mcvisor/pkg/utils"
 	"github.com/apex/log"
 	"github.com/thejerf/suture/v4"
 )
@@ -22,20 +28,23 @@ var SutureEventLabels = map[suture.EventType]string{
 }
 
 func main() {
-	conf, err := LoadConfig(FindConfigFile(ConfigSearchPath()))
+	configPath := FindConfigFile(ConfigSearchPath())
+	// Only one instance can manage the servers of a configuration.
+	lock, err := utils.AcquireLock(filepath.Join(filepath.Dir(configPath), LockFilename))
+	if err != nil {
+		stdlog.Fatalf("could not start: %s", err)
+	}
+
+	conf, err := LoadConfig(configPath)
 	if err != nil {
 		stdlog.Fatalf("could not load configuration: %s", err)
 	}
 
-	supervisor := suture.New("main", suture.Spec{
-		EventHook: suture.EventHook(func(event suture.Event) {
-			log.
-				WithField("message", event.String()).
-				WithFields(log.Fields(event.Map())).
-				Warnf("suture.%s", SutureEventLabels[event.Type()])
-		}),
-	})
-	spvDone := supervisor.ServeBackground(context.Background())
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	supervisor := suture.New("main", supervisorSpec())
+	spvDone := supervisor.ServeBackground(ctx)
 
 	handler, level, service := conf.Logging.CreateLogging()
 	log.SetHandler(handler)
@@ -44,6 +53,10 @@ func main() {
 		supervisor.Add(service)
 	}
 
+	if lock.Stale != nil {
+		log.WithField("path", lock.Path).WithField("holder", lock.Stale).Warn("lock.stale")
+	}
+
 	dispatcher := events.NewDispatcher()
 
 	bot := discord.NewBot(*conf.Discord, dispatcher)
@@ -51,11 +64,32 @@ func main() {
 		supervisor.Add(bot)
 	}
 
-	server := minecraft.NewServer(conf.Minecraft, dispatcher)
-	supervisor.Add(server)
+	scopes := commands.NewScopes(commands.Default)
+	board := discord.NewStatusBoard(conf.Minecraft.Names())
+	servers := make(map[string]*minecraft.Server, len(conf.Minecraft.Servers))
+	consoles := make(map[string]minecraft.Console, len(conf.Minecraft.Servers))
+	var trees sync.WaitGroup
+	for _, name := range conf.Minecraft.Names() {
+		server, err := addServer(supervisor, name, conf, dispatcher, scopes.Add(name), board, &trees)
+		if err != nil {
+			stdlog.Fatalf("invalid configuration of server %s: %s", name, err)
+		}
+		servers[name] = server
+		consoles[name] = server
+	}
+	if err := scopes.Mount(); err != nil {
+		stdlog.Fatalf("invalid configuration: %s", err)
+	}
+
+	supervisor.Add(scheduler.NewScheduler(conf.Scheduler, dispatcher))
 
-	pinger := minecraft.NewPinger(conf.Minecraft.Server, server, dispatcher)
-	supervisor.Add(pinger)
+	if conf.RconProxy.Enabled {
+		proxy, err := rconproxy.NewProxy(conf.RconProxy, consoles)
+		if err != nil {
+			stdlog.Fatalf("invalid RCON proxy configuration: %s", err)
+		}
+		supervisor.Add(proxy)
+	}
 
 	signals := make(chan os.Signal, 1)
 	signal.Notify(signals, os.Kill, os.Interrupt)
@@ -63,20 +97,44 @@ func main() {
 	go func() {
 		if sig, open := <-signals; open {
 			log.WithField("signal", sig).Warn("signal.received")
-			server.Shutdown()
+			for _, server := range servers {
+				server.Detach()
+			}
 		}
 	}()
 
+	// mcvisor exits once all the servers have been shut down.
+	go func() {
+		trees.Wait()
+		cancel()
+	}()
+
 	if bot.IsEnabled() {
 		<-bot.Ready()
 	}
 
-	server.Start()
+	for _, server := range servers {
+		server.Start()
+	}
 
 	err = <-spvDone
 	close(signals)
-	if err != nil && err != suture.ErrTerminateSupervisorTree {
+	if releaseErr := lock.Release(); releaseErr != nil {
+		log.WithError(releaseErr).WithField("path", lock.Path).Error("lock.release")
+	}
+	if err != nil && err != suture.ErrTerminateSupervisorTree && err != context.Canceled {
 		stdlog.Fatalf("error: %s", err)
 	}
 	os.Exit(0)
 }
+
+func supervisorSpec() suture.Spec {
+	return suture.Spec{
+		EventHook: suture.EventHook(func(event suture.Event) {
+			log.
+				WithField("message", event.String()).
+				WithFields(log.Fields(event.Map())).
+				Warnf("suture.%s", SutureEventLabels[event.Type()])
+		}),
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/Adirelle/mcvisor/pkg/backup"
+	"github.com/Adirelle/mcvisor/pkg/commands"
+	"github.com/Adirelle/mcvisor/pkg/discord"
+	"github.com/Adirelle/mcvisor/pkg/events"
+	"github.com/Adirelle/mcvisor/pkg/jobs"
+	"github.com/Adirelle/mcvisor/pkg/minecraft"
+	"github.com/thejerf/suture/v4"
+)
+
+type (
+	// serverTree supervises the services of one Minecraft server.
+	// It stops, without taking down the other servers, once its server has been shut down.
+	serverTree struct {
+		*suture.Supervisor
+		shutdown func()
+	}
+)
+
+// addServer creates the services of the named server, with their own dispatcher and commands, under a child supervisor.
+func addServer(
+	parent *suture.Supervisor,
+	name string,
+	conf *Config,
+	bus *events.Dispatcher,
+	registry *commands.Registry,
+	board *discord.StatusBoard,
+	trees *sync.WaitGroup,
+) (*minecraft.Server, error) {
+	mcConf := conf.Minecraft.Servers[name]
+	dispatcher := events.NewDispatcher()
+
+	spec := supervisorSpec()
+	spec.DontPropagateTermination = true
+	tree := suture.New("minecraft."+name, spec)
+
+	server := minecraft.NewServer(mcConf, dispatcher, registry)
+	tree.Add(server)
+	tree.Add(minecraft.NewPinger(mcConf.Server, server, dispatcher, registry))
+	tree.Add(discord.NewRelay(name, dispatcher, bus, board))
+	tree.Add(minecraft.NewLagTracker(mcConf.Server.Lag, server, server, dispatcher, registry))
+	if mcConf.Server.Monitor.IsEnabled() {
+		tree.Add(minecraft.NewMonitor(mcConf, server, dispatcher, registry))
+	}
+
+	backupConf := conf.Backup
+	if board.IsMultiple() {
+		backupConf = backupConf.ForServer(name)
+	}
+	backupService := backup.NewService(backupConf, mcConf.Server, server, dispatcher, registry)
+	tree.Add(backupService)
+	for _, uploader := range backupService.Uploaders() {
+		tree.Add(uploader)
+	}
+
+	if err := jobs.NewRunner(dispatcher, server, server, mcConf.WorkingDir(), registry).Register(conf.Jobs); err != nil {
+		return nil, err
+	}
+
+	trees.Add(1)
+	parent.Add(&serverTree{tree, trees.Done})
+	return server, nil
+}
+
+func (t *serverTree) Serve(ctx context.Context) error {
+	err := t.Supervisor.Serve(ctx)
+	if errors.Is(err, suture.ErrDoNotRestart) {
+		t.shutdown()
+	}
+	return err
+}
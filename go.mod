@@ -7,8 +7,12 @@ require (
 	github.com/bwmarrin/discordgo v0.24.0
 	github.com/dmotylev/goproperties v0.0.0-20140630191356-7cbffbaada47
 	github.com/go-playground/validator/v10 v10.10.1
+	github.com/klauspost/compress v1.15.15
 	github.com/millkhan/mcstatusgo/v2 v2.2.0
+	github.com/pkg/sftp v1.13.5
+	github.com/robfig/cron/v3 v3.0.1
 	github.com/thejerf/suture/v4 v4.0.2
+	golang.org/x/crypto v0.0.0-20211215153901-e495a2d5b3d3
 	golang.org/x/exp v0.0.0-20220414153411-bcd21879b8fd
 	gopkg.in/natefinch/lumberjack.v2 v2.0.0
 )
@@ -19,11 +23,11 @@ require (
 	github.com/go-playground/locales v0.14.0 // indirect
 	github.com/go-playground/universal-translator v0.18.0 // indirect
 	github.com/gorilla/websocket v1.4.2 // indirect
+	github.com/kr/fs v0.1.0 // indirect
 	github.com/leodido/go-urn v1.2.1 // indirect
 	github.com/mattn/go-colorable v0.1.2 // indirect
 	github.com/mattn/go-isatty v0.0.8 // indirect
 	github.com/pkg/errors v0.8.1 // indirect
-	golang.org/x/crypto v0.0.0-20211215153901-e495a2d5b3d3 // indirect
-	golang.org/x/sys v0.0.0-20211019181941-9d821ace8654 // indirect
+	golang.org/x/sys v0.0.0-20211216021012-1d35b9e2eb4e // indirect
 	golang.org/x/text v0.3.7 // indirect
 )
@@ -0,0 +1,433 @@
+package backup
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+type (
+	Archive struct {
+		ID     string
+		Path   string
+		Format Format
+		Time   time.Time
+		Size   int64
+
+		// seq orders the archives created in the same second.
+		seq int
+	}
+
+	archiveWriter interface {
+		Add(name string, info fs.FileInfo, content io.Reader) error
+		Close() error
+	}
+
+	tarZstWriter struct {
+		zstd *zstd.Encoder
+		tar  *tar.Writer
+	}
+
+	zipWriter struct {
+		*zip.Writer
+	}
+
+	// archiveVisitor is called for each entry of an archive; content is nil for directories.
+	archiveVisitor func(name string, mode fs.FileMode, content io.Reader) error
+)
+
+const (
+	idLayout       = "20060102-150405"
+	checksumSuffix = ".sha256"
+)
+
+var (
+	archiveNameRegexp = regexp.MustCompile(`^backup-((\d{8}-\d{6})(?:-(\d+))?)\.(tar\.zst|zip)$`)
+
+	// Files that are not worth saving, or that cannot be read while the server is running.
+	skippedFiles = map[string]bool{
+		"session.lock": true,
+	}
+
+	ErrUnsafePath = errors.New("archive entry escapes the target directory")
+)
+
+func archiveName(id string, format Format) string {
+	return fmt.Sprintf("backup-%s.%s", id, format)
+}
+
+// uniqueArchiveID returns the ID of an archive created at the given time. A sequence number is appended if an archive
+// with the same ID already exists, in any format.
+func uniqueArchiveID(dir string, when time.Time) string {
+	base := when.Format(idLayout)
+	id := base
+	for seq := 2; archiveExists(dir, id); seq++ {
+		id = fmt.Sprintf("%s-%d", base, seq)
+	}
+	return id
+}
+
+func archiveExists(dir, id string) bool {
+	for _, format := range []Format{TarZstFormat, ZipFormat} {
+		if _, err := os.Lstat(filepath.Join(dir, archiveName(id, format))); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// ListArchives returns the archives found in dir, newest first.
+func ListArchives(dir string) ([]*Archive, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	archives := make([]*Archive, 0, len(entries))
+	for _, entry := range entries {
+		archive := parseArchiveName(entry.Name())
+		if archive == nil || entry.IsDir() {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+		archive.Path = filepath.Join(dir, entry.Name())
+		archive.Size = info.Size()
+		archives = append(archives, archive)
+	}
+
+	sortArchives(archives)
+	return archives, nil
+}
+
+// parseArchiveNames returns the archives matching the given file names, newest first.
+// Their path is their name.
+func parseArchiveNames(names []string) []*Archive {
+	archives := make([]*Archive, 0, len(names))
+	for _, name := range names {
+		if archive := parseArchiveName(name); archive != nil {
+			archives = append(archives, archive)
+		}
+	}
+	sortArchives(archives)
+	return archives
+}
+
+// parseArchiveName returns the archive described by the file name, or nil if it is not an archive.
+func parseArchiveName(name string) *Archive {
+	matches := archiveNameRegexp.FindStringSubmatch(name)
+	if matches == nil {
+		return nil
+	}
+	when, err := time.ParseInLocation(idLayout, matches[2], time.Local)
+	if err != nil {
+		return nil
+	}
+	seq, _ := strconv.Atoi(matches[3])
+	return &Archive{ID: matches[1], Path: name, Format: Format(matches[4]), Time: when, seq: seq}
+}
+
+func sortArchives(archives []*Archive) {
+	sort.Slice(archives, func(i, j int) bool {
+		if archives[i].Time.Equal(archives[j].Time) {
+			return archives[i].seq > archives[j].seq
+		}
+		return archives[i].Time.After(archives[j].Time)
+	})
+}
+
+// FindArchive returns the archive with the given ID.
+func FindArchive(dir, id string) (*Archive, error) {
+	archives, err := ListArchives(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, archive := range archives {
+		if archive.ID == id {
+			return archive, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown backup: %q", id)
+}
+
+// ChecksumPath returns the path of the file holding the SHA-256 checksum of the archive.
+func (a *Archive) ChecksumPath() string {
+	return a.Path + checksumSuffix
+}
+
+// RecordedChecksum returns the checksum written when the archive was created, if any.
+func (a *Archive) RecordedChecksum() (string, error) {
+	content, err := os.ReadFile(a.ChecksumPath())
+	if err != nil {
+		return "", err
+	}
+	fields := strings.Fields(string(content))
+	if len(fields) == 0 {
+		return "", fmt.Errorf("empty checksum file: %s", a.ChecksumPath())
+	}
+	return fields[0], nil
+}
+
+// Checksum computes the SHA-256 checksum of the archive.
+func (a *Archive) Checksum() (string, error) {
+	file, err := os.Open(a.Path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	hash := sha256.New()
+	if _, err = io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// Walk calls visit for each entry of the archive.
+func (a *Archive) Walk(visit archiveVisitor) error {
+	if a.Format == ZipFormat {
+		return walkZip(a.Path, visit)
+	}
+	return walkTarZst(a.Path, visit)
+}
+
+// Remove deletes the archive and its checksum file.
+func (a *Archive) Remove() error {
+	if err := os.Remove(a.Path); err != nil {
+		return err
+	}
+	if err := os.Remove(a.ChecksumPath()); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// createArchive writes the given paths, relative to baseDir, into a new archive, along with its checksum file.
+func createArchive(path string, format Format, baseDir string, paths []string) (err error) {
+	tmpPath := path + ".tmp"
+	file, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+	hash := sha256.New()
+	defer func() {
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err == nil {
+			err = os.Rename(tmpPath, path)
+		}
+		if err == nil {
+			checksum := fmt.Sprintf("%s  %s\n", hex.EncodeToString(hash.Sum(nil)), filepath.Base(path))
+			err = os.WriteFile(path+checksumSuffix, []byte(checksum), 0o644)
+		}
+		if err != nil {
+			_ = os.Remove(tmpPath)
+		}
+	}()
+
+	output := io.MultiWriter(file, hash)
+	var writer archiveWriter
+	if format == ZipFormat {
+		writer = &zipWriter{zip.NewWriter(output)}
+	} else if writer, err = newTarZstWriter(output); err != nil {
+		return err
+	}
+
+	for _, root := range paths {
+		err = filepath.WalkDir(filepath.Join(baseDir, root), func(path string, entry fs.DirEntry, err error) error {
+			if err != nil || skippedFiles[entry.Name()] {
+				return err
+			}
+			info, err := entry.Info()
+			if err != nil {
+				return err
+			}
+			name, err := filepath.Rel(baseDir, path)
+			if err != nil {
+				return err
+			}
+			return addFile(writer, filepath.ToSlash(name), path, info)
+		})
+		if err != nil {
+			_ = writer.Close()
+			return err
+		}
+	}
+
+	return writer.Close()
+}
+
+func addFile(writer archiveWriter, name, path string, info fs.FileInfo) error {
+	if !info.Mode().IsRegular() {
+		return writer.Add(name, info, nil)
+	}
+	content, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer content.Close()
+	return writer.Add(name, info, content)
+}
+
+func newTarZstWriter(w io.Writer) (*tarZstWriter, error) {
+	encoder, err := zstd.NewWriter(w)
+	if err != nil {
+		return nil, err
+	}
+	return &tarZstWriter{encoder, tar.NewWriter(encoder)}, nil
+}
+
+func (w *tarZstWriter) Add(name string, info fs.FileInfo, content io.Reader) error {
+	if !info.IsDir() && !info.Mode().IsRegular() {
+		return nil
+	}
+	header, err := tar.FileInfoHeader(info, "")
+	if err != nil {
+		return err
+	}
+	header.Name = name
+	if info.IsDir() {
+		header.Name += "/"
+	}
+	if err = w.tar.WriteHeader(header); err != nil || content == nil {
+		return err
+	}
+	_, err = io.Copy(w.tar, content)
+	return err
+}
+
+func (w *tarZstWriter) Close() error {
+	err := w.tar.Close()
+	if zstdErr := w.zstd.Close(); err == nil {
+		err = zstdErr
+	}
+	return err
+}
+
+func (w *zipWriter) Add(name string, info fs.FileInfo, content io.Reader) error {
+	if !info.IsDir() && !info.Mode().IsRegular() {
+		return nil
+	}
+	header, err := zip.FileInfoHeader(info)
+	if err != nil {
+		return err
+	}
+	header.Name = name
+	if info.IsDir() {
+		header.Name += "/"
+	} else {
+		header.Method = zip.Deflate
+	}
+	entry, err := w.CreateHeader(header)
+	if err != nil || content == nil {
+		return err
+	}
+	_, err = io.Copy(entry, content)
+	return err
+}
+
+func walkTarZst(path string, visit archiveVisitor) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	decoder, err := zstd.NewReader(file)
+	if err != nil {
+		return err
+	}
+	defer decoder.Close()
+
+	reader := tar.NewReader(decoder)
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		info := header.FileInfo()
+		var content io.Reader
+		if info.Mode().IsRegular() {
+			content = reader
+		}
+		if err = visit(strings.TrimSuffix(header.Name, "/"), info.Mode(), content); err != nil {
+			return err
+		}
+	}
+}
+
+func walkZip(path string, visit archiveVisitor) error {
+	reader, err := zip.OpenReader(path)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	for _, entry := range reader.File {
+		if err = visitZipEntry(entry, visit); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func visitZipEntry(entry *zip.File, visit archiveVisitor) error {
+	name := strings.TrimSuffix(entry.Name, "/")
+	if entry.FileInfo().IsDir() {
+		return visit(name, entry.Mode(), nil)
+	}
+	content, err := entry.Open()
+	if err != nil {
+		return err
+	}
+	defer content.Close()
+	return visit(name, entry.Mode(), content)
+}
+
+// extractEntry writes an archive entry below baseDir.
+func extractEntry(baseDir, name string, mode fs.FileMode, content io.Reader) error {
+	target := filepath.Join(baseDir, filepath.FromSlash(name))
+	if rel, err := filepath.Rel(baseDir, target); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("%w: %s", ErrUnsafePath, name)
+	}
+
+	if content == nil {
+		if mode.IsDir() {
+			return os.MkdirAll(target, 0o755)
+		}
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode.Perm()|0o200)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(file, content); err != nil {
+		_ = file.Close()
+		return err
+	}
+	return file.Close()
+}
@@ -0,0 +1,75 @@
+package backup
+
+import (
+	"path"
+	"path/filepath"
+	"time"
+)
+
+type (
+	Config struct {
+		BaseDir     string           `json:"-"`
+		Dir         string           `json:"dir,omitempty"`
+		Format      Format           `json:"format" validate:"oneof=tar.zst zip"`
+		Extra       []string         `json:"extra,omitempty"`
+		SaveTimeout time.Duration    `json:"save_timeout"`
+		Retention   *RetentionConfig `json:"retention" validate:"required"`
+		Storage     []*StorageConfig `json:"storage,omitempty" validate:"dive"`
+		Upload      *UploadConfig    `json:"upload" validate:"required"`
+	}
+
+	Format string
+
+	RetentionConfig struct {
+		KeepLast   int `json:"keep_last" validate:"min=0"`
+		KeepDaily  int `json:"keep_daily" validate:"min=0"`
+		KeepWeekly int `json:"keep_weekly" validate:"min=0"`
+	}
+)
+
+const (
+	TarZstFormat Format = "tar.zst"
+	ZipFormat    Format = "zip"
+
+	DefaultDir = "backups"
+)
+
+func NewConfig(baseDir string) *Config {
+	return &Config{
+		BaseDir:     filepath.Clean(baseDir),
+		Dir:         DefaultDir,
+		Format:      TarZstFormat,
+		SaveTimeout: 2 * time.Minute,
+		Retention: &RetentionConfig{
+			KeepLast:   5,
+			KeepDaily:  7,
+			KeepWeekly: 4,
+		},
+		Upload: NewUploadConfig(),
+	}
+}
+
+// ForServer returns a copy of the configuration that keeps the archives of the named server apart from the other
+// servers, in sub-directories of the local directory and of the storages.
+func (c *Config) ForServer(name string) *Config {
+	config := *c
+	config.Dir = filepath.Join(c.Dir, name)
+	config.Storage = make([]*StorageConfig, len(c.Storage))
+	for i, storage := range c.Storage {
+		storageConfig := *storage
+		if storage.Type == LocalStorage {
+			storageConfig.Path = filepath.Join(storage.Path, name)
+		} else {
+			storageConfig.Path = path.Join(storage.Path, name)
+		}
+		config.Storage[i] = &storageConfig
+	}
+	return &config
+}
+
+func (c Config) AbsDir() string {
+	if filepath.IsAbs(c.Dir) {
+		return c.Dir
+	}
+	return filepath.Join(c.BaseDir, c.Dir)
+}
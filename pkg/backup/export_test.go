@@ -0,0 +1,4 @@
+package backup
+
+// Exported for testing
+var SignV4 = signV4
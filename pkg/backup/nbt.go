@@ -0,0 +1,173 @@
+package backup
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Minimal NBT reader, only used to check that level.dat is well-formed.
+// cf. https://minecraft.fandom.com/wiki/NBT_format
+
+type (
+	nbtReader struct {
+		*bufio.Reader
+	}
+
+	nbtTag byte
+)
+
+const (
+	tagEnd nbtTag = iota
+	tagByte
+	tagShort
+	tagInt
+	tagLong
+	tagFloat
+	tagDouble
+	tagByteArray
+	tagString
+	tagList
+	tagCompound
+	tagIntArray
+	tagLongArray
+
+	maxNBTDepth = 512
+)
+
+var (
+	ErrInvalidNBT  = errors.New("invalid NBT data")
+	ErrNoLevelData = errors.New("level.dat has no Data compound")
+)
+
+// CheckLevelDat checks that the reader contains a gzipped NBT compound with a Data entry.
+func CheckLevelDat(r io.Reader) error {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return fmt.Errorf("level.dat: %w", err)
+	}
+	defer gz.Close()
+
+	nbt := &nbtReader{bufio.NewReader(gz)}
+	if tag, err := nbt.tag(); err != nil {
+		return err
+	} else if tag != tagCompound {
+		return fmt.Errorf("%w: root tag is %d", ErrInvalidNBT, tag)
+	}
+	if _, err := nbt.string(); err != nil {
+		return err
+	}
+	names, err := nbt.compound(0)
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		if name == "Data" {
+			return nil
+		}
+	}
+	return ErrNoLevelData
+}
+
+func (r *nbtReader) tag() (nbtTag, error) {
+	b, err := r.ReadByte()
+	if err != nil {
+		return tagEnd, err
+	}
+	if tag := nbtTag(b); tag <= tagLongArray {
+		return tag, nil
+	}
+	return tagEnd, fmt.Errorf("%w: unknown tag %d", ErrInvalidNBT, b)
+}
+
+func (r *nbtReader) string() (string, error) {
+	var length uint16
+	if err := binary.Read(r, binary.BigEndian, &length); err != nil {
+		return "", err
+	}
+	buf := make([]byte, length)
+	_, err := io.ReadFull(r, buf)
+	return string(buf), err
+}
+
+func (r *nbtReader) length() (int64, error) {
+	var length int32
+	if err := binary.Read(r, binary.BigEndian, &length); err != nil {
+		return 0, err
+	}
+	if length < 0 {
+		return 0, fmt.Errorf("%w: negative length", ErrInvalidNBT)
+	}
+	return int64(length), nil
+}
+
+func (r *nbtReader) skip(n int64) error {
+	_, err := io.CopyN(io.Discard, r, n)
+	return err
+}
+
+// compound reads named tags until the end tag and returns their names.
+func (r *nbtReader) compound(depth int) (names []string, err error) {
+	for {
+		tag, err := r.tag()
+		if err != nil || tag == tagEnd {
+			return names, err
+		}
+		name, err := r.string()
+		if err != nil {
+			return names, err
+		}
+		names = append(names, name)
+		if err = r.payload(tag, depth+1); err != nil {
+			return names, err
+		}
+	}
+}
+
+func (r *nbtReader) payload(tag nbtTag, depth int) error {
+	if depth > maxNBTDepth {
+		return fmt.Errorf("%w: too deeply nested", ErrInvalidNBT)
+	}
+	switch tag {
+	case tagByte:
+		return r.skip(1)
+	case tagShort:
+		return r.skip(2)
+	case tagInt, tagFloat:
+		return r.skip(4)
+	case tagLong, tagDouble:
+		return r.skip(8)
+	case tagString:
+		_, err := r.string()
+		return err
+	case tagByteArray, tagIntArray, tagLongArray:
+		length, err := r.length()
+		if err != nil {
+			return err
+		}
+		return r.skip(length * map[nbtTag]int64{tagByteArray: 1, tagIntArray: 4, tagLongArray: 8}[tag])
+	case tagList:
+		elemTag, err := r.tag()
+		if err != nil {
+			return err
+		}
+		length, err := r.length()
+		if err != nil {
+			return err
+		}
+		for i := int64(0); i < length; i++ {
+			if err = r.payload(elemTag, depth+1); err != nil {
+				return err
+			}
+		}
+		return nil
+	case tagCompound:
+		_, err := r.compound(depth)
+		return err
+	default:
+		return fmt.Errorf("%w: unexpected tag %d", ErrInvalidNBT, tag)
+	}
+}
@@ -0,0 +1,91 @@
+package backup_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	"github.com/Adirelle/mcvisor/pkg/backup"
+)
+
+type nbtBuilder struct {
+	bytes.Buffer
+}
+
+func (b *nbtBuilder) named(tag byte, name string) *nbtBuilder {
+	_ = b.WriteByte(tag)
+	return b.string(name)
+}
+
+func (b *nbtBuilder) string(value string) *nbtBuilder {
+	_ = binary.Write(b, binary.BigEndian, uint16(len(value)))
+	_, _ = b.WriteString(value)
+	return b
+}
+
+func (b *nbtBuilder) int32(value int32) *nbtBuilder {
+	_ = binary.Write(b, binary.BigEndian, value)
+	return b
+}
+
+func (b *nbtBuilder) byte(value byte) *nbtBuilder {
+	_ = b.WriteByte(value)
+	return b
+}
+
+func (b *nbtBuilder) end() *nbtBuilder {
+	return b.byte(0)
+}
+
+func (b *nbtBuilder) gzipped() *bytes.Buffer {
+	output := &bytes.Buffer{}
+	writer := gzip.NewWriter(output)
+	_, _ = writer.Write(b.Bytes())
+	_ = writer.Close()
+	return output
+}
+
+func TestCheckLevelDat(t *testing.T) {
+	t.Parallel()
+
+	b := &nbtBuilder{}
+	b.named(10, "").
+		named(10, "Data").
+		named(8, "LevelName").string("world").
+		named(3, "version").int32(19133).
+		named(9, "ServerBrands").byte(8).int32(1).string("vanilla").
+		named(11, "Pos").int32(3).int32(1).int32(2).int32(3).
+		end().
+		end()
+
+	if err := backup.CheckLevelDat(b.gzipped()); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+}
+
+func TestCheckLevelDatWithoutData(t *testing.T) {
+	t.Parallel()
+
+	b := &nbtBuilder{}
+	b.named(10, "").named(3, "foo").int32(1).end()
+
+	if err := backup.CheckLevelDat(b.gzipped()); !errors.Is(err, backup.ErrNoLevelData) {
+		t.Errorf("expected ErrNoLevelData, got %v", err)
+	}
+}
+
+func TestCheckLevelDatTruncated(t *testing.T) {
+	t.Parallel()
+
+	b := &nbtBuilder{}
+	b.named(10, "").named(10, "Data").named(8, "LevelName")
+
+	if err := backup.CheckLevelDat(b.gzipped()); err == nil {
+		t.Error("expected an error")
+	}
+	if err := backup.CheckLevelDat(bytes.NewReader([]byte("not gzip"))); err == nil {
+		t.Error("expected an error")
+	}
+}
@@ -0,0 +1,244 @@
+package backup
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Adirelle/mcvisor/pkg/commands"
+	"github.com/Adirelle/mcvisor/pkg/discord"
+	"github.com/Adirelle/mcvisor/pkg/minecraft"
+	"github.com/Adirelle/mcvisor/pkg/utils"
+	"github.com/apex/log"
+)
+
+type (
+	Verification struct {
+		Checksum string
+		Recorded bool
+		Files    int
+		Size     int64
+		Roots    []string
+	}
+
+	RestoreProgress struct {
+		ID   string
+		Step string
+	}
+)
+
+const (
+	ListCommand    commands.Name = "backups"
+	RestoreCommand commands.Name = "restore"
+
+	levelDat = "level.dat"
+)
+
+var (
+	// Interface check
+	_ discord.Notification = (*RestoreProgress)(nil)
+
+	// Extra time given to the server to stop or start, on top of its own timeouts.
+	RestoreTargetMargin = time.Minute
+)
+
+func (s *Service) handleListCommand(*commands.Command) (string, error) {
+	archives, err := ListArchives(s.AbsDir())
+	if err != nil || len(archives) == 0 {
+		return "No backups", err
+	}
+	builder := &strings.Builder{}
+	for _, archive := range archives {
+		_, _ = fmt.Fprintf(builder, "- `%s` %s, %s (<t:%d:f>)\n", archive.ID, archive.Format, utils.HumanSize(archive.Size), archive.Time.Unix())
+	}
+	return builder.String(), nil
+}
+
+func (s *Service) handleRestoreCommand(cmd *commands.Command) (string, error) {
+	if len(cmd.Arguments) != 1 || cmd.Arguments[0] == "" {
+		return "", fmt.Errorf("usage: `%s <id>`", RestoreCommand)
+	}
+	id := cmd.Arguments[0]
+	return s.submit(func() (string, error) {
+		log.WithFields(cmd).WithField("id", id).Warn("backup.restore")
+		message, err := s.Restore(id)
+		if err != nil {
+			log.WithError(err).WithField("id", id).Error("backup.restore")
+		}
+		return message, err
+	})
+}
+
+func (s *Service) verify(id string) (string, error) {
+	archive, err := FindArchive(s.AbsDir(), id)
+	if err != nil {
+		return "", err
+	}
+	result, err := archive.Verify()
+	if err != nil {
+		return "", err
+	}
+
+	checksum := "no recorded checksum"
+	if result.Recorded {
+		checksum = "checksum matches"
+	}
+	return fmt.Sprintf(
+		"Backup `%s` is valid: %s (`%s`), %d files, %s uncompressed, %s parsed",
+		archive.ID,
+		checksum,
+		result.Checksum[:16],
+		result.Files,
+		utils.HumanSize(result.Size),
+		levelDat,
+	), nil
+}
+
+// Verify re-reads the whole archive, checks its checksum and parses the level.dat files.
+func (a *Archive) Verify() (*Verification, error) {
+	result := &Verification{}
+
+	var err error
+	if result.Checksum, err = a.Checksum(); err != nil {
+		return nil, err
+	}
+	recorded, err := a.RecordedChecksum()
+	switch {
+	case err == nil && recorded != result.Checksum:
+		return nil, fmt.Errorf("checksum mismatch: expected %s, got %s", recorded, result.Checksum)
+	case err == nil:
+		result.Recorded = true
+	case !os.IsNotExist(err):
+		return nil, err
+	}
+
+	roots := make(map[string]bool)
+	levels := 0
+	err = a.Walk(func(name string, mode fs.FileMode, content io.Reader) error {
+		roots[strings.SplitN(name, "/", 2)[0]] = true
+		if content == nil {
+			return nil
+		}
+		result.Files++
+		if path.Base(name) != levelDat || strings.Count(name, "/") != 1 {
+			n, err := io.Copy(io.Discard, content)
+			result.Size += n
+			return err
+		}
+		data, err := io.ReadAll(content)
+		result.Size += int64(len(data))
+		if err == nil {
+			err = CheckLevelDat(bytes.NewReader(data))
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		levels++
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if levels == 0 {
+		return nil, fmt.Errorf("no %s found in the archive", levelDat)
+	}
+
+	for root := range roots {
+		result.Roots = append(result.Roots, root)
+	}
+	sort.Strings(result.Roots)
+	return result, nil
+}
+
+// Restore replaces the world with the content of the archive, stopping and restarting the server as needed.
+// The current world directories are kept aside.
+func (s *Service) Restore(id string) (string, error) {
+	progress := func(step string) {
+		log.WithField("id", id).WithField("step", step).Info("backup.restore.progress")
+		s.dispatcher.Dispatch(&RestoreProgress{id, step})
+	}
+
+	archive, err := FindArchive(s.AbsDir(), id)
+	if err != nil {
+		return "", err
+	}
+
+	progress("verifying the backup")
+	verification, err := archive.Verify()
+	if err != nil {
+		return "", fmt.Errorf("backup is invalid: %w", err)
+	}
+
+	wasRunning := s.server.Status() != minecraft.Stopped
+	if wasRunning {
+		progress("stopping the server")
+		timeout := s.serverConfig.StopTimeout + s.serverConfig.TermTimeout + RestoreTargetMargin
+		if _, err = minecraft.ReachTarget(s.dispatcher, s.server, minecraft.StopTarget, timeout); err != nil {
+			return "", err
+		}
+	}
+
+	workDir := s.serverConfig.AbsWorkingDir()
+	suffix := ".pre-restore-" + time.Now().Format(idLayout)
+	var movedAside []string
+	for _, root := range verification.Roots {
+		current := filepath.Join(workDir, root)
+		if _, err = os.Stat(current); os.IsNotExist(err) {
+			continue
+		}
+		if err = os.Rename(current, current+suffix); err != nil {
+			s.rollback(movedAside, suffix)
+			return "", fmt.Errorf("could not move %s aside: %w", root, err)
+		}
+		movedAside = append(movedAside, root)
+		progress(fmt.Sprintf("moved `%s` aside to `%s`", root, root+suffix))
+	}
+
+	progress("extracting the backup")
+	err = archive.Walk(func(name string, mode fs.FileMode, content io.Reader) error {
+		return extractEntry(workDir, name, mode, content)
+	})
+	if err != nil {
+		for _, root := range verification.Roots {
+			_ = os.RemoveAll(filepath.Join(workDir, root))
+		}
+		s.rollback(movedAside, suffix)
+		return "", fmt.Errorf("could not extract the backup: %w", err)
+	}
+
+	if wasRunning {
+		progress("starting the server")
+		s.dispatcher.Dispatch(minecraft.StartTarget)
+	}
+
+	message := fmt.Sprintf("Backup `%s` restored", archive.ID)
+	if len(movedAside) > 0 {
+		message += fmt.Sprintf(", previous world kept with the `%s` suffix", suffix)
+	}
+	return message, nil
+}
+
+func (s *Service) rollback(movedAside []string, suffix string) {
+	workDir := s.serverConfig.AbsWorkingDir()
+	for _, root := range movedAside {
+		current := filepath.Join(workDir, root)
+		if err := os.Rename(current+suffix, current); err != nil {
+			log.WithError(err).WithField("path", current).Error("backup.restore.rollback")
+		}
+	}
+}
+
+func (p *RestoreProgress) Fields() log.Fields {
+	return log.Fields{"id": p.ID, "step": p.Step}
+}
+
+func (p *RestoreProgress) DiscordNotification() string {
+	return fmt.Sprintf("**Restoring backup `%s`**: %s", p.ID, p.Step)
+}
@@ -0,0 +1,46 @@
+package backup
+
+import (
+	"fmt"
+	"time"
+)
+
+// Expired returns the archives that are not kept by any retention rule.
+// The archives must be sorted newest first, as returned by ListArchives.
+func (r *RetentionConfig) Expired(archives []*Archive) (expired []*Archive) {
+	if r.KeepLast == 0 && r.KeepDaily == 0 && r.KeepWeekly == 0 {
+		return nil
+	}
+
+	kept := make(map[*Archive]bool, len(archives))
+	for i := 0; i < r.KeepLast && i < len(archives); i++ {
+		kept[archives[i]] = true
+	}
+	keepNewestOfPeriods(archives, r.KeepDaily, kept, func(t time.Time) string {
+		return t.Format("2006-01-02")
+	})
+	keepNewestOfPeriods(archives, r.KeepWeekly, kept, func(t time.Time) string {
+		year, week := t.ISOWeek()
+		return fmt.Sprintf("%d-%d", year, week)
+	})
+
+	for _, archive := range archives {
+		if !kept[archive] {
+			expired = append(expired, archive)
+		}
+	}
+	return
+}
+
+func keepNewestOfPeriods(archives []*Archive, count int, kept map[*Archive]bool, period func(time.Time) string) {
+	seen := make(map[string]bool, count)
+	for _, archive := range archives {
+		if len(seen) >= count {
+			return
+		}
+		if key := period(archive.Time); !seen[key] {
+			seen[key] = true
+			kept[archive] = true
+		}
+	}
+}
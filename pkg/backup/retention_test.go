@@ -0,0 +1,56 @@
+package backup_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Adirelle/mcvisor/pkg/backup"
+)
+
+func TestRetentionExpired(t *testing.T) {
+	t.Parallel()
+
+	// Two backups a day for 30 days, newest first.
+	start := time.Date(2022, 5, 31, 18, 0, 0, 0, time.Local)
+	archives := make([]*backup.Archive, 60)
+	for i := range archives {
+		archives[i] = &backup.Archive{ID: string(rune('a' + i)), Time: start.Add(-time.Duration(i) * 12 * time.Hour)}
+	}
+
+	retention := &backup.RetentionConfig{KeepLast: 3, KeepDaily: 5, KeepWeekly: 4}
+	expired := retention.Expired(archives)
+
+	isExpired := make(map[*backup.Archive]bool)
+	for _, archive := range expired {
+		isExpired[archive] = true
+	}
+
+	for i := 0; i < 3; i++ {
+		if isExpired[archives[i]] {
+			t.Errorf("archive #%d should be kept as one of the last ones", i)
+		}
+	}
+	// Newest backup of the 4th and 5th days
+	for _, i := range []int{6, 8} {
+		if isExpired[archives[i]] {
+			t.Errorf("archive #%d should be kept as a daily backup", i)
+		}
+	}
+	if !isExpired[archives[9]] {
+		t.Error("archive #9 should have expired")
+	}
+
+	// 3 last + 2 more dailies + newest of up to 3 older weeks
+	if kept := len(archives) - len(expired); kept < 5 || kept > 8 {
+		t.Errorf("unexpected number of kept archives: %d", kept)
+	}
+}
+
+func TestRetentionDisabled(t *testing.T) {
+	t.Parallel()
+
+	archives := []*backup.Archive{{ID: "a", Time: time.Now()}, {ID: "b", Time: time.Now().Add(-time.Hour)}}
+	if expired := (&backup.RetentionConfig{}).Expired(archives); len(expired) != 0 {
+		t.Errorf("expected no expired archive, got %d", len(expired))
+	}
+}
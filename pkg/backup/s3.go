@@ -0,0 +1,293 @@
+package backup
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Adirelle/mcvisor/pkg/utils"
+)
+
+type (
+	// S3Config describes a bucket on any S3-compatible endpoint (AWS, MinIO, Backblaze B2, ...).
+	// The credentials default to the AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY environment variables.
+	S3Config struct {
+		Endpoint  string       `json:"endpoint" validate:"required,url"`
+		Region    string       `json:"region,omitempty"`
+		Bucket    string       `json:"bucket" validate:"required"`
+		AccessKey string       `json:"access_key,omitempty"`
+		SecretKey utils.Secret `json:"secret_key,omitempty"`
+		PathStyle bool         `json:"path_style,omitempty"`
+	}
+
+	// s3Storage is a minimal S3 client, signing its requests with AWS Signature Version 4.
+	// Objects are uploaded in a single request, which limits their size to 5 GiB.
+	s3Storage struct {
+		*S3Config
+		prefix string
+		client *http.Client
+	}
+
+	s3Error struct {
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+
+	s3ListResult struct {
+		Contents []struct {
+			Key string `xml:"Key"`
+		} `xml:"Contents"`
+		IsTruncated           bool   `xml:"IsTruncated"`
+		NextContinuationToken string `xml:"NextContinuationToken"`
+	}
+)
+
+const (
+	DefaultS3Region = "us-east-1"
+
+	s3Algorithm       = "AWS4-HMAC-SHA256"
+	s3DateLayout      = "20060102T150405Z"
+	s3UnsignedPayload = "UNSIGNED-PAYLOAD"
+)
+
+var (
+	// Interface check
+	_ Storage = (*s3Storage)(nil)
+)
+
+func newS3Storage(config *S3Config, prefix string) *s3Storage {
+	return &s3Storage{config, strings.Trim(path.Clean("/"+prefix), "/"), http.DefaultClient}
+}
+
+func (s *s3Storage) String() string {
+	return fmt.Sprintf("s3://%s/%s", s.Bucket, s.prefix)
+}
+
+func (s *s3Storage) Put(ctx context.Context, name string, content io.Reader, size int64) error {
+	req, err := s.newRequest(ctx, http.MethodPut, s.key(name), nil, content)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	return s.do(req, nil)
+}
+
+func (s *s3Storage) Get(ctx context.Context, name string) (io.ReadCloser, error) {
+	req, err := s.newRequest(ctx, http.MethodGet, s.key(name), nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.send(req)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+func (s *s3Storage) List(ctx context.Context) (names []string, err error) {
+	prefix := ""
+	if s.prefix != "" {
+		prefix = s.prefix + "/"
+	}
+	query := url.Values{"list-type": {"2"}, "prefix": {prefix}, "delimiter": {"/"}}
+	for {
+		req, err := s.newRequest(ctx, http.MethodGet, "", query, nil)
+		if err != nil {
+			return nil, err
+		}
+		var result s3ListResult
+		if err = s.do(req, &result); err != nil {
+			return nil, err
+		}
+		for _, object := range result.Contents {
+			names = append(names, strings.TrimPrefix(object.Key, prefix))
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return names, nil
+		}
+		query.Set("continuation-token", result.NextContinuationToken)
+	}
+}
+
+func (s *s3Storage) Delete(ctx context.Context, name string) error {
+	req, err := s.newRequest(ctx, http.MethodDelete, s.key(name), nil, nil)
+	if err != nil {
+		return err
+	}
+	return s.do(req, nil)
+}
+
+func (s *s3Storage) key(name string) string {
+	return path.Join(s.prefix, name)
+}
+
+func (s *s3Storage) region() string {
+	if s.Region == "" {
+		return DefaultS3Region
+	}
+	return s.Region
+}
+
+func (s *s3Storage) credentials() (accessKey, secretKey string) {
+	accessKey, secretKey = s.AccessKey, s.SecretKey.Reveal()
+	if accessKey == "" {
+		accessKey = os.Getenv("AWS_ACCESS_KEY_ID")
+	}
+	if secretKey == "" {
+		secretKey = os.Getenv("AWS_SECRET_ACCESS_KEY")
+	}
+	return
+}
+
+// newRequest builds a signed request for the given object key, or for the bucket itself if key is empty.
+func (s *s3Storage) newRequest(ctx context.Context, method, key string, query url.Values, body io.Reader) (*http.Request, error) {
+	endpoint, err := url.Parse(s.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+	objectPath := "/" + key
+	if s.PathStyle {
+		objectPath = "/" + s.Bucket + objectPath
+	} else {
+		endpoint.Host = s.Bucket + "." + endpoint.Host
+	}
+	endpoint.Path = objectPath
+	endpoint.RawPath = s3Escape(objectPath, false)
+	endpoint.RawQuery = s3CanonicalQuery(query)
+
+	req, err := http.NewRequestWithContext(ctx, method, endpoint.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	s.sign(req, time.Now().UTC())
+	return req, nil
+}
+
+func (s *s3Storage) sign(req *http.Request, now time.Time) {
+	accessKey, secretKey := s.credentials()
+	req.Header.Set("X-Amz-Date", now.Format(s3DateLayout))
+	req.Header.Set("X-Amz-Content-Sha256", s3UnsignedPayload)
+	signV4(req, []string{"host", "x-amz-content-sha256", "x-amz-date"}, s3UnsignedPayload, s.region(), "s3", accessKey, secretKey, now)
+}
+
+// signV4 sets the Authorization header of the request using AWS Signature Version 4.
+// signedHeaders are the sorted, lower-case names of the headers to sign; the host is read from the URL.
+func signV4(req *http.Request, signedHeaders []string, payloadHash, region, service, accessKey, secretKey string, now time.Time) {
+	date := now.UTC().Format(s3DateLayout)
+
+	canonicalHeaders := make([]string, len(signedHeaders))
+	for i, name := range signedHeaders {
+		value := req.Header.Get(name)
+		if name == "host" {
+			value = req.URL.Host
+		}
+		canonicalHeaders[i] = name + ":" + strings.TrimSpace(value) + "\n"
+	}
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		strings.Join(canonicalHeaders, ""),
+		strings.Join(signedHeaders, ";"),
+		payloadHash,
+	}, "\n")
+
+	scopeParts := []string{date[:8], region, service, "aws4_request"}
+	scope := strings.Join(scopeParts, "/")
+	stringToSign := strings.Join([]string{s3Algorithm, date, scope, hexSHA256(canonicalRequest)}, "\n")
+
+	key := []byte("AWS4" + secretKey)
+	for _, part := range scopeParts {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s3Algorithm, accessKey, scope, strings.Join(signedHeaders, ";"), signature,
+	))
+}
+
+// send executes the request and turns S3 error responses into errors.
+func (s *s3Storage) send(req *http.Request) (*http.Response, error) {
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+	var s3Err s3Error
+	if err = xml.NewDecoder(resp.Body).Decode(&s3Err); err != nil || s3Err.Code == "" {
+		return nil, fmt.Errorf("s3: %s %s: %s", req.Method, req.URL.Path, resp.Status)
+	}
+	return nil, fmt.Errorf("s3: %s %s: %s: %s", req.Method, req.URL.Path, s3Err.Code, s3Err.Message)
+}
+
+// do executes the request and decodes the XML response into result, if not nil.
+func (s *s3Storage) do(req *http.Request, result any) error {
+	resp, err := s.send(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if result == nil {
+		_, err = io.Copy(io.Discard, resp.Body)
+		return err
+	}
+	return xml.NewDecoder(resp.Body).Decode(result)
+}
+
+// s3Escape percent-encodes everything but the unreserved characters, and optionally the slashes.
+func s3Escape(value string, escapeSlash bool) string {
+	var builder strings.Builder
+	for _, b := range []byte(value) {
+		switch {
+		case 'A' <= b && b <= 'Z', 'a' <= b && b <= 'z', '0' <= b && b <= '9', b == '-', b == '_', b == '.', b == '~':
+			builder.WriteByte(b)
+		case b == '/' && !escapeSlash:
+			builder.WriteByte(b)
+		default:
+			fmt.Fprintf(&builder, "%%%02X", b)
+		}
+	}
+	return builder.String()
+}
+
+func s3CanonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	parts := make([]string, 0, len(keys))
+	for _, key := range keys {
+		for _, value := range query[key] {
+			parts = append(parts, s3Escape(key, true)+"="+s3Escape(value, true))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+func hexSHA256(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, content string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(content))
+	return mac.Sum(nil)
+}
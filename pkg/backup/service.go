@@ -0,0 +1,298 @@
+package backup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/Adirelle/mcvisor/pkg/commands"
+	"github.com/Adirelle/mcvisor/pkg/discord"
+	"github.com/Adirelle/mcvisor/pkg/events"
+	"github.com/Adirelle/mcvisor/pkg/minecraft"
+	"github.com/Adirelle/mcvisor/pkg/utils"
+	"github.com/apex/log"
+	properties "github.com/dmotylev/goproperties"
+	"github.com/thejerf/suture/v4"
+)
+
+type (
+	Service struct {
+		*Config
+		serverConfig *minecraft.ServerConfig
+		server       Server
+		dispatcher   *events.Dispatcher
+		requests     chan *request
+		busy         sync.Mutex
+		uploaders    []*Uploader
+	}
+
+	Server interface {
+		minecraft.Statuser
+		minecraft.Console
+		CollectConsoleOutput(command string, terminator *regexp.Regexp, maxWait time.Duration) ([]string, error)
+	}
+
+	request struct {
+		run   func() (string, error)
+		reply chan<- result
+	}
+
+	result struct {
+		message string
+		err     error
+	}
+
+	BackupFailed struct {
+		Reason error
+	}
+)
+
+const (
+	BackupCommand commands.Name = "backup"
+
+	DefaultLevelName = "world"
+)
+
+var (
+	// Interface checks
+	_ suture.Service       = (*Service)(nil)
+	_ discord.Notification = (*BackupFailed)(nil)
+
+	savedRegexp = regexp.MustCompile(`^Saved the game$`)
+
+	SubmitTimeout = 5 * time.Second
+
+	ErrBusy        = errors.New("a backup operation is already in progress")
+	ErrNotRunning  = errors.New("backup service is not running")
+	ErrTransition  = errors.New("the server is starting or stopping, try again later")
+	ErrBackupUsage = errors.New("usage: `backup`, `backup verify <id>` or `backup upload <id>`")
+	ErrNoWorld     = errors.New("no world directory found")
+	ErrNoStorage   = errors.New("no backup storage is configured")
+)
+
+func NewService(config *Config, serverConfig *minecraft.ServerConfig, server Server, dispatcher *events.Dispatcher, registry *commands.Registry) *Service {
+	s := &Service{
+		Config:       config,
+		serverConfig: serverConfig,
+		server:       server,
+		dispatcher:   dispatcher,
+		requests:     make(chan *request),
+	}
+	for _, target := range config.Storage {
+		retention := target.Retention
+		if retention == nil {
+			retention = config.Retention
+		}
+		s.uploaders = append(s.uploaders, NewUploader(target.Name, NewStorage(target, config.BaseDir), retention, config.Upload, dispatcher))
+	}
+	registry.Register(BackupCommand, "back up the world, check a backup with `backup verify <id>` or upload it again with `backup upload <id>`", discord.AdminCategory, commands.HandlerFunc(s.handleBackupCommand))
+	registry.Register(ListCommand, "list the backups", discord.AdminCategory, commands.HandlerFunc(s.handleListCommand))
+	registry.Register(RestoreCommand, "restore a backup, replacing the current world", discord.AdminCategory, commands.HandlerFunc(s.handleRestoreCommand))
+	return s
+}
+
+// Serve runs the backup operations one at a time.
+func (s *Service) Serve(ctx context.Context) error {
+	for {
+		select {
+		case req := <-s.requests:
+			message, err := req.run()
+			req.reply <- result{message, err}
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+func (s *Service) handleBackupCommand(cmd *commands.Command) (string, error) {
+	switch {
+	case len(cmd.Arguments) == 0 || cmd.Arguments[0] == "":
+		return s.submit(s.backup)
+	case cmd.Arguments[0] == "verify" && len(cmd.Arguments) == 2:
+		id := cmd.Arguments[1]
+		return s.submit(func() (string, error) { return s.verify(id) })
+	case cmd.Arguments[0] == "upload" && len(cmd.Arguments) == 2:
+		return s.upload(cmd.Arguments[1])
+	default:
+		return "", ErrBackupUsage
+	}
+}
+
+// submit runs the operation in the service goroutine and waits for its result.
+// It fails right away if another operation is in progress.
+func (s *Service) submit(run func() (string, error)) (string, error) {
+	if !s.busy.TryLock() {
+		return "", ErrBusy
+	}
+	defer s.busy.Unlock()
+	reply := make(chan result, 1)
+	if err := utils.SendWithTimeout(s.requests, &request{run, reply}, SubmitTimeout); err != nil {
+		return "", ErrNotRunning
+	}
+	res := <-reply
+	return res.message, res.err
+}
+
+func (s *Service) backup() (string, error) {
+	archive, removed, err := s.Backup()
+	if err != nil {
+		log.WithError(err).Error("backup.failed")
+		s.dispatcher.Dispatch(&BackupFailed{err})
+		return "", err
+	}
+	message := fmt.Sprintf(
+		"Backup `%s` created (%s), %d old backup(s) removed",
+		archive.ID,
+		utils.HumanSize(archive.Size),
+		removed,
+	)
+	if queued := s.enqueueUpload(archive); queued > 0 {
+		message += fmt.Sprintf(", upload queued to %d storage(s)", queued)
+	}
+	return message, nil
+}
+
+// Uploaders returns the services that copy the archives to the configured storages.
+func (s *Service) Uploaders() []*Uploader {
+	return s.uploaders
+}
+
+func (s *Service) upload(id string) (string, error) {
+	if len(s.uploaders) == 0 {
+		return "", ErrNoStorage
+	}
+	archive, err := FindArchive(s.AbsDir(), id)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Upload of `%s` queued to %d storage(s)", archive.ID, s.enqueueUpload(archive)), nil
+}
+
+// enqueueUpload schedules the upload of the archive to every storage and returns how many accepted it.
+func (s *Service) enqueueUpload(archive *Archive) (queued int) {
+	for _, uploader := range s.uploaders {
+		if err := uploader.Enqueue(archive); err != nil {
+			log.WithError(err).WithField("storage", uploader.Name).WithField("archive", archive.ID).Error("backup.upload.queue")
+			continue
+		}
+		queued++
+	}
+	return
+}
+
+// Backup creates a new archive of the world and applies the retention rules.
+func (s *Service) Backup() (archive *Archive, removed int, err error) {
+	start := time.Now()
+	logger := log.WithField("dir", s.AbsDir())
+	if status := s.server.Status(); status.IsOneOf(minecraft.Starting, minecraft.Stopping) {
+		return nil, 0, ErrTransition
+	}
+	logger.Info("backup.start")
+
+	paths, err := s.worldPaths()
+	if err != nil {
+		return
+	}
+
+	if s.server.Status().IsRunning() {
+		if _, err = s.server.ExecuteConsoleCommand("save-off"); err != nil {
+			return nil, 0, fmt.Errorf("could not disable saving: %w", err)
+		}
+		defer func() {
+			if _, saveOnErr := s.server.ExecuteConsoleCommand("save-on"); saveOnErr != nil {
+				logger.WithError(saveOnErr).Error("backup.save-on")
+				if err == nil {
+					err = fmt.Errorf("could not re-enable saving: %w", saveOnErr)
+				}
+			}
+		}()
+		if _, err = s.server.CollectConsoleOutput("save-all flush", savedRegexp, s.SaveTimeout); err != nil {
+			return nil, 0, fmt.Errorf("could not save the world: %w", err)
+		}
+		logger.Debug("backup.saved")
+	}
+
+	if err = os.MkdirAll(s.AbsDir(), 0o755); err != nil {
+		return
+	}
+
+	id := uniqueArchiveID(s.AbsDir(), start)
+	path := filepath.Join(s.AbsDir(), archiveName(id, s.Format))
+	if err = createArchive(path, s.Format, s.serverConfig.AbsWorkingDir(), paths); err != nil {
+		return nil, 0, fmt.Errorf("could not create archive: %w", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return
+	}
+	archive = &Archive{ID: id, Path: path, Format: s.Format, Time: start, Size: info.Size()}
+	logger.WithField("archive", path).WithField("size", archive.Size).WithField("duration", time.Since(start)).Info("backup.done")
+
+	removed, err = s.applyRetention()
+	return
+}
+
+func (s *Service) applyRetention() (removed int, err error) {
+	archives, err := ListArchives(s.AbsDir())
+	if err != nil {
+		return
+	}
+	for _, archive := range s.Retention.Expired(archives) {
+		if s.isUploading(archive) {
+			log.WithField("archive", archive.Path).Info("backup.retention.pinned")
+			continue
+		}
+		if err = archive.Remove(); err != nil {
+			return
+		}
+		log.WithField("archive", archive.Path).Info("backup.retention.remove")
+		removed++
+	}
+	return
+}
+
+// isUploading returns whether an upload of the archive is pending, so that it is kept until it is done.
+func (s *Service) isUploading(archive *Archive) bool {
+	for _, uploader := range s.uploaders {
+		if uploader.IsPending(archive) {
+			return true
+		}
+	}
+	return false
+}
+
+// worldPaths lists the world directories, relative to the server working directory.
+func (s *Service) worldPaths() ([]string, error) {
+	level := DefaultLevelName
+	if props, err := properties.Load(s.serverConfig.AbsServerProperties()); err == nil {
+		level = props.String("level-name", DefaultLevelName)
+	} else {
+		log.WithError(err).WithField("path", s.serverConfig.AbsServerProperties()).Warn("backup.properties")
+	}
+
+	candidates := append([]string{level, level + "_nether", level + "_the_end"}, s.Extra...)
+	paths := make([]string, 0, len(candidates))
+	for _, path := range candidates {
+		if _, err := os.Stat(filepath.Join(s.serverConfig.AbsWorkingDir(), path)); err == nil {
+			paths = append(paths, path)
+		}
+	}
+	if len(paths) == 0 {
+		return nil, ErrNoWorld
+	}
+	return paths, nil
+}
+
+func (e *BackupFailed) Fields() log.Fields {
+	return log.Fields{"error": e.Reason}
+}
+
+func (e *BackupFailed) DiscordNotification() string {
+	return fmt.Sprintf("**Backup failed**: %s", e.Reason)
+}
@@ -0,0 +1,207 @@
+package backup_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/Adirelle/mcvisor/pkg/backup"
+	"github.com/Adirelle/mcvisor/pkg/commands"
+	"github.com/Adirelle/mcvisor/pkg/events"
+	"github.com/Adirelle/mcvisor/pkg/minecraft"
+)
+
+type fakeServer struct {
+	status minecraft.Status
+	saving chan struct{}
+	saved  chan struct{}
+}
+
+func (s *fakeServer) Status() minecraft.Status {
+	return s.status
+}
+
+func (s *fakeServer) ExecuteConsoleCommand(string) (string, error) {
+	return "", nil
+}
+
+func (s *fakeServer) CollectConsoleOutput(string, *regexp.Regexp, time.Duration) ([]string, error) {
+	if s.saving != nil {
+		s.saving <- struct{}{}
+		<-s.saved
+	}
+	return []string{"Saved the game"}, nil
+}
+
+func newBackupService(t *testing.T, server *fakeServer) (*backup.Service, *commands.Registry) {
+	t.Helper()
+	serverConfig := minecraft.NewConfig(t.TempDir()).Server
+	world := filepath.Join(serverConfig.AbsWorkingDir(), "world")
+	if err := os.MkdirAll(world, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(world, "level.dat"), []byte("level"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	config := backup.NewConfig(t.TempDir())
+	config.Format = backup.ZipFormat
+	config.Retention = &backup.RetentionConfig{}
+	registry := commands.NewRegistry()
+	return backup.NewService(config, serverConfig, server, events.NewDispatcher(), registry), registry
+}
+
+func serve(t *testing.T, service *backup.Service) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go func() { _ = service.Serve(ctx) }()
+}
+
+func TestServiceBusy(t *testing.T) {
+	t.Parallel()
+
+	server := &fakeServer{status: minecraft.Ready, saving: make(chan struct{}), saved: make(chan struct{})}
+	service, registry := newBackupService(t, server)
+	serve(t, service)
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := registry.HandleCommand(commands.NewCommand("backup", commands.System))
+		done <- err
+	}()
+	<-server.saving
+
+	if _, err := registry.HandleCommand(commands.NewCommand("backup", commands.System)); !errors.Is(err, backup.ErrBusy) {
+		t.Errorf("expected ErrBusy, got %v", err)
+	}
+
+	close(server.saved)
+	if err := <-done; err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+}
+
+func TestServiceNotRunning(t *testing.T) {
+	timeout := backup.SubmitTimeout
+	backup.SubmitTimeout = 10 * time.Millisecond
+	t.Cleanup(func() { backup.SubmitTimeout = timeout })
+
+	_, registry := newBackupService(t, &fakeServer{status: minecraft.Stopped})
+	if _, err := registry.HandleCommand(commands.NewCommand("backup", commands.System)); !errors.Is(err, backup.ErrNotRunning) {
+		t.Errorf("expected ErrNotRunning, got %v", err)
+	}
+}
+
+func TestServiceBackupTransition(t *testing.T) {
+	t.Parallel()
+
+	for _, status := range []minecraft.Status{minecraft.Starting, minecraft.Stopping} {
+		service, _ := newBackupService(t, &fakeServer{status: status})
+		if _, _, err := service.Backup(); !errors.Is(err, backup.ErrTransition) {
+			t.Errorf("%s: expected ErrTransition, got %v", status, err)
+		}
+	}
+}
+
+func TestServiceBackupIDs(t *testing.T) {
+	t.Parallel()
+
+	service, _ := newBackupService(t, &fakeServer{status: minecraft.Stopped})
+	ids := make(map[string]bool)
+	for i := 0; i < 3; i++ {
+		archive, _, err := service.Backup()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ids[archive.ID] {
+			t.Errorf("duplicate archive ID: %s", archive.ID)
+		}
+		ids[archive.ID] = true
+	}
+
+	archives, err := backup.ListArchives(service.AbsDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(archives) != 3 {
+		t.Errorf("expected 3 archives, got %d", len(archives))
+	}
+}
+
+func TestListArchivesSameSecond(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	for _, name := range []string{
+		"backup-20220101-120000.zip",
+		"backup-20220101-120000-2.zip",
+		"backup-20220101-120000-10.tar.zst",
+		"backup-20220101-115959.zip",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	archives, err := backup.ListArchives(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"20220101-120000-10", "20220101-120000-2", "20220101-120000", "20220101-115959"}
+	if len(archives) != len(expected) {
+		t.Fatalf("expected %d archives, got %d", len(expected), len(archives))
+	}
+	for i, id := range expected {
+		if archives[i].ID != id {
+			t.Errorf("#%d: expected %s, got %s", i, id, archives[i].ID)
+		}
+	}
+}
+
+func TestServiceRetentionKeepsPendingUploads(t *testing.T) {
+	t.Parallel()
+
+	serverConfig := minecraft.NewConfig(t.TempDir()).Server
+	if err := os.MkdirAll(filepath.Join(serverConfig.AbsWorkingDir(), "world"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	config := backup.NewConfig(t.TempDir())
+	config.Format = backup.ZipFormat
+	config.Retention = &backup.RetentionConfig{KeepLast: 1}
+	config.Storage = []*backup.StorageConfig{{Name: "local", Type: backup.LocalStorage, Path: "offsite"}}
+	service := backup.NewService(config, serverConfig, &fakeServer{status: minecraft.Stopped}, events.NewDispatcher(), commands.NewRegistry())
+	uploader := service.Uploaders()[0]
+
+	first, _, err := service.Backup()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = uploader.Enqueue(first); err != nil {
+		t.Fatal(err)
+	}
+	if _, removed, err := service.Backup(); err != nil || removed != 0 {
+		t.Fatalf("expected the archive being uploaded to be kept, got %d removed, %v", removed, err)
+	}
+	if _, err = os.Stat(first.Path); err != nil {
+		t.Fatalf("the archive being uploaded was removed: %s", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	go func() { _ = uploader.Serve(ctx) }()
+	for uploader.IsPending(first) {
+		select {
+		case <-ctx.Done():
+			t.Fatal("the archive was not uploaded")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+
+	if _, removed, err := service.Backup(); err != nil || removed != 2 {
+		t.Errorf("expected the 2 older archives to be removed, got %d, %v", removed, err)
+	}
+}
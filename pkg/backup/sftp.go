@@ -0,0 +1,231 @@
+package backup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Adirelle/mcvisor/pkg/utils"
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+type (
+	SFTPConfig struct {
+		Address    string       `json:"address" validate:"required,hostname_port"`
+		User       string       `json:"user" validate:"required"`
+		Password   utils.Secret `json:"password,omitempty"`
+		KeyFile    string       `json:"key_file,omitempty"`
+		KnownHosts string       `json:"known_hosts,omitempty"`
+	}
+
+	sftpStorage struct {
+		*SFTPConfig
+		dir     string
+		baseDir string
+	}
+
+	sftpSession struct {
+		*sftp.Client
+		conn *ssh.Client
+	}
+
+	sftpFile struct {
+		*sftp.File
+		session *sftpSession
+	}
+)
+
+var (
+	// Interface check
+	_ Storage = (*sftpStorage)(nil)
+
+	SFTPDialTimeout = 30 * time.Second
+
+	ErrNoSFTPAuth = errors.New("sftp: a password or a key file is required")
+)
+
+func newSFTPStorage(config *SFTPConfig, dir, baseDir string) *sftpStorage {
+	dir = path.Clean(filepath.ToSlash(dir))
+	if dir == "." {
+		dir = ""
+	}
+	return &sftpStorage{config, dir, baseDir}
+}
+
+func (s *sftpStorage) String() string {
+	return fmt.Sprintf("sftp://%s@%s/%s", s.User, s.Address, strings.TrimPrefix(s.dir, "/"))
+}
+
+func (s *sftpStorage) Put(ctx context.Context, name string, content io.Reader, size int64) error {
+	session, err := s.connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+
+	if s.dir != "" {
+		if err = session.MkdirAll(s.dir); err != nil {
+			return err
+		}
+	}
+	target := s.path(name)
+	tmpPath := target + ".tmp"
+	file, err := session.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+	if _, err = file.ReadFrom(content); err != nil {
+		_ = file.Close()
+		_ = session.Remove(tmpPath)
+		return err
+	}
+	if err = file.Close(); err != nil {
+		_ = session.Remove(tmpPath)
+		return err
+	}
+	if err = session.PosixRename(tmpPath, target); err != nil {
+		_ = session.Remove(target)
+		err = session.Rename(tmpPath, target)
+	}
+	return err
+}
+
+func (s *sftpStorage) Get(ctx context.Context, name string) (io.ReadCloser, error) {
+	session, err := s.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	file, err := session.Open(s.path(name))
+	if err != nil {
+		session.Close()
+		return nil, err
+	}
+	return &sftpFile{file, session}, nil
+}
+
+func (s *sftpStorage) List(ctx context.Context) ([]string, error) {
+	session, err := s.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer session.Close()
+
+	dir := s.dir
+	if dir == "" {
+		dir = "."
+	}
+	entries, err := session.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if entry.Mode().IsRegular() && !strings.HasSuffix(entry.Name(), ".tmp") {
+			names = append(names, entry.Name())
+		}
+	}
+	return names, nil
+}
+
+func (s *sftpStorage) Delete(ctx context.Context, name string) error {
+	session, err := s.connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+	return session.Remove(s.path(name))
+}
+
+func (s *sftpStorage) path(name string) string {
+	return path.Join(s.dir, name)
+}
+
+// connect opens a new SSH connection and starts a SFTP session on it.
+func (s *sftpStorage) connect(ctx context.Context) (*sftpSession, error) {
+	config, err := s.clientConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	dialer := net.Dialer{Timeout: SFTPDialTimeout}
+	netConn, err := dialer.DialContext(ctx, "tcp", s.Address)
+	if err != nil {
+		return nil, err
+	}
+	sshConn, channels, requests, err := ssh.NewClientConn(netConn, s.Address, config)
+	if err != nil {
+		_ = netConn.Close()
+		return nil, err
+	}
+	conn := ssh.NewClient(sshConn, channels, requests)
+
+	client, err := sftp.NewClient(conn)
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return &sftpSession{client, conn}, nil
+}
+
+func (s *sftpStorage) clientConfig() (*ssh.ClientConfig, error) {
+	var auths []ssh.AuthMethod
+	if s.KeyFile != "" {
+		key, err := os.ReadFile(absPath(s.baseDir, s.KeyFile))
+		if err != nil {
+			return nil, err
+		}
+		signer, err := ssh.ParsePrivateKey(key)
+		if err != nil {
+			return nil, fmt.Errorf("invalid key file %s: %w", s.KeyFile, err)
+		}
+		auths = append(auths, ssh.PublicKeys(signer))
+	}
+	if s.Password != "" {
+		auths = append(auths, ssh.Password(s.Password.Reveal()))
+	}
+	if len(auths) == 0 {
+		return nil, ErrNoSFTPAuth
+	}
+
+	knownHostsPath := s.KnownHosts
+	if knownHostsPath == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, err
+		}
+		knownHostsPath = filepath.Join(home, ".ssh", "known_hosts")
+	}
+	hostKeyCallback, err := knownhosts.New(absPath(s.baseDir, knownHostsPath))
+	if err != nil {
+		return nil, err
+	}
+
+	return &ssh.ClientConfig{
+		User:            s.User,
+		Auth:            auths,
+		HostKeyCallback: hostKeyCallback,
+		Timeout:         SFTPDialTimeout,
+	}, nil
+}
+
+func (s *sftpSession) Close() {
+	_ = s.Client.Close()
+	_ = s.conn.Close()
+}
+
+func (f *sftpFile) Close() error {
+	err := f.File.Close()
+	f.session.Close()
+	return err
+}
@@ -0,0 +1,198 @@
+//go:build !windows
+
+package backup_test
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Adirelle/mcvisor/pkg/backup"
+	"github.com/Adirelle/mcvisor/pkg/events"
+	"github.com/Adirelle/mcvisor/pkg/utils"
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// startSFTPServer runs an in-process SFTP server accepting the given password, and returns its address and a
+// known_hosts file that trusts it.
+func startSFTPServer(t *testing.T, password string) (address, knownHostsPath string) {
+	t.Helper()
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hostKey, err := ssh.NewSignerFromKey(privateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &ssh.ServerConfig{
+		PasswordCallback: func(meta ssh.ConnMetadata, given []byte) (*ssh.Permissions, error) {
+			if meta.User() == "backup" && string(given) == password {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("access denied for %s", meta.User())
+		},
+	}
+	config.AddHostKey(hostKey)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serveSFTP(conn, config)
+		}
+	}()
+
+	address = listener.Addr().String()
+	knownHostsPath = filepath.Join(t.TempDir(), "known_hosts")
+	line := knownhosts.Line([]string{knownhosts.Normalize(address)}, hostKey.PublicKey())
+	if err := os.WriteFile(knownHostsPath, []byte(line+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return
+}
+
+func serveSFTP(netConn net.Conn, config *ssh.ServerConfig) {
+	defer netConn.Close()
+	conn, channels, requests, err := ssh.NewServerConn(netConn, config)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	go ssh.DiscardRequests(requests)
+	for newChannel := range channels {
+		if newChannel.ChannelType() != "session" {
+			_ = newChannel.Reject(ssh.UnknownChannelType, "unsupported channel type")
+			continue
+		}
+		channel, channelRequests, err := newChannel.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			for req := range channelRequests {
+				isSFTP := req.Type == "subsystem" && len(req.Payload) > 4 && string(req.Payload[4:]) == "sftp"
+				_ = req.Reply(isSFTP, nil)
+				if !isSFTP {
+					continue
+				}
+				server, err := sftp.NewServer(channel)
+				if err != nil {
+					_ = channel.Close()
+					return
+				}
+				_ = server.Serve()
+				_ = channel.Close()
+			}
+		}()
+	}
+}
+
+func newSFTPStorage(t *testing.T, password string) (storage backup.Storage, dir string) {
+	t.Helper()
+	address, knownHostsPath := startSFTPServer(t, "secret")
+	dir = filepath.Join(t.TempDir(), "offsite")
+	storage = backup.NewStorage(&backup.StorageConfig{
+		Name: "sftp",
+		Type: backup.SFTPStorage,
+		Path: dir,
+		SFTP: &backup.SFTPConfig{
+			Address:    address,
+			User:       "backup",
+			Password:   utils.Secret(password),
+			KnownHosts: knownHostsPath,
+		},
+	}, t.TempDir())
+	return
+}
+
+func TestSFTPStorage(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	storage, dir := newSFTPStorage(t, "secret")
+
+	names, err := storage.List(ctx)
+	if err != nil || len(names) != 0 {
+		t.Fatalf("expected an empty listing, got %v, %v", names, err)
+	}
+
+	for _, name := range []string{"a.zip", "b.zip"} {
+		if err := storage.Put(ctx, name, strings.NewReader("content of "+name), int64(len("content of "+name))); err != nil {
+			t.Fatalf("could not put %s: %s", name, err)
+		}
+	}
+	if err = os.WriteFile(filepath.Join(dir, "c.zip.tmp"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	names, err = storage.List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(names)
+	if fmt.Sprint(names) != "[a.zip b.zip]" {
+		t.Errorf("unexpected names: %v", names)
+	}
+
+	reader, err := storage.Get(ctx, "b.zip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, _ := io.ReadAll(reader)
+	_ = reader.Close()
+	if string(content) != "content of b.zip" {
+		t.Errorf("unexpected content: %q", content)
+	}
+
+	if err = storage.Delete(ctx, "b.zip"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = os.Stat(filepath.Join(dir, "b.zip")); !os.IsNotExist(err) {
+		t.Errorf("expected b.zip to be deleted, got %v", err)
+	}
+}
+
+func TestSFTPStorageUpload(t *testing.T) {
+	t.Parallel()
+
+	storage, dir := newSFTPStorage(t, "secret")
+	archive := makeArchive(t, t.TempDir(), time.Now())
+	uploader := backup.NewUploader("sftp", storage, &backup.RetentionConfig{}, backup.NewUploadConfig(), events.NewDispatcher())
+
+	if err := uploader.Upload(context.Background(), archive); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	for _, path := range []string{archive.Path, archive.ChecksumPath()} {
+		if _, err := os.Stat(filepath.Join(dir, filepath.Base(path))); err != nil {
+			t.Errorf("expected %s to be uploaded: %s", filepath.Base(path), err)
+		}
+	}
+}
+
+func TestSFTPStorageWrongPassword(t *testing.T) {
+	t.Parallel()
+
+	storage, _ := newSFTPStorage(t, "wrong")
+	if _, err := storage.List(context.Background()); err == nil || !strings.Contains(err.Error(), "unable to authenticate") {
+		t.Errorf("expected an authentication error, got %v", err)
+	}
+}
@@ -0,0 +1,124 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+type (
+	// Storage is a place where archives are copied to, so they survive the loss of the server disk.
+	Storage interface {
+		fmt.Stringer
+		// Put stores the content under the given name, replacing any existing file.
+		Put(ctx context.Context, name string, content io.Reader, size int64) error
+		// Get opens a stored file.
+		Get(ctx context.Context, name string) (io.ReadCloser, error)
+		// List returns the names of the stored files.
+		List(ctx context.Context) ([]string, error)
+		// Delete removes a stored file.
+		Delete(ctx context.Context, name string) error
+	}
+
+	StorageConfig struct {
+		Name      string           `json:"name" validate:"required"`
+		Type      StorageType      `json:"type" validate:"oneof=local sftp s3"`
+		Path      string           `json:"path"`
+		SFTP      *SFTPConfig      `json:"sftp,omitempty" validate:"required_if=Type sftp"`
+		S3        *S3Config        `json:"s3,omitempty" validate:"required_if=Type s3"`
+		Retention *RetentionConfig `json:"retention,omitempty"`
+	}
+
+	StorageType string
+
+	localStorage struct {
+		dir string
+	}
+)
+
+const (
+	LocalStorage StorageType = "local"
+	SFTPStorage  StorageType = "sftp"
+	S3Storage    StorageType = "s3"
+)
+
+var (
+	// Interface check
+	_ Storage = (*localStorage)(nil)
+)
+
+// NewStorage creates the storage described by the configuration.
+// Relative local paths are resolved from baseDir.
+func NewStorage(config *StorageConfig, baseDir string) Storage {
+	switch config.Type {
+	case SFTPStorage:
+		return newSFTPStorage(config.SFTP, config.Path, baseDir)
+	case S3Storage:
+		return newS3Storage(config.S3, config.Path)
+	default:
+		return &localStorage{absPath(baseDir, config.Path)}
+	}
+}
+
+func absPath(baseDir, path string) string {
+	if filepath.IsAbs(path) {
+		return filepath.Clean(path)
+	}
+	return filepath.Join(baseDir, path)
+}
+
+func (s *localStorage) String() string {
+	return s.dir
+}
+
+func (s *localStorage) Put(ctx context.Context, name string, content io.Reader, size int64) (err error) {
+	if err = os.MkdirAll(s.dir, 0o755); err != nil {
+		return
+	}
+	path := filepath.Join(s.dir, name)
+	tmpPath := path + ".tmp"
+	file, err := os.Create(tmpPath)
+	if err != nil {
+		return
+	}
+	defer func() {
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err == nil {
+			err = os.Rename(tmpPath, path)
+		}
+		if err != nil {
+			_ = os.Remove(tmpPath)
+		}
+	}()
+	_, err = io.Copy(file, content)
+	return
+}
+
+func (s *localStorage) Get(ctx context.Context, name string) (io.ReadCloser, error) {
+	return os.Open(filepath.Join(s.dir, name))
+}
+
+func (s *localStorage) List(ctx context.Context) ([]string, error) {
+	entries, err := os.ReadDir(s.dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if !entry.IsDir() && !strings.HasSuffix(entry.Name(), ".tmp") {
+			names = append(names, entry.Name())
+		}
+	}
+	return names, nil
+}
+
+func (s *localStorage) Delete(ctx context.Context, name string) error {
+	return os.Remove(filepath.Join(s.dir, name))
+}
@@ -0,0 +1,256 @@
+package backup
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/Adirelle/mcvisor/pkg/discord"
+	"github.com/Adirelle/mcvisor/pkg/events"
+	"github.com/apex/log"
+	"github.com/thejerf/suture/v4"
+)
+
+type (
+	UploadConfig struct {
+		Retries    uint          `json:"retries"`
+		MinBackoff time.Duration `json:"min_backoff"`
+		MaxBackoff time.Duration `json:"max_backoff"`
+	}
+
+	// Uploader copies the new archives to a storage, one at a time, and applies the retention rules of the storage.
+	Uploader struct {
+		*UploadConfig
+		Name       string
+		storage    Storage
+		retention  *RetentionConfig
+		dispatcher *events.Dispatcher
+		queue      chan *Archive
+
+		mu      sync.Mutex
+		pending map[string]int
+	}
+
+	UploadFailed struct {
+		Storage  string
+		Archive  string
+		Attempts uint
+		Reason   error
+	}
+)
+
+var (
+	// Interface checks
+	_ suture.Service       = (*Uploader)(nil)
+	_ discord.Notification = (*UploadFailed)(nil)
+
+	ErrChecksumMismatch = errors.New("checksum mismatch")
+	ErrUploadQueueFull  = errors.New("upload queue is full")
+)
+
+func NewUploadConfig() *UploadConfig {
+	return &UploadConfig{
+		Retries:    5,
+		MinBackoff: 30 * time.Second,
+		MaxBackoff: 30 * time.Minute,
+	}
+}
+
+// Backoff returns the delay to wait before the given retry, starting at 0.
+func (c *UploadConfig) Backoff(retry uint) time.Duration {
+	delay := c.MinBackoff
+	for i := uint(0); i < retry && delay < c.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if c.MaxBackoff > 0 && delay > c.MaxBackoff {
+		delay = c.MaxBackoff
+	}
+	return delay
+}
+
+func NewUploader(name string, storage Storage, retention *RetentionConfig, config *UploadConfig, dispatcher *events.Dispatcher) *Uploader {
+	return &Uploader{
+		UploadConfig: config,
+		Name:         name,
+		storage:      storage,
+		retention:    retention,
+		dispatcher:   dispatcher,
+		queue:        events.MakeHandler[*Archive](),
+		pending:      make(map[string]int),
+	}
+}
+
+func (u *Uploader) String() string {
+	return fmt.Sprintf("Uploader(%s)", u.Name)
+}
+
+// Enqueue schedules the upload of the archive. The archive is pending until its upload succeeds or is given up.
+func (u *Uploader) Enqueue(archive *Archive) error {
+	u.setPending(archive, 1)
+	select {
+	case u.queue <- archive:
+		return nil
+	default:
+		u.setPending(archive, -1)
+		return ErrUploadQueueFull
+	}
+}
+
+// IsPending returns whether the archive is queued or being uploaded, in which case it must not be deleted.
+func (u *Uploader) IsPending(archive *Archive) bool {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.pending[archive.ID] > 0
+}
+
+func (u *Uploader) setPending(archive *Archive, delta int) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if count := u.pending[archive.ID] + delta; count > 0 {
+		u.pending[archive.ID] = count
+	} else {
+		delete(u.pending, archive.ID)
+	}
+}
+
+// Serve uploads the queued archives, retrying failed uploads with an exponential backoff.
+func (u *Uploader) Serve(ctx context.Context) error {
+	for {
+		select {
+		case archive := <-u.queue:
+			uploaded := u.uploadWithRetries(ctx, archive)
+			u.setPending(archive, -1)
+			if !uploaded {
+				continue
+			}
+			if removed, err := u.ApplyRetention(ctx); err != nil {
+				log.WithError(err).WithField("storage", u.Name).Error("backup.upload.retention")
+			} else if removed > 0 {
+				log.WithField("storage", u.Name).WithField("removed", removed).Info("backup.upload.retention")
+			}
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+func (u *Uploader) uploadWithRetries(ctx context.Context, archive *Archive) bool {
+	logger := log.WithField("storage", u.Name).WithField("archive", archive.ID)
+	for attempt := uint(0); ; attempt++ {
+		start := time.Now()
+		err := u.Upload(ctx, archive)
+		if err == nil {
+			logger.WithField("duration", time.Since(start)).Info("backup.upload.done")
+			return true
+		}
+		if ctx.Err() != nil {
+			return false
+		}
+		if attempt >= u.Retries {
+			failure := &UploadFailed{u.Name, archive.ID, attempt + 1, err}
+			logger.WithFields(failure).Error("backup.upload.failed")
+			u.dispatcher.Dispatch(failure)
+			return false
+		}
+		delay := u.Backoff(attempt)
+		logger.WithError(err).WithField("delay", delay).Warn("backup.upload.retry")
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return false
+		}
+	}
+}
+
+// Upload copies the archive and its checksum file to the storage, then reads it back to check its integrity.
+// A corrupted copy is deleted.
+func (u *Uploader) Upload(ctx context.Context, archive *Archive) error {
+	checksum, err := archive.RecordedChecksum()
+	if err != nil {
+		if checksum, err = archive.Checksum(); err != nil {
+			return err
+		}
+	}
+
+	name := filepath.Base(archive.Path)
+	if err = u.putFile(ctx, name, archive.Path); err != nil {
+		return err
+	}
+	if err = u.putFile(ctx, name+checksumSuffix, archive.ChecksumPath()); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	remoteChecksum, err := u.remoteChecksum(ctx, name)
+	if err != nil {
+		return fmt.Errorf("could not read back %s: %w", name, err)
+	}
+	if remoteChecksum != checksum {
+		_ = u.storage.Delete(ctx, name)
+		_ = u.storage.Delete(ctx, name+checksumSuffix)
+		return fmt.Errorf("%w: %s", ErrChecksumMismatch, name)
+	}
+	return nil
+}
+
+func (u *Uploader) putFile(ctx context.Context, name, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	return u.storage.Put(ctx, name, file, info.Size())
+}
+
+func (u *Uploader) remoteChecksum(ctx context.Context, name string) (string, error) {
+	content, err := u.storage.Get(ctx, name)
+	if err != nil {
+		return "", err
+	}
+	defer content.Close()
+	hash := sha256.New()
+	if _, err = io.Copy(hash, content); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// ApplyRetention deletes the stored archives that are not kept by the retention rules of the storage.
+func (u *Uploader) ApplyRetention(ctx context.Context) (removed int, err error) {
+	names, err := u.storage.List(ctx)
+	if err != nil {
+		return
+	}
+	archives := parseArchiveNames(names)
+	for _, archive := range u.retention.Expired(archives) {
+		if err = u.storage.Delete(ctx, archive.Path); err != nil {
+			return
+		}
+		_ = u.storage.Delete(ctx, archive.ChecksumPath())
+		removed++
+	}
+	return
+}
+
+func (e *UploadFailed) Fields() log.Fields {
+	return log.Fields{
+		"storage":  e.Storage,
+		"archive":  e.Archive,
+		"attempts": e.Attempts,
+		"error":    e.Reason,
+	}
+}
+
+func (e *UploadFailed) DiscordNotification() string {
+	return fmt.Sprintf("**Could not upload backup `%s` to %s** after %d attempt(s): %s", e.Archive, e.Storage, e.Attempts, e.Reason)
+}
@@ -0,0 +1,154 @@
+package backup_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/Adirelle/mcvisor/pkg/backup"
+	"github.com/Adirelle/mcvisor/pkg/events"
+)
+
+type corruptingStorage struct {
+	backup.Storage
+}
+
+func (s corruptingStorage) Put(ctx context.Context, name string, content io.Reader, size int64) error {
+	data, err := io.ReadAll(content)
+	if err != nil {
+		return err
+	}
+	data[0] ^= 0xff
+	return s.Storage.Put(ctx, name, bytes.NewReader(data), size)
+}
+
+func makeArchive(t *testing.T, dir string, when time.Time) *backup.Archive {
+	t.Helper()
+	id := when.Format("20060102-150405")
+	path := filepath.Join(dir, fmt.Sprintf("backup-%s.zip", id))
+	content := []byte("archive " + id)
+	if err := os.WriteFile(path, content, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(content)
+	if err := os.WriteFile(path+".sha256", []byte(hex.EncodeToString(sum[:])+"  "+filepath.Base(path)+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return &backup.Archive{ID: id, Path: path, Format: backup.ZipFormat, Time: when, Size: int64(len(content))}
+}
+
+func newLocalStorage(t *testing.T) backup.Storage {
+	t.Helper()
+	return backup.NewStorage(&backup.StorageConfig{Name: "local", Type: backup.LocalStorage, Path: "offsite"}, t.TempDir())
+}
+
+func TestUploaderUpload(t *testing.T) {
+	t.Parallel()
+
+	storage := newLocalStorage(t)
+	archive := makeArchive(t, t.TempDir(), time.Now())
+	uploader := backup.NewUploader("local", storage, &backup.RetentionConfig{}, backup.NewUploadConfig(), events.NewDispatcher())
+
+	if err := uploader.Upload(context.Background(), archive); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	names, err := storage.List(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(names)
+	expected := []string{filepath.Base(archive.Path), filepath.Base(archive.ChecksumPath())}
+	if fmt.Sprint(names) != fmt.Sprint(expected) {
+		t.Errorf("expected %v, got %v", expected, names)
+	}
+
+	stored, err := storage.Get(context.Background(), filepath.Base(archive.Path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stored.Close()
+	content, _ := io.ReadAll(stored)
+	original, _ := os.ReadFile(archive.Path)
+	if !bytes.Equal(content, original) {
+		t.Errorf("stored content differs from the archive")
+	}
+}
+
+func TestUploaderUploadCorrupted(t *testing.T) {
+	t.Parallel()
+
+	storage := newLocalStorage(t)
+	archive := makeArchive(t, t.TempDir(), time.Now())
+	uploader := backup.NewUploader("local", corruptingStorage{storage}, &backup.RetentionConfig{}, backup.NewUploadConfig(), events.NewDispatcher())
+
+	err := uploader.Upload(context.Background(), archive)
+	if !errors.Is(err, backup.ErrChecksumMismatch) {
+		t.Fatalf("expected a checksum mismatch, got %v", err)
+	}
+
+	names, err := storage.List(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 0 {
+		t.Errorf("corrupted copies should have been deleted, found %v", names)
+	}
+}
+
+func TestUploaderApplyRetention(t *testing.T) {
+	t.Parallel()
+
+	storage := newLocalStorage(t)
+	dir := t.TempDir()
+	uploader := backup.NewUploader("local", storage, &backup.RetentionConfig{KeepLast: 2}, backup.NewUploadConfig(), events.NewDispatcher())
+
+	start := time.Date(2022, 6, 1, 12, 0, 0, 0, time.Local)
+	for i := 0; i < 4; i++ {
+		if err := uploader.Upload(context.Background(), makeArchive(t, dir, start.Add(time.Duration(i)*time.Hour))); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	removed, err := uploader.ApplyRetention(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if removed != 2 {
+		t.Errorf("expected 2 archives to be removed, got %d", removed)
+	}
+
+	names, err := storage.List(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(names)
+	expected := []string{
+		"backup-20220601-140000.zip", "backup-20220601-140000.zip.sha256",
+		"backup-20220601-150000.zip", "backup-20220601-150000.zip.sha256",
+	}
+	if fmt.Sprint(names) != fmt.Sprint(expected) {
+		t.Errorf("expected %v, got %v", expected, names)
+	}
+}
+
+func TestUploadConfigBackoff(t *testing.T) {
+	t.Parallel()
+
+	config := &backup.UploadConfig{MinBackoff: time.Second, MaxBackoff: 10 * time.Second}
+	expected := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 10 * time.Second, 10 * time.Second}
+	for retry, delay := range expected {
+		if actual := config.Backoff(uint(retry)); actual != delay {
+			t.Errorf("retry #%d: expected %s, got %s", retry, delay, actual)
+		}
+	}
+}
@@ -1,5 +1,11 @@
 package commands
 
+import (
+	"fmt"
+
+	"github.com/apex/log"
+)
+
 type (
 	Permission interface{}
 
@@ -8,6 +14,36 @@ type (
 	}
 
 	all string
+
+	system struct{}
+)
+
+var (
+	AllowAll Permission = all("allow")
+
+	// System is the actor used for commands issued by mcvisor itself; it has all permissions.
+	System Actor = system{}
+
+	// Interface check
+	_ log.Fielder = system{}
 )
 
-var AllowAll Permission = all("allow")
+// ActorName describes the actor of a command, e.g. to record who did something.
+func ActorName(actor Actor) string {
+	if stringer, ok := actor.(fmt.Stringer); ok {
+		return stringer.String()
+	}
+	return "unknown actor"
+}
+
+func (system) HasPermission(Permission) bool {
+	return true
+}
+
+func (system) Fields() log.Fields {
+	return log.Fields{"actor": "system"}
+}
+
+func (system) String() string {
+	return "mcvisor"
+}
@@ -3,6 +3,7 @@ package commands
 import (
 	"errors"
 	"fmt"
+	"sort"
 	"strings"
 	"sync"
 )
@@ -43,6 +44,22 @@ func (r *Registry) RegisterDefinition(def Definition) {
 	}
 }
 
+// IsRegistered returns whether a command with the given name exists.
+func (r *Registry) IsRegistered(name Name) bool {
+	_, found := r.definitions[name]
+	return found
+}
+
+// Definitions returns the registered commands, sorted by name.
+func (r *Registry) Definitions() []*Definition {
+	defs := make([]*Definition, 0, len(r.definitions))
+	for _, def := range r.definitions {
+		defs = append(defs, def)
+	}
+	sort.Slice(defs, func(i, j int) bool { return defs[i].Name < defs[j].Name })
+	return defs
+}
+
 func (r *Registry) HandleCommand(cmd *Command) (string, error) {
 	def, found := r.definitions[cmd.Name]
 	switch {
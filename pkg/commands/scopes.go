@@ -0,0 +1,126 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+type (
+	// Scopes routes commands from a parent registry to named child registries, e.g. one per server.
+	// The scope is selected by the first argument of the command ("start survival"); "all" selects every scope.
+	// The name can be omitted when there is only one scope.
+	Scopes struct {
+		parent     *Registry
+		registries map[string]*Registry
+		names      []string
+	}
+)
+
+const (
+	AllScopes = "all"
+)
+
+var (
+	ErrUnknownScope  = errors.New("unknown server")
+	ErrMissingScope  = errors.New("please specify a server")
+	ErrScopeConflict = errors.New("command already exists outside of the servers")
+)
+
+func NewScopes(parent *Registry) *Scopes {
+	return &Scopes{parent: parent, registries: make(map[string]*Registry)}
+}
+
+// Add creates the registry of a new scope.
+func (s *Scopes) Add(name string) *Registry {
+	registry := NewRegistry()
+	s.registries[name] = registry
+	s.names = append(s.names, name)
+	sort.Strings(s.names)
+	return registry
+}
+
+// Names returns the scope names, sorted.
+func (s *Scopes) Names() []string {
+	return s.names
+}
+
+// IsMultiple returns whether there are several scopes, in which case their names must be given.
+func (s *Scopes) IsMultiple() bool {
+	return len(s.names) > 1
+}
+
+// Mount registers in the parent registry a routing command for each command of the scopes.
+// It must be called once all the commands of the scopes have been registered. It fails if a command of the scopes
+// already exists in the parent registry, except for the help command that every registry has.
+func (s *Scopes) Mount() error {
+	mounted := make(map[Name]bool)
+	for _, name := range s.names {
+		for _, def := range s.registries[name].Definitions() {
+			if mounted[def.Name] || def.Name == HelpCommand {
+				continue
+			}
+			if s.parent.IsRegistered(def.Name) {
+				return fmt.Errorf("server %s: %w: %s", name, ErrScopeConflict, def.Name)
+			}
+			mounted[def.Name] = true
+			description := def.Description
+			if s.IsMultiple() {
+				description = fmt.Sprintf("%s (`%s <server|all> ...`)", description, def.Name)
+			}
+			s.parent.Register(def.Name, description, def.Permission, s.router())
+		}
+	}
+	return nil
+}
+
+func (s *Scopes) router() Handler {
+	return HandlerFunc(func(cmd *Command) (string, error) {
+		names, args, err := s.resolve(cmd.Arguments)
+		if err != nil {
+			return "", err
+		}
+		if len(names) == 1 {
+			return s.registries[names[0]].HandleCommand(&Command{cmd.Name, args, cmd.Actor})
+		}
+
+		replies := make([]string, 0, len(names))
+		var errs []string
+		for _, name := range names {
+			reply, err := s.registries[name].HandleCommand(&Command{cmd.Name, args, cmd.Actor})
+			switch {
+			case err == ErrUnknownCommand:
+				continue
+			case err != nil:
+				errs = append(errs, fmt.Sprintf("%s: %s", name, err))
+			}
+			if reply != "" {
+				replies = append(replies, fmt.Sprintf("**%s**: %s", name, reply))
+			}
+		}
+		if len(errs) > 0 {
+			err = errors.New(strings.Join(errs, ", "))
+		}
+		return strings.Join(replies, "\n"), err
+	})
+}
+
+// resolve extracts the scope names from the arguments.
+func (s *Scopes) resolve(args []string) (names []string, rest []string, err error) {
+	if len(args) > 0 {
+		if args[0] == AllScopes {
+			return s.names, args[1:], nil
+		}
+		if _, found := s.registries[args[0]]; found {
+			return args[:1], args[1:], nil
+		}
+	}
+	if !s.IsMultiple() {
+		return s.names, args, nil
+	}
+	if len(args) > 0 && args[0] != "" {
+		return nil, nil, fmt.Errorf("%w: %q, expected one of: %s, %s", ErrUnknownScope, args[0], strings.Join(s.names, ", "), AllScopes)
+	}
+	return nil, nil, fmt.Errorf("%w: %s, %s", ErrMissingScope, strings.Join(s.names, ", "), AllScopes)
+}
@@ -0,0 +1,81 @@
+package commands_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Adirelle/mcvisor/pkg/commands"
+)
+
+func echo(name string) commands.Handler {
+	return commands.HandlerFunc(func(cmd *commands.Command) (string, error) {
+		return name + " " + cmd.String(), nil
+	})
+}
+
+func TestScopes(t *testing.T) {
+	t.Parallel()
+
+	parent := commands.NewRegistry()
+	scopes := commands.NewScopes(parent)
+	scopes.Add("survival").Register("start", "start", commands.AllowAll, echo("survival"))
+	scopes.Add("creative").Register("start", "start", commands.AllowAll, echo("creative"))
+	if err := scopes.Mount(); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string]string{
+		"start survival":     "survival start",
+		"start creative now": "creative start now",
+		"start all":          "**creative**: creative start\n**survival**: survival start",
+	}
+	for line, expected := range cases {
+		reply, err := parent.HandleCommand(commands.NewCommand(line, commands.System))
+		if err != nil {
+			t.Errorf("%q: unexpected error: %s", line, err)
+		} else if reply != expected {
+			t.Errorf("%q: expected %q, got %q", line, expected, reply)
+		}
+	}
+
+	if _, err := parent.HandleCommand(commands.NewCommand("start", commands.System)); !errors.Is(err, commands.ErrMissingScope) {
+		t.Errorf("expected ErrMissingScope, got %v", err)
+	}
+	if _, err := parent.HandleCommand(commands.NewCommand("start hardcore", commands.System)); !errors.Is(err, commands.ErrUnknownScope) {
+		t.Errorf("expected ErrUnknownScope, got %v", err)
+	}
+}
+
+func TestSingleScope(t *testing.T) {
+	t.Parallel()
+
+	parent := commands.NewRegistry()
+	scopes := commands.NewScopes(parent)
+	scopes.Add("default").Register("start", "start", commands.AllowAll, echo("default"))
+	if err := scopes.Mount(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, line := range []string{"start", "start default"} {
+		reply, err := parent.HandleCommand(commands.NewCommand(line, commands.System))
+		if err != nil || reply != "default start" {
+			t.Errorf("%q: unexpected reply %q, %v", line, reply, err)
+		}
+	}
+}
+
+func TestScopesConflict(t *testing.T) {
+	t.Parallel()
+
+	parent := commands.NewRegistry()
+	parent.Register("perms", "perms", commands.AllowAll, echo("global"))
+	scopes := commands.NewScopes(parent)
+	scopes.Add("default").Register("perms", "perms", commands.AllowAll, echo("default"))
+
+	if err := scopes.Mount(); !errors.Is(err, commands.ErrScopeConflict) {
+		t.Errorf("expected ErrScopeConflict, got %v", err)
+	}
+	if reply, err := parent.HandleCommand(commands.NewCommand("perms", commands.System)); err != nil || reply != "global perms" {
+		t.Errorf("expected the global command to be kept, got %q, %v", reply, err)
+	}
+}
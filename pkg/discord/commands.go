@@ -20,6 +20,7 @@ func (b *Bot) HandleMessage(message *discordgo.Message, ctx context.Context) {
 
 	actor := &actor{
 		UserID:      message.Author.ID,
+		UserName:    message.Author.String(),
 		ChannelID:   message.ChannelID,
 		Permissions: b.Permissions,
 	}
@@ -34,11 +35,16 @@ func (b *Bot) HandleMessage(message *discordgo.Message, ctx context.Context) {
 			logger.WithField("reply", reply).Debug("discord.command.reply")
 		} else {
 			logger.WithError(err).Warn("discord.command.reply")
-			reply = fmt.Sprintf("**%s**", err.Error())
-
+			if reply == "" {
+				reply = fmt.Sprintf("**%s**", err.Error())
+			} else {
+				reply = fmt.Sprintf("%s\n**%s**", reply, err.Error())
+			}
+		}
+		for _, chunk := range SplitMessage(reply, MessageLimit) {
+			_, _ = b.Session.ChannelMessageSendComplex(
+				message.ChannelID,
+				&discordgo.MessageSend{Content: chunk, Reference: message.Reference()})
 		}
-		_, _ = b.Session.ChannelMessageSendComplex(
-			message.ChannelID,
-			&discordgo.MessageSend{Content: reply, Reference: message.Reference()})
 	}()
 }
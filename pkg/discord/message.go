@@ -0,0 +1,90 @@
+package discord
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// MessageLimit is the maximum number of characters of a Discord message.
+	MessageLimit = 2000
+
+	codeFence       = "```"
+	maxFenceLength  = 16
+	chunkingReserve = 2 * maxFenceLength
+)
+
+// SplitMessage splits the content into chunks that fit in Discord messages, cutting between lines when possible.
+// Code blocks that span several chunks are closed and reopened.
+func SplitMessage(content string, limit int) []string {
+	if content == "" {
+		return nil
+	}
+	if utf8.RuneCountInString(content) <= limit {
+		return []string{content}
+	}
+
+	var chunks, current []string
+	size := 0
+	fence := ""
+
+	flush := func() {
+		chunk := strings.Join(current, "\n")
+		if fence != "" {
+			chunk += "\n" + codeFence
+		}
+		chunks = append(chunks, chunk)
+		current, size = nil, 0
+		if fence != "" {
+			current, size = []string{fence}, utf8.RuneCountInString(fence)
+		}
+	}
+
+	for _, line := range strings.Split(content, "\n") {
+		for _, piece := range splitRunes(line, limit-chunkingReserve) {
+			length := utf8.RuneCountInString(piece)
+			if len(current) > 0 {
+				length++
+			}
+			if fence != "" {
+				length += 1 + len(codeFence)
+			}
+			if size+length > limit {
+				flush()
+			}
+			current = append(current, piece)
+			size += utf8.RuneCountInString(piece)
+			if len(current) > 1 {
+				size++
+			}
+		}
+
+		switch {
+		case !strings.HasPrefix(line, codeFence):
+		case fence != "":
+			fence = ""
+		case utf8.RuneCountInString(line) <= maxFenceLength:
+			fence = line
+		default:
+			fence = codeFence
+		}
+	}
+
+	if len(current) > 0 {
+		chunks = append(chunks, strings.Join(current, "\n"))
+	}
+	return chunks
+}
+
+func splitRunes(line string, size int) []string {
+	runes := []rune(line)
+	if len(runes) <= size {
+		return []string{line}
+	}
+	pieces := make([]string, 0, len(runes)/size+1)
+	for len(runes) > size {
+		pieces = append(pieces, string(runes[:size]))
+		runes = runes[size:]
+	}
+	return append(pieces, string(runes))
+}
@@ -0,0 +1,62 @@
+package discord_test
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/Adirelle/mcvisor/pkg/discord"
+)
+
+func TestSplitMessageShort(t *testing.T) {
+	t.Parallel()
+
+	if chunks := discord.SplitMessage("", 100); len(chunks) != 0 {
+		t.Errorf("expected no chunk, got %q", chunks)
+	}
+	if chunks := discord.SplitMessage("hello", 100); len(chunks) != 1 || chunks[0] != "hello" {
+		t.Errorf("expected a single chunk, got %q", chunks)
+	}
+}
+
+func TestSplitMessageCodeBlock(t *testing.T) {
+	t.Parallel()
+
+	lines := make([]string, 50)
+	for i := range lines {
+		lines[i] = strings.Repeat("x", 20)
+	}
+	content := "Output:\n```\n" + strings.Join(lines, "\n") + "\n```"
+
+	chunks := discord.SplitMessage(content, 200)
+	if len(chunks) < 2 {
+		t.Fatalf("expected several chunks, got %d", len(chunks))
+	}
+	for i, chunk := range chunks {
+		if l := utf8.RuneCountInString(chunk); l > 200 {
+			t.Errorf("chunk %d is too long: %d", i, l)
+		}
+		if n := strings.Count(chunk, "```"); n != 2 {
+			t.Errorf("chunk %d should contain a complete code block: %q", i, chunk)
+		}
+	}
+	if joined := strings.Join(chunks, ""); strings.Count(joined, "xxxxxxxxxxxxxxxxxxxx") != 50 {
+		t.Errorf("some lines were lost")
+	}
+}
+
+func TestSplitMessageLongLine(t *testing.T) {
+	t.Parallel()
+
+	chunks := discord.SplitMessage(strings.Repeat("é", 500), 100)
+	if len(chunks) < 5 {
+		t.Fatalf("expected at least 5 chunks, got %d", len(chunks))
+	}
+	total := 0
+	for _, chunk := range chunks {
+		total += utf8.RuneCountInString(strings.ReplaceAll(chunk, "\n", ""))
+	}
+	if total != 500 {
+		t.Errorf("expected 500 characters, got %d", total)
+	}
+}
@@ -21,10 +21,12 @@ func (b *Bot) HandleNotification(notification Notification) {
 
 	for _, channelID := range b.Notifications {
 		loggerC := logger.WithField("channel", channelID)
-		if _, err := b.Session.ChannelMessageSend(string(channelID), message); err == nil {
-			loggerC.Debug("discord.notification")
-		} else {
-			loggerC.WithError(err).Warn("discord.notification")
+		for _, chunk := range SplitMessage(message, MessageLimit) {
+			if _, err := b.Session.ChannelMessageSend(string(channelID), chunk); err == nil {
+				loggerC.Debug("discord.notification")
+			} else {
+				loggerC.WithError(err).Warn("discord.notification")
+			}
 		}
 	}
 }
@@ -1,6 +1,8 @@
 package discord
 
 import (
+	"fmt"
+
 	"github.com/Adirelle/mcvisor/pkg/commands"
 	"github.com/apex/log"
 	"golang.org/x/exp/slices"
@@ -26,6 +28,7 @@ type (
 
 	actor struct {
 		UserID    string
+		UserName  string
 		ChannelID string
 		RoleIDs   []string
 		*Permissions
@@ -40,11 +43,36 @@ var (
 	ControlCategory commands.Permission = category(2)
 	AdminCategory   commands.Permission = category(3)
 
+	categoryNames = map[string]commands.Permission{
+		"public":  PublicCategory,
+		"query":   QueryCategory,
+		"control": ControlCategory,
+		"admin":   AdminCategory,
+	}
+
 	// Interface checks
 	_ commands.Actor = (*actor)(nil)
 	_ log.Fielder    = (*actor)(nil)
 )
 
+// ParseCategory returns the permission category with the given name, as used in the configuration.
+func ParseCategory(name string) (commands.Permission, error) {
+	if category, found := categoryNames[name]; found {
+		return category, nil
+	}
+	return nil, fmt.Errorf("unknown permission category: %q", name)
+}
+
+// CategoryIncludes returns whether the granted category gives access to the commands of the required one.
+func CategoryIncludes(granted, required commands.Permission) bool {
+	if required == commands.AllowAll {
+		return true
+	}
+	grantedCat, ok1 := granted.(category)
+	requiredCat, ok2 := required.(category)
+	return ok1 && ok2 && grantedCat >= requiredCat
+}
+
 func (p *Permissions) IsAllowed(category category, actor *actor) bool {
 	for _, list := range p.AsList()[category:] {
 		if list.IsAllowed(actor) {
@@ -81,6 +109,10 @@ func (a *actor) HasPermission(permission commands.Permission) bool {
 	return permission == commands.AllowAll || (ok && a.Permissions.IsAllowed(cat, a))
 }
 
+func (a *actor) String() string {
+	return fmt.Sprintf("Discord user %s (%s)", a.UserName, a.UserID)
+}
+
 func (a *actor) Fields() log.Fields {
 	return log.Fields{
 		"userId":    a.UserID,
@@ -0,0 +1,133 @@
+package discord
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/Adirelle/mcvisor/pkg/events"
+	"github.com/apex/log"
+	"github.com/thejerf/suture/v4"
+)
+
+type (
+	// Relay forwards the notifications and the statuses of a server from its own dispatcher to the one of the bot.
+	Relay struct {
+		name          string
+		from          *events.Dispatcher
+		to            *events.Dispatcher
+		board         *StatusBoard
+		notifications chan Notification
+		statuses      chan StatusProvider
+	}
+
+	// StatusBoard combines the statuses of several servers into the bot presence.
+	StatusBoard struct {
+		mu       sync.Mutex
+		names    []string
+		statuses map[string]string
+	}
+
+	// ScopedNotification is a notification of one of several servers.
+	ScopedNotification struct {
+		Scope string
+		Notification
+	}
+)
+
+var (
+	// Interface checks
+	_ suture.Service = (*Relay)(nil)
+	_ StatusProvider = (*StatusBoard)(nil)
+	_ Notification   = (*ScopedNotification)(nil)
+	_ log.Fielder    = (*ScopedNotification)(nil)
+)
+
+// NewStatusBoard creates a board for the named servers; the names are only shown if there are several of them.
+func NewStatusBoard(names []string) *StatusBoard {
+	return &StatusBoard{names: names, statuses: make(map[string]string, len(names))}
+}
+
+func NewRelay(name string, from, to *events.Dispatcher, board *StatusBoard) *Relay {
+	return &Relay{
+		name:          name,
+		from:          from,
+		to:            to,
+		board:         board,
+		notifications: events.MakeHandler[Notification](),
+		statuses:      events.MakeHandler[StatusProvider](),
+	}
+}
+
+func (r *Relay) String() string {
+	return fmt.Sprintf("Relay(%s)", r.name)
+}
+
+func (r *Relay) Serve(ctx context.Context) error {
+	defer r.from.Subscribe(r.notifications).Cancel()
+	defer r.from.Subscribe(r.statuses).Cancel()
+
+	for {
+		select {
+		case notification := <-r.notifications:
+			if r.board.IsMultiple() {
+				notification = &ScopedNotification{r.name, notification}
+			}
+			r.to.Dispatch(notification)
+		case provider := <-r.statuses:
+			r.board.Set(r.name, provider.DiscordStatus())
+			r.to.Dispatch(r.board)
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+func (b *StatusBoard) IsMultiple() bool {
+	return len(b.names) > 1
+}
+
+// Set updates the status of a server.
+func (b *StatusBoard) Set(name, status string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.statuses[name] = status
+}
+
+func (b *StatusBoard) DiscordStatus() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.IsMultiple() {
+		for _, status := range b.statuses {
+			return status
+		}
+		return ""
+	}
+	parts := make([]string, 0, len(b.names))
+	for _, name := range b.names {
+		if status, found := b.statuses[name]; found {
+			// "Server ready" becomes "survival: ready"
+			parts = append(parts, fmt.Sprintf("%s: %s", name, strings.TrimPrefix(status, "Server ")))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+func (n *ScopedNotification) DiscordNotification() string {
+	message := n.Notification.DiscordNotification()
+	if message == "" {
+		return ""
+	}
+	return fmt.Sprintf("[%s] %s", n.Scope, message)
+}
+
+func (n *ScopedNotification) Fields() log.Fields {
+	fields := log.Fields{"scope": n.Scope}
+	if fielder, ok := n.Notification.(log.Fielder); ok {
+		for key, value := range fielder.Fields() {
+			fields[key] = value
+		}
+	}
+	return fields
+}
@@ -0,0 +1,75 @@
+package jobs
+
+import (
+	"errors"
+	"time"
+
+	"github.com/Adirelle/mcvisor/pkg/commands"
+	"github.com/Adirelle/mcvisor/pkg/minecraft"
+)
+
+type (
+	Config map[commands.Name]*JobConfig
+
+	JobConfig struct {
+		Description string `json:"description,omitempty"`
+		Permission  string `json:"permission,omitempty" validate:"omitempty,oneof=public query control admin"`
+		Steps       []Step `json:"steps" validate:"required,min=1,dive"`
+	}
+
+	Step struct {
+		Console string           `json:"console,omitempty"`
+		Wait    time.Duration    `json:"wait,omitempty"`
+		Shell   string           `json:"shell,omitempty"`
+		Target  minecraft.Target `json:"target,omitempty" validate:"omitempty,oneof=start stop restart"`
+		Timeout time.Duration    `json:"timeout,omitempty"`
+	}
+)
+
+const (
+	DefaultPermission = "admin"
+)
+
+var (
+	ErrInvalidStep = errors.New("exactly one of console, wait, shell or target must be set")
+
+	DefaultTargetTimeout = 5 * time.Minute
+	DefaultShellTimeout  = time.Minute
+)
+
+func NewConfig() Config {
+	return make(Config)
+}
+
+func (s Step) Validate() error {
+	count := 0
+	for _, isSet := range []bool{s.Console != "", s.Wait != 0, s.Shell != "", s.Target != ""} {
+		if isSet {
+			count++
+		}
+	}
+	if count != 1 {
+		return ErrInvalidStep
+	}
+	return nil
+}
+
+func (s Step) String() string {
+	switch {
+	case s.Console != "":
+		return "console " + s.Console
+	case s.Shell != "":
+		return "shell " + s.Shell
+	case s.Target != "":
+		return "target " + string(s.Target)
+	default:
+		return "wait " + s.Wait.String()
+	}
+}
+
+func (s Step) TimeoutOr(def time.Duration) time.Duration {
+	if s.Timeout > 0 {
+		return s.Timeout
+	}
+	return def
+}
@@ -0,0 +1,166 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Adirelle/mcvisor/pkg/commands"
+	"github.com/Adirelle/mcvisor/pkg/discord"
+	"github.com/Adirelle/mcvisor/pkg/events"
+	"github.com/Adirelle/mcvisor/pkg/minecraft"
+	"github.com/Adirelle/mcvisor/pkg/scheduler"
+	"github.com/apex/log"
+)
+
+type (
+	Runner struct {
+		dispatcher *events.Dispatcher
+		console    minecraft.Console
+		statuser   minecraft.Statuser
+		workingDir string
+		registry   *commands.Registry
+	}
+
+	job struct {
+		*JobConfig
+		name    commands.Name
+		runner  *Runner
+		running sync.Mutex
+	}
+)
+
+var (
+	// Interface check
+	_ commands.Handler = (*job)(nil)
+
+	ErrJobRunning   = errors.New("job is already running")
+	ErrCommandTaken = errors.New("a command with the same name already exists")
+
+	// ReservedCommands are the global commands that are registered after the jobs.
+	ReservedCommands = []commands.Name{scheduler.ScheduleCommand}
+)
+
+func NewRunner(dispatcher *events.Dispatcher, console minecraft.Console, statuser minecraft.Statuser, workingDir string, registry *commands.Registry) *Runner {
+	return &Runner{
+		dispatcher: dispatcher,
+		console:    console,
+		statuser:   statuser,
+		workingDir: workingDir,
+		registry:   registry,
+	}
+}
+
+// Register adds one command per configured job. Jobs cannot replace existing commands, nor shadow global ones.
+func (r *Runner) Register(config Config) error {
+	for name, jobConfig := range config {
+		if r.isTaken(name) {
+			return fmt.Errorf("job %s: %w", name, ErrCommandTaken)
+		}
+		for i, step := range jobConfig.Steps {
+			if err := step.Validate(); err != nil {
+				return fmt.Errorf("job %s: step %d: %w", name, i+1, err)
+			}
+		}
+		permissionName := jobConfig.Permission
+		if permissionName == "" {
+			permissionName = DefaultPermission
+		}
+		permission, err := discord.ParseCategory(permissionName)
+		if err != nil {
+			return fmt.Errorf("job %s: %w", name, err)
+		}
+		description := jobConfig.Description
+		if description == "" {
+			description = fmt.Sprintf("run the %s job", name)
+		}
+		r.registry.Register(name, description, permission, &job{JobConfig: jobConfig, name: name, runner: r})
+	}
+	return nil
+}
+
+// isTaken returns whether the name is used by a command of the server, or by a global command.
+func (r *Runner) isTaken(name commands.Name) bool {
+	if r.registry.IsRegistered(name) || commands.Default.IsRegistered(name) {
+		return true
+	}
+	for _, reserved := range ReservedCommands {
+		if name == reserved {
+			return true
+		}
+	}
+	return false
+}
+
+func (j *job) HandleCommand(cmd *commands.Command) (string, error) {
+	if !j.running.TryLock() {
+		return "", ErrJobRunning
+	}
+	defer j.running.Unlock()
+
+	logger := log.WithFields(cmd).WithField("job", j.name)
+	logger.Info("job.start")
+
+	transcript := make([]string, 0, len(j.Steps))
+	for i, step := range j.Steps {
+		stepLogger := logger.WithField("step", i+1).WithField("action", step.String())
+		output, err := j.runner.runStep(step)
+		if output != "" {
+			transcript = append(transcript, output)
+		}
+		if err != nil {
+			stepLogger.WithError(err).Warn("job.step")
+			return strings.Join(transcript, "\n"), fmt.Errorf("step %d (%s) failed: %w", i+1, step, err)
+		}
+		stepLogger.Debug("job.step")
+	}
+
+	logger.Info("job.done")
+	return strings.Join(transcript, "\n"), nil
+}
+
+func (r *Runner) runStep(step Step) (string, error) {
+	switch {
+	case step.Console != "":
+		output, err := r.console.ExecuteConsoleCommand(step.Console)
+		reply := fmt.Sprintf("`%s`", step.Console)
+		if output != "" {
+			reply += fmt.Sprintf("\n```\n%s\n```", output)
+		}
+		return reply, err
+	case step.Shell != "":
+		return r.runShell(step.Shell, step.TimeoutOr(DefaultShellTimeout))
+	case step.Target != "":
+		return r.reachTarget(step.Target, step.TimeoutOr(DefaultTargetTimeout))
+	default:
+		time.Sleep(step.Wait)
+		return "", nil
+	}
+}
+
+func (r *Runner) runShell(command string, timeout time.Duration) (string, error) {
+	ctx, cleanup := context.WithTimeout(context.Background(), timeout)
+	defer cleanup()
+
+	cmd := exec.CommandContext(ctx, ShellCommand[0], append(ShellCommand[1:], command)...)
+	cmd.Dir = r.workingDir
+	output, err := cmd.CombinedOutput()
+
+	reply := fmt.Sprintf("`$ %s`", command)
+	if trimmed := strings.TrimSpace(string(output)); trimmed != "" {
+		reply += fmt.Sprintf("\n```\n%s\n```", trimmed)
+	}
+	return reply, err
+}
+
+func (r *Runner) reachTarget(target minecraft.Target, timeout time.Duration) (string, error) {
+	status, err := minecraft.ReachTarget(r.dispatcher, r.statuser, target, timeout)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Server %s", status), nil
+}
@@ -0,0 +1,186 @@
+package jobs_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Adirelle/mcvisor/pkg/commands"
+	"github.com/Adirelle/mcvisor/pkg/events"
+	"github.com/Adirelle/mcvisor/pkg/jobs"
+	"github.com/Adirelle/mcvisor/pkg/minecraft"
+	"github.com/Adirelle/mcvisor/pkg/scheduler"
+)
+
+type fakeServer struct {
+	commands chan string
+	release  chan struct{}
+}
+
+func (s *fakeServer) ExecuteConsoleCommand(command string) (string, error) {
+	s.commands <- command
+	if s.release != nil {
+		<-s.release
+	}
+	if command == "fail" {
+		return "", errors.New("unknown command")
+	}
+	return "executed " + command, nil
+}
+
+func (*fakeServer) Status() minecraft.Status {
+	return minecraft.Ready
+}
+
+func newRunner(t *testing.T, config jobs.Config) (*commands.Registry, *fakeServer) {
+	t.Helper()
+	registry := commands.NewRegistry()
+	server := &fakeServer{commands: make(chan string, 10)}
+	runner := jobs.NewRunner(events.NewDispatcher(), server, server, t.TempDir(), registry)
+	if err := runner.Register(config); err != nil {
+		t.Fatal(err)
+	}
+	return registry, server
+}
+
+func TestJobSteps(t *testing.T) {
+	t.Parallel()
+
+	registry, server := newRunner(t, jobs.Config{
+		"announce": {Steps: []jobs.Step{
+			{Console: "say hello"},
+			{Wait: time.Millisecond},
+			{Shell: "echo from the shell"},
+			{Target: minecraft.StartTarget},
+		}},
+	})
+
+	reply, err := registry.HandleCommand(commands.NewCommand("announce", commands.System))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if command := <-server.commands; command != "say hello" {
+		t.Errorf("unexpected console command: %q", command)
+	}
+	for _, expected := range []string{"`say hello`", "executed say hello", "`$ echo from the shell`", "from the shell", "Server ready"} {
+		if !strings.Contains(reply, expected) {
+			t.Errorf("expected the reply to contain %q, got %q", expected, reply)
+		}
+	}
+}
+
+func TestJobFailedStep(t *testing.T) {
+	t.Parallel()
+
+	registry, server := newRunner(t, jobs.Config{
+		"broken": {Steps: []jobs.Step{{Console: "fail"}, {Console: "never"}}},
+	})
+
+	_, err := registry.HandleCommand(commands.NewCommand("broken", commands.System))
+	if err == nil || !strings.Contains(err.Error(), "step 1 (console fail) failed") {
+		t.Errorf("expected the first step to fail, got %v", err)
+	}
+	<-server.commands
+	select {
+	case command := <-server.commands:
+		t.Errorf("unexpected console command after the failure: %q", command)
+	default:
+	}
+}
+
+func TestJobRunning(t *testing.T) {
+	t.Parallel()
+
+	registry, server := newRunner(t, jobs.Config{
+		"slow": {Steps: []jobs.Step{{Console: "save-all"}}},
+	})
+	server.release = make(chan struct{})
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := registry.HandleCommand(commands.NewCommand("slow", commands.System))
+		done <- err
+	}()
+	<-server.commands
+
+	if _, err := registry.HandleCommand(commands.NewCommand("slow", commands.System)); !errors.Is(err, jobs.ErrJobRunning) {
+		t.Errorf("expected %s, got %v", jobs.ErrJobRunning, err)
+	}
+
+	close(server.release)
+	if err := <-done; err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if _, err := registry.HandleCommand(commands.NewCommand("slow", commands.System)); err != nil {
+		t.Errorf("expected the job to run again, got %v", err)
+	}
+}
+
+func TestRegisterRejectsExistingCommands(t *testing.T) {
+	t.Parallel()
+
+	registry := commands.NewRegistry()
+	stopped := false
+	registry.Register("stop", "stop the server", commands.AllowAll, commands.HandlerFunc(func(*commands.Command) (string, error) {
+		stopped = true
+		return "", nil
+	}))
+	runner := jobs.NewRunner(events.NewDispatcher(), nil, nil, t.TempDir(), registry)
+
+	err := runner.Register(jobs.Config{"stop": {Permission: "public", Steps: []jobs.Step{{Shell: "echo pwned"}}}})
+	if !errors.Is(err, jobs.ErrCommandTaken) {
+		t.Errorf("expected %s, got %v", jobs.ErrCommandTaken, err)
+	}
+	if _, err = registry.HandleCommand(commands.NewCommand("stop", commands.System)); err != nil || !stopped {
+		t.Errorf("expected the original command to be kept, got %v", err)
+	}
+}
+
+func TestRegisterRejectsGlobalCommands(t *testing.T) {
+	// Not parallel, as it registers a command in the default registry.
+	commands.Register("announce-all", "global announcement", commands.AllowAll, commands.HandlerFunc(func(*commands.Command) (string, error) {
+		return "", nil
+	}))
+
+	for _, name := range []commands.Name{"announce-all", scheduler.ScheduleCommand} {
+		registry := commands.NewRegistry()
+		runner := jobs.NewRunner(events.NewDispatcher(), nil, nil, t.TempDir(), registry)
+		err := runner.Register(jobs.Config{name: {Steps: []jobs.Step{{Shell: "echo shadowed"}}}})
+		if !errors.Is(err, jobs.ErrCommandTaken) {
+			t.Errorf("%s: expected %s, got %v", name, jobs.ErrCommandTaken, err)
+		}
+		if registry.IsRegistered(name) {
+			t.Errorf("%s: the job should not be registered", name)
+		}
+	}
+}
+
+func TestStepValidate(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		step  jobs.Step
+		valid bool
+	}{
+		{jobs.Step{Console: "say hello"}, true},
+		{jobs.Step{Wait: time.Second}, true},
+		{jobs.Step{Shell: "true"}, true},
+		{jobs.Step{Target: minecraft.RestartTarget, Timeout: time.Minute}, true},
+		{jobs.Step{}, false},
+		{jobs.Step{Timeout: time.Minute}, false},
+		{jobs.Step{Console: "say hello", Shell: "true"}, false},
+		{jobs.Step{Wait: time.Second, Target: minecraft.StopTarget}, false},
+	}
+	for _, test := range tests {
+		if err := test.step.Validate(); (err == nil) != test.valid {
+			t.Errorf("%#v: unexpected result: %v", test.step, err)
+		}
+	}
+
+	runner := jobs.NewRunner(events.NewDispatcher(), nil, nil, t.TempDir(), commands.NewRegistry())
+	err := runner.Register(jobs.Config{"invalid": {Steps: []jobs.Step{{Console: "list"}, {}}}})
+	if !errors.Is(err, jobs.ErrInvalidStep) {
+		t.Errorf("expected %s, got %v", jobs.ErrInvalidStep, err)
+	}
+}
@@ -0,0 +1,5 @@
+//go:build windows
+
+package jobs
+
+var ShellCommand = []string{"cmd.exe", "/C"}
@@ -2,7 +2,7 @@ package minecraft
 
 import (
 	"fmt"
-	"os"
+	"net"
 	"path/filepath"
 	"time"
 )
@@ -12,6 +12,9 @@ const (
 	DefaultServerProperties = "server.properties"
 	DefaultLog4JConf        = "mcvisor_log4J.xml"
 	JaveHomeEnvName         = "JAVA_HOME"
+	DefaultStopTimeout      = time.Minute
+	DefaultTermTimeout      = 30 * time.Second
+	DefaultServerPort       = 25565
 )
 
 type Config struct {
@@ -20,64 +23,90 @@ type Config struct {
 }
 
 type ServerConfig struct {
-	BaseDir    string         `json:"-"`
-	WorkingDir string         `json:"working_dir,omitempty"`
-	Jar        string         `json:"jar,omitempty"`
-	Properties string         `json:"properties,omitempty"`
-	Log4JConf  string         `json:"log4jxml,omitempty"`
-	Options    []string       `json:"options"`
-	Network    *NetworkConfig `json:"network"`
+	BaseDir     string           `json:"-"`
+	WorkingDir  string           `json:"working_dir,omitempty"`
+	Jar         string           `json:"jar,omitempty"`
+	Launcher    *LauncherConfig  `json:"launcher" validate:"required"`
+	Properties  string           `json:"properties,omitempty"`
+	Log4JConf   string           `json:"log4jxml,omitempty"`
+	Options     []string         `json:"options"`
+	Network     *NetworkConfig   `json:"network" validate:"required"`
+	StopTimeout time.Duration    `json:"stop_timeout"`
+	TermTimeout time.Duration    `json:"term_timeout"`
+	LogPatterns []LogPattern     `json:"log_patterns,omitempty" validate:"dive"`
+	Readiness   *ReadinessConfig `json:"readiness" validate:"required"`
+	Restart     *RestartConfig   `json:"restart" validate:"required"`
+	Countdown   *CountdownConfig `json:"countdown" validate:"required"`
+	Console     *ConsoleConfig   `json:"console" validate:"required"`
+	Monitor     *MonitorConfig   `json:"monitor" validate:"required"`
+	Lag         *LagConfig       `json:"lag" validate:"required"`
+	Detached    *DetachedConfig  `json:"detached" validate:"required"`
+	Preflight   *PreflightConfig `json:"preflight" validate:"required"`
 }
 
 type NetworkConfig struct {
-	Host              string        `json:"host,omitempty" validate:"omitempty,ip|hostname|fqdn"`
-	Port              uint16        `json:"port,omitempty"`
-	PingPeriod        time.Duration `json:"ping_interval"`
-	ConnectionTimeout time.Duration `json:"connection_timeout"`
-	ResponseTimeout   time.Duration `json:"response_timeout"`
+	Host              string          `json:"host,omitempty" validate:"omitempty,ip|hostname|fqdn"`
+	Port              uint16          `json:"port,omitempty"`
+	PingPeriod        time.Duration   `json:"ping_interval"`
+	ConnectionTimeout time.Duration   `json:"connection_timeout"`
+	ResponseTimeout   time.Duration   `json:"response_timeout"`
+	Watchdog          *WatchdogConfig `json:"watchdog" validate:"required"`
 }
 
 type JavaConfig struct {
-	Home    string   `json:"home,omitempty" validate:"dir"`
-	Options []string `json:"options"`
+	Home       string     `json:"home,omitempty" validate:"omitempty,dir"`
+	SearchDirs []string   `json:"search_dirs,omitempty"`
+	MinMemory  MemorySize `json:"min_memory,omitempty"`
+	MaxMemory  MemorySize `json:"max_memory,omitempty"`
+	Preset     JavaPreset `json:"preset" validate:"oneof=default aikar zgc none"`
+	Options    []string   `json:"options"`
 }
 
 func NewConfig(baseDir string) *Config {
 	baseDir = filepath.Clean(baseDir)
 	return &Config{
 		Java: &JavaConfig{
-			Home: os.Getenv(JaveHomeEnvName),
-			Options: []string{
-				"-XX:+UnlockExperimentalVMOptions",
-				"-XX:+UseG1GC",
-				"-XX:G1NewSizePercent=20",
-				"-XX:G1ReservePercent=20",
-				"-XX:MaxGCPauseMillis=50",
-				"-XX:G1HeapRegionSize=32M",
-			},
+			Preset:  DefaultPreset,
+			Options: []string{},
 		},
 		Server: &ServerConfig{
-			BaseDir:    baseDir,
-			WorkingDir: baseDir,
-			Jar:        DefaultServerJar,
-			Properties: DefaultServerProperties,
-			Log4JConf:  DefaultLog4JConf,
-			Options:    []string{"--nogui"},
+			BaseDir:     baseDir,
+			WorkingDir:  baseDir,
+			Jar:         DefaultServerJar,
+			Launcher:    NewLauncherConfig(),
+			Properties:  DefaultServerProperties,
+			Log4JConf:   DefaultLog4JConf,
+			Options:     []string{"--nogui"},
+			StopTimeout: DefaultStopTimeout,
+			TermTimeout: DefaultTermTimeout,
+			Readiness:   NewReadinessConfig(),
+			Restart:     NewRestartConfig(),
+			Countdown:   NewCountdownConfig(),
+			Console:     NewConsoleConfig(),
+			Monitor:     NewMonitorConfig(),
+			Lag:         NewLagConfig(),
+			Detached:    NewDetachedConfig(),
+			Preflight:   NewPreflightConfig(),
 			Network: &NetworkConfig{
 				PingPeriod:        10 * time.Second,
 				ConnectionTimeout: 5 * time.Second,
 				ResponseTimeout:   5 * time.Second,
+				Watchdog:          NewWatchdogConfig(),
 			},
 		},
 	}
 }
 
-func (c Config) Command() []string {
-	return append(c.Java.Command(), c.Server.Command()...)
+func (c Config) Command() ([]string, error) {
+	return c.Server.Launcher.CommandLine(c.Java, c.Server)
 }
 
 func (c Config) Env() []string {
-	return []string{JaveHomeEnvName + "=" + c.Java.Home}
+	env := c.Server.Launcher.Env(c.Java, c.Server)
+	if c.Java.Home == "" {
+		return env
+	}
+	return append(env, JaveHomeEnvName+"="+c.Java.Home)
 }
 
 func (c Config) WorkingDir() string {
@@ -85,17 +114,21 @@ func (c Config) WorkingDir() string {
 }
 
 func (c JavaConfig) AbsJavaCommand() string {
+	if c.Home == "" {
+		// Without a runtime, the command is looked up in the PATH.
+		return filepath.Base(JavaCmd)
+	}
 	return filepath.Join(c.Home, JavaCmd)
 }
 
-func (c JavaConfig) Command() []string {
-	return append([]string{c.AbsJavaCommand()}, c.Options...)
-}
-
 func (c ServerConfig) AbsWorkingDir() string {
 	return absPath(c.BaseDir, c.WorkingDir)
 }
 
+func (c ServerConfig) AbsJar() string {
+	return absPath(c.AbsWorkingDir(), c.Jar)
+}
+
 func (c ServerConfig) AbsLog4JConf() string {
 	return absPath(c.AbsWorkingDir(), c.Log4JConf)
 }
@@ -104,15 +137,20 @@ func (c ServerConfig) AbsServerProperties() string {
 	return absPath(c.AbsWorkingDir(), c.Properties)
 }
 
-func (c ServerConfig) Command() []string {
-	return append(
-		[]string{
-			fmt.Sprintf("-Dlog4j.configurationFile=%s", c.Log4JConf),
-			"-jar",
-			c.Jar,
-		},
-		c.Options...,
-	)
+// Log4JOption returns the Java option that makes the server use the log4j configuration of mcvisor.
+func (c ServerConfig) Log4JOption() string {
+	return fmt.Sprintf("-Dlog4j.configurationFile=%s", c.Log4JConf)
+}
+
+func (c NetworkConfig) Address() string {
+	host, port := c.Host, c.Port
+	if host == "" {
+		host = "localhost"
+	}
+	if port == 0 {
+		port = DefaultServerPort
+	}
+	return net.JoinHostPort(host, fmt.Sprint(port))
 }
 
 func absPath(base, path string) string {
@@ -3,3 +3,15 @@
 package minecraft
 
 const JavaCmd string = "bin/java"
+
+const (
+	DefaultLauncherScript = "run.sh"
+	ForgeArgsFileName     = "unix_args.txt"
+)
+
+var DefaultJavaSearchDirs = []string{
+	"/usr/lib/jvm",
+	"/usr/java",
+	"/opt/java",
+	"/Library/Java/JavaVirtualMachines",
+}
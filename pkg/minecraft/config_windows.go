@@ -3,3 +3,15 @@
 package minecraft
 
 const JavaCmd string = "bin\\java.exe"
+
+const (
+	DefaultLauncherScript = "run.bat"
+	ForgeArgsFileName     = "win_args.txt"
+)
+
+var DefaultJavaSearchDirs = []string{
+	`C:\Program Files\Java`,
+	`C:\Program Files\Eclipse Adoptium`,
+	`C:\Program Files\Microsoft`,
+	`C:\Program Files\Zulu`,
+}
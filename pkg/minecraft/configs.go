@@ -0,0 +1,90 @@
+package minecraft
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+)
+
+type (
+	// Configs holds the configurations of the supervised servers, by name.
+	//
+	// In JSON, it is an object with the server names as keys. The former single-server format, with "server"
+	// and "java" keys, is still accepted and read as one server named DefaultServerName.
+	Configs struct {
+		BaseDir string
+		Servers map[string]*Config `validate:"required,min=1,dive,keys,required,excludesall= /,ne=all,endkeys,required"`
+	}
+)
+
+const (
+	DefaultServerName = "default"
+)
+
+func NewConfigs(baseDir string) *Configs {
+	baseDir = filepath.Clean(baseDir)
+	return &Configs{
+		BaseDir: baseDir,
+		Servers: map[string]*Config{DefaultServerName: NewConfig(baseDir)},
+	}
+}
+
+// Names returns the server names, sorted.
+func (c *Configs) Names() []string {
+	names := make([]string, 0, len(c.Servers))
+	for name := range c.Servers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Validate checks the settings that cannot be checked by struct tags.
+func (c *Configs) Validate() error {
+	for _, name := range c.Names() {
+		if err := c.Servers[name].Java.Validate(); err != nil {
+			return fmt.Errorf("server %s: %w", name, err)
+		}
+		if err := c.Servers[name].Server.Detached.Validate(); err != nil {
+			return fmt.Errorf("server %s: %w", name, err)
+		}
+		if err := c.Servers[name].Server.Preflight.Validate(); err != nil {
+			return fmt.Errorf("server %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func (c *Configs) MarshalJSON() ([]byte, error) {
+	return json.Marshal(c.Servers)
+}
+
+func (c *Configs) UnmarshalJSON(data []byte) error {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	_, hasServer := raw["server"]
+	_, hasJava := raw["java"]
+	if len(raw) == 0 || hasServer || hasJava {
+		config := NewConfig(c.BaseDir)
+		if err := json.Unmarshal(data, config); err != nil {
+			return err
+		}
+		c.Servers = map[string]*Config{DefaultServerName: config}
+		return nil
+	}
+
+	c.Servers = make(map[string]*Config, len(raw))
+	for name, content := range raw {
+		config := NewConfig(c.BaseDir)
+		config.Server.WorkingDir = name
+		if err := json.Unmarshal(content, config); err != nil {
+			return err
+		}
+		c.Servers[name] = config
+	}
+	return nil
+}
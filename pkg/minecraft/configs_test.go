@@ -0,0 +1,61 @@
+package minecraft_test
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/Adirelle/mcvisor/pkg/minecraft"
+	"github.com/go-playground/validator/v10"
+)
+
+func TestConfigsLegacyFormat(t *testing.T) {
+	t.Parallel()
+
+	configs := minecraft.NewConfigs("/srv")
+	if err := json.Unmarshal([]byte(`{"server": {"working_dir": "mc"}}`), configs); err != nil {
+		t.Fatal(err)
+	}
+	if names := configs.Names(); !reflect.DeepEqual(names, []string{minecraft.DefaultServerName}) {
+		t.Fatalf("unexpected servers: %v", names)
+	}
+	if dir := configs.Servers[minecraft.DefaultServerName].WorkingDir(); dir != filepath.Join("/srv", "mc") {
+		t.Errorf("unexpected working directory: %s", dir)
+	}
+}
+
+func TestConfigsNamedServers(t *testing.T) {
+	t.Parallel()
+
+	configs := minecraft.NewConfigs("/srv")
+	if err := json.Unmarshal([]byte(`{"survival": {}, "creative": {"server": {"working_dir": "build"}}}`), configs); err != nil {
+		t.Fatal(err)
+	}
+	if names := configs.Names(); !reflect.DeepEqual(names, []string{"creative", "survival"}) {
+		t.Fatalf("unexpected servers: %v", names)
+	}
+	expected := map[string]string{"survival": "survival", "creative": "build"}
+	for name, dir := range expected {
+		if actual := configs.Servers[name].WorkingDir(); actual != filepath.Join("/srv", dir) {
+			t.Errorf("%s: unexpected working directory: %s", name, actual)
+		}
+	}
+}
+
+func TestConfigsMissingSections(t *testing.T) {
+	t.Parallel()
+
+	for _, data := range []string{
+		`{"server": {"network": null}}`,
+		`{"server": {"network": {"watchdog": null}}}`,
+	} {
+		configs := minecraft.NewConfigs("/srv")
+		if err := json.Unmarshal([]byte(data), configs); err != nil {
+			t.Fatal(err)
+		}
+		if err := validator.New().Struct(configs); err == nil {
+			t.Errorf("%s: expected a validation error", data)
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package minecraft
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/Adirelle/mcvisor/pkg/commands"
+	"github.com/Adirelle/mcvisor/pkg/utils"
+	"github.com/apex/log"
+	"golang.org/x/exp/slices"
+)
+
+type (
+	ConsoleConfig struct {
+		QuietPeriod time.Duration     `json:"quiet_period"`
+		MaxWait     time.Duration     `json:"max_wait"`
+		Terminators map[string]string `json:"terminators,omitempty"`
+		Noise       []string          `json:"noise,omitempty"`
+	}
+
+	outputCollector struct {
+		*ConsoleConfig
+		parser      *LogParser
+		terminators map[string]*regexp.Regexp
+		noise       []*regexp.Regexp
+	}
+
+	consoleCommand struct {
+		command string
+		reply   chan<- error
+	}
+)
+
+var (
+	ConsoleOutputCapacity = 100
+
+	ErrConsoleIncomplete = errors.New("command output did not complete in time")
+)
+
+func NewConsoleConfig() *ConsoleConfig {
+	return &ConsoleConfig{
+		QuietPeriod: 500 * time.Millisecond,
+		MaxWait:     5 * time.Second,
+		Terminators: map[string]string{
+			"save-all": `^Saved the game$`,
+		},
+	}
+}
+
+func newOutputCollector(config *ConsoleConfig, parser *LogParser) (*outputCollector, error) {
+	c := &outputCollector{
+		ConsoleConfig: config,
+		parser:        parser,
+		terminators:   make(map[string]*regexp.Regexp, len(config.Terminators)),
+	}
+	for command, pattern := range config.Terminators {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid terminator for %s: %w", command, err)
+		}
+		c.terminators[command] = re
+	}
+	for _, pattern := range config.Noise {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid noise pattern: %w", err)
+		}
+		c.noise = append(c.noise, re)
+	}
+	return c, nil
+}
+
+// Terminator returns the pattern matching the last output line of the given command, if any.
+func (c *outputCollector) Terminator(command string) *regexp.Regexp {
+	words := strings.Fields(command)
+	if len(words) == 0 {
+		return nil
+	}
+	return c.terminators[strings.TrimPrefix(words[0], "/")]
+}
+
+// IsNoise returns whether the line is background activity rather than command output.
+func (c *outputCollector) IsNoise(line string) bool {
+	if c.parser.Parse(line) != nil {
+		return true
+	}
+	for _, re := range c.noise {
+		if re.MatchString(line) {
+			return true
+		}
+	}
+	return false
+}
+
+// Collect gathers output lines until either:
+//   - the terminator matches a line,
+//   - there is no terminator and no line has been received during the quiet period,
+//   - maxWait is elapsed, in which case ErrConsoleIncomplete is returned if a terminator was expected.
+func (c *outputCollector) Collect(outputs <-chan ServerOutput, terminator *regexp.Regexp, maxWait time.Duration) (lines []string, err error) {
+	deadline := time.NewTimer(maxWait)
+	defer deadline.Stop()
+
+	var quiet *time.Timer
+	var quietC <-chan time.Time
+	if terminator == nil {
+		quiet = time.NewTimer(c.QuietPeriod)
+		defer quiet.Stop()
+		quietC = quiet.C
+	}
+
+	for {
+		select {
+		case output := <-outputs:
+			line := string(output)
+			if terminator != nil && terminator.MatchString(line) {
+				return append(lines, line), nil
+			}
+			if c.IsNoise(line) {
+				continue
+			}
+			lines = append(lines, line)
+			if quiet != nil {
+				if !quiet.Stop() {
+					select {
+					case <-quiet.C:
+					default:
+					}
+				}
+				quiet.Reset(c.QuietPeriod)
+			}
+		case <-quietC:
+			return lines, nil
+		case <-deadline.C:
+			if terminator != nil {
+				err = ErrConsoleIncomplete
+			}
+			return lines, err
+		}
+	}
+}
+
+func (s *Server) handleConsoleCommand(cmd *commands.Command) (string, error) {
+	output, err := s.ExecuteConsoleCommand(strings.Join(cmd.Arguments, " "))
+	if output != "" {
+		output = fmt.Sprintf("```\n%s\n```", output)
+	}
+	return output, err
+}
+
+// ExecuteConsoleCommand sends a command to the server console and returns its output.
+func (s *Server) ExecuteConsoleCommand(command string) (string, error) {
+	lines, err := s.CollectConsoleOutput(command, s.collector.Terminator(command), s.Server.Console.MaxWait)
+	return strings.Join(lines, "\n"), err
+}
+
+// CollectConsoleOutput sends a command to the server console and collects its output lines
+// until terminator matches or, if terminator is nil, until the server stays quiet.
+// The command is sent through RCON when it is enabled, which returns the exact response of the command;
+// the output is then only collected from the server logs if the response does not match the terminator.
+func (s *Server) CollectConsoleOutput(command string, terminator *regexp.Regexp, maxWait time.Duration) ([]string, error) {
+	if !s.Status().IsRunning() {
+		return nil, ErrStoppedServer
+	}
+
+	s.consoleMu.Lock()
+	defer s.consoleMu.Unlock()
+
+	outputs := make(chan ServerOutput, ConsoleOutputCapacity)
+	defer s.dispatcher.Subscribe(outputs).Cancel()
+
+	if lines, available, err := s.rcon.Execute(command); available {
+		if err != nil || terminator == nil || slices.IndexFunc(lines, terminator.MatchString) >= 0 {
+			return lines, err
+		}
+		more, err := s.collector.Collect(outputs, terminator, maxWait)
+		return append(lines, more...), err
+	}
+
+	reply := make(chan error, 1)
+	if err := utils.SendWithTimeout(s.console, &consoleCommand{command, reply}, ConsoleCommandTimeout); err != nil {
+		return nil, err
+	}
+	if err, recvErr := utils.RecvWithTimeout(reply, ConsoleCommandTimeout); recvErr != nil {
+		return nil, recvErr
+	} else if err != nil {
+		return nil, err
+	}
+
+	lines, err := s.collector.Collect(outputs, terminator, maxWait)
+	log.WithField("command", command).WithField("lines", len(lines)).WithError(err).Debug("server.console.output")
+	return lines, err
+}
+
+// executeConsoleCommand writes the command to the server console, from the Serve goroutine.
+func (s *Server) executeConsoleCommand(command string) error {
+	err := s.sendConsoleCommand(command)
+	if err != nil {
+		log.WithError(err).WithField("command", command).Warn("server.console")
+		return err
+	}
+	log.WithField("command", command).Info("server.console")
+	return nil
+}
@@ -0,0 +1,258 @@
+package minecraft
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Adirelle/mcvisor/pkg/commands"
+	"github.com/Adirelle/mcvisor/pkg/discord"
+	"github.com/Adirelle/mcvisor/pkg/utils"
+	"github.com/apex/log"
+)
+
+type (
+	CountdownConfig struct {
+		Steps  []time.Duration `json:"steps"`
+		Method AnnounceMethod  `json:"method" validate:"oneof=say tellraw"`
+	}
+
+	AnnounceMethod string
+
+	countdown struct {
+		target   Target
+		deadline time.Time
+		timer    <-chan time.Time
+	}
+
+	countdownRequest struct {
+		countdown *countdown
+		reply     chan<- countdownReply
+	}
+
+	countdownReply struct {
+		message string
+		err     error
+	}
+
+	CountdownNotice struct {
+		Target    Target
+		Remaining time.Duration
+	}
+
+	CountdownCancelled struct {
+		Target Target
+	}
+)
+
+const (
+	SayMethod     AnnounceMethod = "say"
+	TellrawMethod AnnounceMethod = "tellraw"
+
+	CancelCommand commands.Name = "cancel"
+)
+
+var (
+	// Interface checks
+	_ discord.Notification = (*CountdownNotice)(nil)
+	_ discord.Notification = (*CountdownCancelled)(nil)
+
+	ErrNoCountdown = errors.New("there is no pending countdown")
+)
+
+func NewCountdownConfig() *CountdownConfig {
+	return &CountdownConfig{
+		Steps: []time.Duration{
+			30 * time.Minute,
+			15 * time.Minute,
+			10 * time.Minute,
+			5 * time.Minute,
+			time.Minute,
+			30 * time.Second,
+			10 * time.Second,
+			5 * time.Second,
+		},
+		Method: TellrawMethod,
+	}
+}
+
+// NextStep returns the largest step shorter than remaining, or zero.
+func (c *CountdownConfig) NextStep(remaining time.Duration) (next time.Duration) {
+	for _, step := range c.Steps {
+		if step < remaining && step > next {
+			next = step
+		}
+	}
+	return
+}
+
+// ConsoleCommand returns the console command that displays the message to all players.
+func (c *CountdownConfig) ConsoleCommand(message string) string {
+	if c.Method == SayMethod {
+		return "say " + message
+	}
+	text, _ := json.Marshal(map[string]string{"text": message, "color": "gold"})
+	return "tellraw @a " + string(text)
+}
+
+func (c *countdown) Timer() <-chan time.Time {
+	if c == nil {
+		return nil
+	}
+	return c.timer
+}
+
+// scheduleTarget asks the server to change its target after the given delay.
+// The returned message tells whether a pending countdown has been replaced.
+func (s *Server) scheduleTarget(target Target, delay time.Duration) (string, error) {
+	return s.sendCountdownRequest(&countdown{target: target, deadline: time.Now().Add(delay)})
+}
+
+func (s *Server) handleCancelCommand(*commands.Command) (string, error) {
+	return s.sendCountdownRequest(nil)
+}
+
+func (s *Server) sendCountdownRequest(c *countdown) (string, error) {
+	reply := make(chan countdownReply, 1)
+	if err := utils.SendWithTimeout(s.countdowns, &countdownRequest{c, reply}, ConsoleCommandTimeout); err != nil {
+		return "", err
+	}
+	res, err := utils.RecvWithTimeout(reply, ConsoleCommandTimeout)
+	if err != nil {
+		return "", err
+	}
+	return res.message, res.err
+}
+
+func (s *Server) setCountdown(c *countdown) countdownReply {
+	if c == nil {
+		if s.countdown == nil {
+			return countdownReply{err: ErrNoCountdown}
+		}
+		s.cancelCountdown()
+		return countdownReply{}
+	}
+
+	var message string
+	if previous := s.countdown; previous != nil {
+		message = fmt.Sprintf(
+			"The pending server %s, due in %s, has been replaced",
+			previous.target.Noun(),
+			humanDuration(time.Until(previous.deadline)),
+		)
+		s.dropCountdown(c.target)
+	}
+
+	if !s.status.IsRunning() {
+		s.setTarget(c.target)
+		return countdownReply{message: message}
+	}
+
+	s.countdown = c
+	s.tickCountdown()
+	return countdownReply{message: message}
+}
+
+// dropCountdown removes the pending countdown before the server moves to the given target.
+// The countdown is announced as cancelled only if it was heading to another target.
+func (s *Server) dropCountdown(target Target) {
+	if s.countdown.target != target {
+		s.cancelCountdown()
+		return
+	}
+	log.WithField("target", target).Info("server.countdown.replace")
+	s.countdown = nil
+}
+
+func (s *Server) cancelCountdown() {
+	target := s.countdown.target
+	s.countdown = nil
+	log.WithField("target", target).Info("server.countdown.cancel")
+	s.announce(fmt.Sprintf("Server %s cancelled", target.Noun()))
+	s.dispatcher.Dispatch(&CountdownCancelled{target})
+}
+
+func (s *Server) tickCountdown() {
+	c := s.countdown
+	remaining := time.Until(c.deadline).Round(time.Second)
+	if remaining <= 0 {
+		s.countdown = nil
+		s.setTarget(c.target)
+		return
+	}
+
+	notice := &CountdownNotice{c.target, remaining}
+	log.WithFields(notice).Info("server.countdown")
+	s.announce(notice.String())
+	s.dispatcher.Dispatch(notice)
+
+	c.timer = time.After(remaining - s.Server.Countdown.NextStep(remaining))
+}
+
+func (s *Server) announce(message string) {
+	if s.process == nil || !s.status.IsRunning() {
+		return
+	}
+	command := s.Server.Countdown.ConsoleCommand(message)
+	go func() {
+		if _, err := s.ExecuteConsoleCommand(command); err != nil {
+			log.WithError(err).Warn("server.countdown.announce")
+		}
+	}()
+}
+
+func (t Target) Noun() string {
+	switch t {
+	case RestartTarget:
+		return "restart"
+	case StartTarget:
+		return "start"
+	default:
+		return "stop"
+	}
+}
+
+func (n *CountdownNotice) Fields() log.Fields {
+	return log.Fields{"target": n.Target, "remaining": n.Remaining}
+}
+
+func (n *CountdownNotice) String() string {
+	return fmt.Sprintf("Server %s in %s", n.Target.Noun(), humanDuration(n.Remaining))
+}
+
+func (n *CountdownNotice) DiscordNotification() string {
+	return fmt.Sprintf("**%s**", n.String())
+}
+
+func (n *CountdownCancelled) DiscordNotification() string {
+	return fmt.Sprintf("**Server %s cancelled**", n.Target.Noun())
+}
+
+func humanDuration(d time.Duration) string {
+	units := []struct {
+		duration time.Duration
+		name     string
+	}{
+		{time.Hour, "hour"},
+		{time.Minute, "minute"},
+		{time.Second, "second"},
+	}
+
+	var parts []string
+	d = d.Round(time.Second)
+	for _, unit := range units {
+		n := d / unit.duration
+		if n == 0 {
+			continue
+		}
+		d -= n * unit.duration
+		if n > 1 {
+			parts = append(parts, fmt.Sprintf("%d %ss", n, unit.name))
+		} else {
+			parts = append(parts, fmt.Sprintf("%d %s", n, unit.name))
+		}
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,346 @@
+package minecraft
+
+import (
+	"bufio"
+	_ "embed"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/Adirelle/mcvisor/pkg/events"
+	"github.com/apex/log"
+)
+
+type (
+	// DetachedConfig runs the server outside of the process tree of mcvisor, so that it survives mcvisor restarts.
+	//
+	// The server reads its console from a named pipe and its output is read back from its log file. Its PID is
+	// written to a file, so that mcvisor adopts the running server when it starts again instead of launching a
+	// second one. Detached servers are left running when mcvisor is interrupted; `!shutdown` still stops them.
+	DetachedConfig struct {
+		Enabled      bool          `json:"enabled"`
+		PIDFile      string        `json:"pid_file,omitempty"`
+		StdinPipe    string        `json:"stdin_pipe,omitempty"`
+		LogFile      string        `json:"log_file,omitempty"`
+		OutputFile   string        `json:"output_file,omitempty"`
+		PollInterval time.Duration `json:"poll_interval" validate:"gt=0"`
+	}
+
+	// logTailer reads the lines appended to a log file, following its rotations.
+	logTailer struct {
+		path    string
+		file    *os.File
+		info    os.FileInfo
+		reader  *bufio.Reader
+		offset  int64
+		partial string
+	}
+)
+
+const (
+	DefaultPIDFile      = "mcvisor.pid"
+	DefaultStdinPipe    = "mcvisor.stdin"
+	DefaultLogFile      = "logs/latest.log"
+	DefaultOutputFile   = "logs/mcvisor-output.log"
+	DefaultPollInterval = 500 * time.Millisecond
+
+	// stoppingMessage is logged by the server when it stops, e.g. after a "stop" command sent from the game.
+	stoppingMessage = "Stopping server"
+)
+
+var (
+	ErrDetachedUnsupported = errors.New("detached servers are not supported on this platform")
+	ErrUnknownExitStatus   = errors.New("adopted server exited with an unknown status")
+
+	//go:embed log4j_detached.xml
+	log4jDetachedFile []byte
+)
+
+func NewDetachedConfig() *DetachedConfig {
+	return &DetachedConfig{
+		PIDFile:      DefaultPIDFile,
+		StdinPipe:    DefaultStdinPipe,
+		LogFile:      DefaultLogFile,
+		OutputFile:   DefaultOutputFile,
+		PollInterval: DefaultPollInterval,
+	}
+}
+
+// Validate checks that detached servers are supported, if enabled.
+func (c *DetachedConfig) Validate() error {
+	if c.Enabled && !detachedSupported {
+		return ErrDetachedUnsupported
+	}
+	return nil
+}
+
+func (c ServerConfig) AbsPIDFile() string {
+	return absPath(c.AbsWorkingDir(), c.Detached.PIDFile)
+}
+
+func (c ServerConfig) AbsStdinPipe() string {
+	return absPath(c.AbsWorkingDir(), c.Detached.StdinPipe)
+}
+
+func (c ServerConfig) AbsLogFile() string {
+	return absPath(c.AbsWorkingDir(), c.Detached.LogFile)
+}
+
+func (c ServerConfig) AbsOutputFile() string {
+	return absPath(c.AbsWorkingDir(), c.Detached.OutputFile)
+}
+
+// detach sets up the command to run in its own session, reading the named pipe and writing to the output file.
+func (p *process) detach(c *ServerConfig) error {
+	pipe := c.AbsStdinPipe()
+	if err := makeFifo(pipe); err != nil {
+		return fmt.Errorf("could not create %s: %w", pipe, err)
+	}
+	// The server opens the pipe for writing too, so that it does not read EOF when there is no other writer.
+	stdin, err := os.OpenFile(pipe, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	output := c.AbsOutputFile()
+	if err := os.MkdirAll(filepath.Dir(output), 0o755); err != nil {
+		_ = stdin.Close()
+		return err
+	}
+	stdout, err := os.OpenFile(output, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		_ = stdin.Close()
+		return err
+	}
+
+	p.Cmd.Stdin = stdin
+	p.Cmd.Stdout = stdout
+	p.Cmd.Stderr = stdout
+	p.closeAfterStart = []io.Closer{stdin, stdout}
+	setSession(p.Cmd)
+
+	// Open the log before starting the server, so that no line is missed if it rotates the log right away.
+	p.tailer = newLogTailer(c.AbsLogFile())
+	p.detached = c
+	return nil
+}
+
+// started writes the PID file and opens the console of a detached server.
+func (p *process) started() error {
+	c := p.detached
+	if err := os.WriteFile(c.AbsPIDFile(), []byte(strconv.Itoa(p.Process.Pid)+"\n"), 0o644); err != nil {
+		return err
+	}
+	stdin, err := openFifoWriter(c.AbsStdinPipe())
+	if err != nil {
+		return err
+	}
+	p.Stdin = stdin
+	go p.tailer.Run(c.Detached.PollInterval, p.Done, p.dispatchLogLine)
+	return nil
+}
+
+// dispatchLogLine dispatches a line of the log of a detached server, without the prefix its log4j configuration adds
+// to the console output.
+func (p *process) dispatchLogLine(line string) {
+	message := stripLogPrefix(line)
+	if message == stoppingMessage {
+		atomic.StoreInt32(&p.stopping, 1)
+	}
+	p.DispatchStdout(message)
+}
+
+// adoptProcess looks for a detached server left running by a previous instance of mcvisor.
+// It returns nil if there is none. An invalid or stale PID file is removed, so that a new server can be started.
+func adoptProcess(c *Config, d *events.Dispatcher, parser *LogParser) (*process, error) {
+	pidFile := c.Server.AbsPIDFile()
+	pid, err := readPIDFile(pidFile)
+	if err != nil {
+		log.WithError(err).WithField("path", pidFile).Warn("server.adopt.invalid")
+		discardPIDFile(pidFile)
+		return nil, nil
+	} else if pid == 0 {
+		return nil, nil
+	}
+	logger := log.WithField("pid", pid)
+	if !processExists(pid) {
+		logger.Info("server.adopt.stale")
+		discardPIDFile(pidFile)
+		return nil, nil
+	}
+	// The server is the only reader of the pipe: if nobody reads it, the PID has been reused by another process.
+	stdin, err := openFifoWriter(c.Server.AbsStdinPipe())
+	if err != nil {
+		logger.WithError(err).Warn("server.adopt.stale")
+		discardPIDFile(pidFile)
+		return nil, nil
+	}
+	proc, err := os.FindProcess(pid)
+	if err != nil {
+		_ = stdin.Close()
+		return nil, err
+	}
+
+	p := &process{
+		Process:    proc,
+		Done:       make(chan struct{}),
+		Stdin:      stdin,
+		Dispatcher: d,
+		parser:     parser,
+		detached:   c.Server,
+		tailer:     newLogTailer(c.Server.AbsLogFile()),
+		Adopted:    true,
+	}
+	logger.Warn("server.adopt")
+	go p.tailer.Run(c.Server.Detached.PollInterval, p.Done, p.dispatchLogLine)
+	go p.Wait()
+	return p, nil
+}
+
+// waitAdopted polls the adopted process until it exits, since only its parent could wait for it.
+func (p *process) waitAdopted() {
+	ticker := time.NewTicker(p.detached.Detached.PollInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if !processExists(p.Process.Pid) {
+			// A server that was stopping is assumed to have exited normally.
+			if atomic.LoadInt32(&p.stopping) == 0 {
+				p.Err = ErrUnknownExitStatus
+			}
+			return
+		}
+	}
+}
+
+// cleanup closes the console of a detached server once it has exited.
+func (p *process) cleanup() {
+	if p.detached == nil {
+		return
+	}
+	_ = p.Stdin.Close()
+	if err := removePIDFile(p.detached.AbsPIDFile()); err != nil {
+		log.WithError(err).Warn("server.pidfile")
+	}
+}
+
+// readPIDFile returns the PID written in the file, or 0 if the file does not exist.
+func readPIDFile(path string) (int, error) {
+	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(content)))
+	if err != nil || pid <= 0 {
+		return 0, fmt.Errorf("%s: invalid PID: %q", path, strings.TrimSpace(string(content)))
+	}
+	return pid, nil
+}
+
+// discardPIDFile removes a PID file that does not describe a running server. A failure is only logged, since the
+// file is overwritten when the next server starts.
+func discardPIDFile(path string) {
+	if err := removePIDFile(path); err != nil {
+		log.WithError(err).WithField("path", path).Warn("server.pidfile")
+	}
+}
+
+func removePIDFile(path string) error {
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// newLogTailer opens the log file, if it exists, and skips its current content.
+func newLogTailer(path string) *logTailer {
+	t := &logTailer{path: path}
+	t.open()
+	if t.file != nil {
+		t.offset, _ = t.file.Seek(0, io.SeekEnd)
+	}
+	return t
+}
+
+// Run calls f for each line appended to the log file, checking it at every interval until done is closed.
+// It restarts from the beginning of the file when it is rotated or truncated.
+func (t *logTailer) Run(interval time.Duration, done <-chan struct{}, f func(string)) {
+	defer t.close()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		t.read(f)
+		if t.rotated() {
+			t.read(f)
+			t.close()
+		}
+		if t.file == nil {
+			t.open()
+			t.read(f)
+		}
+		select {
+		case <-done:
+			t.read(f)
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (t *logTailer) open() {
+	file, err := os.Open(t.path)
+	if err != nil {
+		return
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return
+	}
+	t.file, t.info, t.reader, t.offset, t.partial = file, info, bufio.NewReader(file), 0, ""
+}
+
+func (t *logTailer) close() {
+	if t.file != nil {
+		_ = t.file.Close()
+		t.file = nil
+	}
+}
+
+// rotated returns whether the path now points to another file, or whether the file has been truncated.
+func (t *logTailer) rotated() bool {
+	if t.file == nil {
+		return false
+	}
+	info, err := os.Stat(t.path)
+	if err != nil {
+		return false
+	}
+	return !os.SameFile(info, t.info) || info.Size() < t.offset
+}
+
+func (t *logTailer) read(f func(string)) {
+	if t.file == nil {
+		return
+	}
+	for {
+		line, err := t.reader.ReadString('\n')
+		t.offset += int64(len(line))
+		if err != nil {
+			// Keep the incomplete line until the end of it is written.
+			t.partial += line
+			if err != io.EOF {
+				log.WithError(err).WithField("path", t.path).Debug("server.tail")
+			}
+			return
+		}
+		f(strings.TrimRight(t.partial+line, "\r\n"))
+		t.partial = ""
+	}
+}
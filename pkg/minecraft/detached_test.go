@@ -0,0 +1,213 @@
+//go:build !windows
+
+package minecraft_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/Adirelle/mcvisor/pkg/commands"
+	"github.com/Adirelle/mcvisor/pkg/events"
+	"github.com/Adirelle/mcvisor/pkg/minecraft"
+	"github.com/thejerf/suture/v4"
+)
+
+// detachedScript mimics a server: it logs its startup, then echoes its console to its log until it reads "stop".
+// It also logs the end of "save-all" and the shutdown of the server.
+const detachedScript = `mkdir -p logs
+echo "[12:00:00] [Server thread/INFO]: Done (0.1s)!" >> logs/latest.log
+while read -r line; do
+	echo "[12:00:01] [Server thread/INFO]: > $line" >> logs/latest.log
+	[ "$line" = save-all ] && echo "[12:00:01] [Server thread/INFO]: Saved the game" >> logs/latest.log
+	[ "$line" = stop ] && echo "[12:00:01] [Server thread/INFO]: Stopping server" >> logs/latest.log && sleep 0.2 && exit 0
+done`
+
+type detachedServer struct {
+	*minecraft.Server
+	dispatcher *events.Dispatcher
+	statuses   chan minecraft.Status
+	errors     chan error
+}
+
+func startDetachedServer(t *testing.T, ctx context.Context, config *minecraft.Config) *detachedServer {
+	t.Helper()
+	dispatcher := events.NewDispatcher()
+	s := &detachedServer{
+		Server:     minecraft.NewServer(config, dispatcher, commands.NewRegistry()),
+		dispatcher: dispatcher,
+		statuses:   events.MakeHandler[minecraft.Status](),
+		errors:     make(chan error, 1),
+	}
+	t.Cleanup(dispatcher.Subscribe(s.statuses).Cancel)
+	go func() { s.errors <- s.Serve(ctx) }()
+	s.Start()
+	return s
+}
+
+func (s *detachedServer) waitFor(t *testing.T, ctx context.Context, status minecraft.Status) {
+	t.Helper()
+	for {
+		select {
+		case current := <-s.statuses:
+			if current == status {
+				return
+			}
+		case err := <-s.errors:
+			t.Fatalf("server stopped while waiting for %s: %s", status, err)
+		case <-ctx.Done():
+			t.Fatalf("server is not %s", status)
+		}
+	}
+}
+
+func TestDetachedServer(t *testing.T) {
+	t.Parallel()
+
+	config := minecraft.NewConfig(t.TempDir())
+	config.Java.Home = t.TempDir()
+	config.Server.Launcher.Type = minecraft.CustomLauncher
+	config.Server.Launcher.Command = []string{"/bin/sh", "-c", detachedScript}
+	config.Server.Readiness.Conditions = []minecraft.ReadinessCondition{minecraft.LogCondition}
+	config.Server.Detached.Enabled = true
+	config.Server.Detached.PollInterval = 10 * time.Millisecond
+	if err := minecraft.AcceptEULA(config.Server.AbsEULAFile(), "test", time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	first := startDetachedServer(t, ctx, config)
+	first.waitFor(t, ctx, minecraft.Ready)
+	pid := first.PID()
+	t.Cleanup(func() { _ = syscall.Kill(pid, syscall.SIGKILL) })
+
+	content, err := os.ReadFile(config.Server.AbsPIDFile())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.TrimSpace(string(content)) != strconv.Itoa(pid) {
+		t.Errorf("expected PID file to contain %d, got %q", pid, content)
+	}
+	if output, err := first.ExecuteConsoleCommand("list"); err != nil || !strings.Contains(output, "> list") {
+		t.Errorf("unexpected console output: %q, %v", output, err)
+	}
+	lines, err := first.CollectConsoleOutput("save-all", regexp.MustCompile(`^Saved the game$`), 5*time.Second)
+	if err != nil || len(lines) != 2 || lines[0] != "> save-all" {
+		t.Errorf("unexpected save-all output: %q, %v", lines, err)
+	}
+
+	first.Detach()
+	if err := <-first.errors; !errors.Is(err, suture.ErrTerminateSupervisorTree) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := syscall.Kill(pid, 0); err != nil {
+		t.Fatalf("detached server is not running: %s", err)
+	}
+
+	second := startDetachedServer(t, ctx, config)
+	second.waitFor(t, ctx, minecraft.Ready)
+	if second.PID() != pid {
+		t.Errorf("expected the server %d to be adopted, got %d", pid, second.PID())
+	}
+	if output, err := second.ExecuteConsoleCommand("seed"); err != nil || !strings.Contains(output, "> seed") {
+		t.Errorf("unexpected console output: %q, %v", output, err)
+	}
+
+	second.Shutdown()
+	if err := <-second.errors; !errors.Is(err, suture.ErrTerminateSupervisorTree) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := os.Stat(config.Server.AbsPIDFile()); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected the PID file to be removed, got %v", err)
+	}
+}
+
+func TestDetachedServerInvalidPIDFile(t *testing.T) {
+	t.Parallel()
+
+	config := minecraft.NewConfig(t.TempDir())
+	config.Java.Home = t.TempDir()
+	config.Server.Launcher.Type = minecraft.CustomLauncher
+	config.Server.Launcher.Command = []string{"/bin/sh", "-c", detachedScript}
+	config.Server.Readiness.Conditions = []minecraft.ReadinessCondition{minecraft.LogCondition}
+	config.Server.Detached.Enabled = true
+	config.Server.Detached.PollInterval = 10 * time.Millisecond
+	config.Server.Preflight.Ports = false
+	if err := minecraft.AcceptEULA(config.Server.AbsEULAFile(), "test", time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(config.Server.AbsPIDFile(), []byte("garbage\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	server := startDetachedServer(t, ctx, config)
+	server.waitFor(t, ctx, minecraft.Ready)
+	pid := server.PID()
+	t.Cleanup(func() { _ = syscall.Kill(pid, syscall.SIGKILL) })
+
+	content, err := os.ReadFile(config.Server.AbsPIDFile())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.TrimSpace(string(content)) != strconv.Itoa(pid) {
+		t.Errorf("expected PID file to contain %d, got %q", pid, content)
+	}
+
+	server.Shutdown()
+	if err := <-server.errors; !errors.Is(err, suture.ErrTerminateSupervisorTree) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestDetachedServerAdoptedStop(t *testing.T) {
+	t.Parallel()
+
+	config := minecraft.NewConfig(t.TempDir())
+	config.Java.Home = t.TempDir()
+	config.Server.Launcher.Type = minecraft.CustomLauncher
+	config.Server.Launcher.Command = []string{"/bin/sh", "-c", detachedScript}
+	config.Server.Readiness.Conditions = []minecraft.ReadinessCondition{minecraft.LogCondition}
+	config.Server.Detached.Enabled = true
+	config.Server.Detached.PollInterval = 10 * time.Millisecond
+	config.Server.Preflight.Ports = false
+	if err := minecraft.AcceptEULA(config.Server.AbsEULAFile(), "test", time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	first := startDetachedServer(t, ctx, config)
+	first.waitFor(t, ctx, minecraft.Ready)
+	pid := first.PID()
+	t.Cleanup(func() { _ = syscall.Kill(pid, syscall.SIGKILL) })
+	first.Detach()
+	<-first.errors
+
+	second := startDetachedServer(t, ctx, config)
+	exits := events.MakeHandler[*minecraft.UnexpectedExit]()
+	t.Cleanup(second.dispatcher.Subscribe(exits).Cancel)
+	second.waitFor(t, ctx, minecraft.Ready)
+
+	// The server is stopped from the game, not by mcvisor.
+	_, _ = second.ExecuteConsoleCommand("stop")
+	second.waitFor(t, ctx, minecraft.Stopped)
+	select {
+	case exit := <-exits:
+		t.Errorf("a clean stop was reported as a crash: %+v", exit)
+	case <-time.After(200 * time.Millisecond):
+	}
+	second.Shutdown()
+	<-second.errors
+}
@@ -0,0 +1,42 @@
+//go:build !windows
+
+package minecraft
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+const detachedSupported = true
+
+// makeFifo creates the named pipe, unless it already exists.
+func makeFifo(path string) error {
+	info, err := os.Stat(path)
+	switch {
+	case err == nil && info.Mode()&os.ModeNamedPipe != 0:
+		return nil
+	case err == nil:
+		return fmt.Errorf("%s exists and is not a named pipe", path)
+	case !errors.Is(err, os.ErrNotExist):
+		return err
+	}
+	return syscall.Mkfifo(path, 0o600)
+}
+
+// openFifoWriter opens the named pipe for writing. It fails, instead of blocking, if nobody reads the pipe.
+func openFifoWriter(path string) (*os.File, error) {
+	return os.OpenFile(path, os.O_WRONLY|syscall.O_NONBLOCK, 0)
+}
+
+// setSession starts the command in a new session, so that it does not receive the signals sent to mcvisor.
+func setSession(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+}
+
+func processExists(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
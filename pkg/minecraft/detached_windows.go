@@ -0,0 +1,24 @@
+//go:build windows
+
+package minecraft
+
+import (
+	"os"
+	"os/exec"
+)
+
+const detachedSupported = false
+
+func makeFifo(string) error {
+	return ErrDetachedUnsupported
+}
+
+func openFifoWriter(string) (*os.File, error) {
+	return nil, ErrDetachedUnsupported
+}
+
+func setSession(*exec.Cmd) {}
+
+func processExists(int) bool {
+	return false
+}
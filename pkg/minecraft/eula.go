@@ -0,0 +1,130 @@
+package minecraft
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Adirelle/mcvisor/pkg/commands"
+	"github.com/Adirelle/mcvisor/pkg/discord"
+	"github.com/Adirelle/mcvisor/pkg/utils"
+	"github.com/apex/log"
+)
+
+type (
+	// EULANotAccepted is dispatched when the server cannot be started because the EULA has not been accepted.
+	EULANotAccepted struct {
+		Path string
+	}
+
+	// EULAAccepted is dispatched when the EULA is accepted with the `eula accept` command.
+	EULAAccepted struct {
+		Path  string
+		Actor string
+	}
+)
+
+const (
+	EULAFile = "eula.txt"
+	EULAURL  = "https://aka.ms/MinecraftEULA"
+
+	EULACommand commands.Name = "eula"
+)
+
+var (
+	// Interface checks
+	_ discord.Notification = (*EULANotAccepted)(nil)
+	_ discord.Notification = (*EULAAccepted)(nil)
+	_ log.Fielder          = (*EULANotAccepted)(nil)
+	_ log.Fielder          = (*EULAAccepted)(nil)
+
+	ErrEULAUsage = errors.New("usage: `eula` or `eula accept`")
+)
+
+func (c ServerConfig) AbsEULAFile() string {
+	return absPath(c.AbsWorkingDir(), EULAFile)
+}
+
+// IsEULAAccepted reads eula.txt. A missing file means that the EULA has not been accepted.
+func IsEULAAccepted(path string) (bool, error) {
+	file, err := LoadPropertiesFile(path)
+	if err != nil {
+		return false, err
+	}
+	value, _ := file.Get("eula")
+	return strings.EqualFold(strings.TrimSpace(value), "true"), nil
+}
+
+// AcceptEULA writes eula.txt, recording who accepted the EULA and when.
+func AcceptEULA(path string, actor string, when time.Time) error {
+	content := fmt.Sprintf(
+		"#By changing the setting below to TRUE you are indicating your agreement to our EULA (%s).\n#Accepted by %s through mcvisor on %s\neula=true\n",
+		EULAURL,
+		actor,
+		when.UTC().Format(time.RFC3339),
+	)
+	return os.WriteFile(path, []byte(content), 0o644)
+}
+
+// checkEULA returns whether the server can be started, notifying why it cannot.
+func (s *Server) checkEULA() bool {
+	path := s.Server.AbsEULAFile()
+	accepted, err := IsEULAAccepted(path)
+	if err != nil {
+		log.WithError(err).WithField("path", path).Warn("server.eula")
+	}
+	if !accepted {
+		event := &EULANotAccepted{path}
+		log.WithFields(event).Error("server.eula")
+		s.dispatcher.Dispatch(event)
+	}
+	return accepted
+}
+
+func (s *Server) handleEULACommand(cmd *commands.Command) (string, error) {
+	path := s.Server.AbsEULAFile()
+	switch {
+	case len(cmd.Arguments) == 0 || cmd.Arguments[0] == "":
+		accepted, err := IsEULAAccepted(path)
+		if err != nil {
+			return "", err
+		}
+		if accepted {
+			return "The EULA has been accepted", nil
+		}
+		return fmt.Sprintf("The EULA (<%s>) has not been accepted, use `eula accept` to accept it", EULAURL), nil
+	case len(cmd.Arguments) == 1 && cmd.Arguments[0] == "accept":
+		event := &EULAAccepted{path, commands.ActorName(cmd.Actor)}
+		if err := AcceptEULA(path, event.Actor, time.Now()); err != nil {
+			log.WithError(err).WithField("path", path).Error("server.eula")
+			return "", err
+		}
+		log.WithFields(event).Warn("server.eula.accepted")
+		s.dispatcher.Dispatch(event)
+		return "EULA accepted, starting the server", utils.SendWithTimeout(s.targets, StartTarget, ConsoleCommandTimeout)
+	default:
+		return "", ErrEULAUsage
+	}
+}
+
+func (e *EULANotAccepted) Fields() log.Fields {
+	return log.Fields{"path": e.Path}
+}
+
+func (e *EULANotAccepted) DiscordNotification() string {
+	return fmt.Sprintf(
+		"**Cannot start the server: the Minecraft EULA (<%s>) has not been accepted in %s.** An administrator can accept it with the `eula accept` command.",
+		EULAURL,
+		EULAFile,
+	)
+}
+
+func (e *EULAAccepted) Fields() log.Fields {
+	return log.Fields{"path": e.Path, "actor": e.Actor}
+}
+
+func (e *EULAAccepted) DiscordNotification() string {
+	return fmt.Sprintf("**Minecraft EULA accepted by %s**", e.Actor)
+}
@@ -0,0 +1,115 @@
+package minecraft_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Adirelle/mcvisor/pkg/commands"
+	"github.com/Adirelle/mcvisor/pkg/events"
+	"github.com/Adirelle/mcvisor/pkg/minecraft"
+)
+
+func TestEULA(t *testing.T) {
+	t.Parallel()
+
+	config := minecraft.NewConfig(t.TempDir())
+	config.Java.Home = t.TempDir()
+	path := config.Server.AbsEULAFile()
+	if err := os.WriteFile(path, []byte("eula=false\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	dispatcher := events.NewDispatcher()
+	refusals := events.MakeHandler[*minecraft.EULANotAccepted]()
+	defer dispatcher.Subscribe(refusals).Cancel()
+	acceptances := events.MakeHandler[*minecraft.EULAAccepted]()
+	defer dispatcher.Subscribe(acceptances).Cancel()
+	targets := events.MakeHandler[minecraft.Target]()
+	defer dispatcher.Subscribe(targets).Cancel()
+
+	registry := commands.NewRegistry()
+	server := minecraft.NewServer(config, dispatcher, registry)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	go func() { _ = server.Serve(ctx) }()
+	server.Start()
+
+	select {
+	case refusal := <-refusals:
+		if refusal.Path != path {
+			t.Errorf("unexpected path: %s", refusal.Path)
+		}
+	case <-ctx.Done():
+		t.Fatal("the server started without accepting the EULA")
+	}
+
+	reply, err := registry.HandleCommand(commands.NewCommand("eula accept", commands.System))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(reply, "accepted") {
+		t.Errorf("unexpected reply: %s", reply)
+	}
+	select {
+	case acceptance := <-acceptances:
+		if acceptance.Actor != "mcvisor" {
+			t.Errorf("unexpected actor: %s", acceptance.Actor)
+		}
+	case <-ctx.Done():
+		t.Fatal("no acceptance event")
+	}
+
+	accepted, err := minecraft.IsEULAAccepted(path)
+	if err != nil || !accepted {
+		t.Errorf("expected the EULA to be accepted, got %v, %v", accepted, err)
+	}
+	content, _ := os.ReadFile(path)
+	if !strings.Contains(string(content), "#Accepted by mcvisor") {
+		t.Errorf("expected the acceptance to be recorded, got:\n%s", content)
+	}
+
+	for {
+		select {
+		case target := <-targets:
+			if target == minecraft.StartTarget {
+				return
+			}
+		case <-ctx.Done():
+			t.Fatal("the server was not started")
+		}
+	}
+}
+
+func TestIsEULAAccepted(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	cases := map[string]bool{
+		"":                           false,
+		"eula=false\n":               false,
+		"#comment\neula=TRUE\n":      true,
+		"eula = true\n":              true,
+		"something=else\n":           false,
+		"eula=true\neula=false\n":    false,
+		"#Accepted\r\neula=true\r\n": true,
+	}
+	i := 0
+	for content, expected := range cases {
+		i++
+		path := filepath.Join(dir, fmt.Sprintf("eula%d.txt", i))
+		if content != "" {
+			if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if accepted, err := minecraft.IsEULAAccepted(path); err != nil || accepted != expected {
+			t.Errorf("%q: expected %v, got %v, %v", content, expected, accepted, err)
+		}
+	}
+}
@@ -0,0 +1,21 @@
+package minecraft
+
+// Exported for testing
+var FindProcess = findProcess
+
+type Restarter = restarter
+
+func NewRestarter(config *RestartConfig) *Restarter {
+	return &restarter{RestartConfig: config}
+}
+
+type RconTransport = rconTransport
+
+func NewRconTransport(config *ServerConfig) *RconTransport {
+	return &rconTransport{ServerConfig: config}
+}
+
+func (c *Config) WithJavaRuntime() (*Config, *JavaRuntime, error) {
+	c, runtime, _, err := c.withJavaRuntime()
+	return c, runtime, err
+}
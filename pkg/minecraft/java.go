@@ -0,0 +1,248 @@
+package minecraft
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/apex/log"
+)
+
+type (
+	// JavaRuntime is a Java installation, as described by its release file.
+	JavaRuntime struct {
+		Home    string
+		Version string
+		Major   int
+		Vendor  string
+	}
+
+	// MinecraftVersion is the version information embedded in server jars.
+	MinecraftVersion struct {
+		ID          string `json:"id"`
+		Name        string `json:"name"`
+		JavaVersion int    `json:"java_version"`
+	}
+)
+
+const (
+	JavaReleaseFile     = "release"
+	VersionJSONEntry    = "version.json"
+	sdkmanJavaSubdir    = ".sdkman/candidates/java"
+	macOSJavaHomeSubdir = "Contents/Home"
+)
+
+var (
+	ErrNoJavaRuntime = errors.New("no compatible Java runtime found")
+)
+
+// ReadJavaRuntime reads the release file of a Java installation.
+func ReadJavaRuntime(home string) (*JavaRuntime, error) {
+	file, err := os.Open(filepath.Join(home, JavaReleaseFile))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	runtime := &JavaRuntime{Home: home}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		key, value, found := strings.Cut(scanner.Text(), "=")
+		if !found {
+			continue
+		}
+		value = strings.Trim(value, `"`)
+		switch key {
+		case "JAVA_VERSION":
+			runtime.Version = value
+			runtime.Major = parseJavaMajor(value)
+		case "IMPLEMENTOR":
+			runtime.Vendor = value
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if runtime.Major == 0 {
+		return nil, fmt.Errorf("%s: no JAVA_VERSION", file.Name())
+	}
+	return runtime, nil
+}
+
+// parseJavaMajor extracts the major version from "1.8.0_292" or "17.0.2".
+func parseJavaMajor(version string) int {
+	parts := strings.FieldsFunc(version, func(r rune) bool { return r == '.' || r == '_' || r == '+' || r == '-' })
+	if len(parts) == 0 {
+		return 0
+	}
+	major, _ := strconv.Atoi(parts[0])
+	if major == 1 && len(parts) > 1 {
+		major, _ = strconv.Atoi(parts[1])
+	}
+	return major
+}
+
+// FindJavaRuntimes lists the Java installations found in the given directories, and their subdirectories.
+func FindJavaRuntimes(dirs []string) []*JavaRuntime {
+	var runtimes []*JavaRuntime
+	seen := make(map[string]bool)
+	add := func(home string) {
+		if resolved, err := filepath.EvalSymlinks(home); err == nil {
+			home = resolved
+		}
+		if seen[home] {
+			return
+		}
+		seen[home] = true
+		if runtime, err := ReadJavaRuntime(home); err == nil {
+			runtimes = append(runtimes, runtime)
+		}
+	}
+	for _, dir := range dirs {
+		add(dir)
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			home := filepath.Join(dir, entry.Name())
+			add(home)
+			add(filepath.Join(home, filepath.FromSlash(macOSJavaHomeSubdir)))
+		}
+	}
+	return runtimes
+}
+
+// ReadMinecraftVersion reads version.json from a server jar.
+func ReadMinecraftVersion(jar string) (*MinecraftVersion, error) {
+	archive, err := zip.OpenReader(jar)
+	if err != nil {
+		return nil, err
+	}
+	defer archive.Close()
+
+	file, err := archive.Open(VersionJSONEntry)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	version := &MinecraftVersion{}
+	if err := json.NewDecoder(file).Decode(version); err != nil {
+		return nil, fmt.Errorf("%s: %w", VersionJSONEntry, err)
+	}
+	return version, nil
+}
+
+// runtimeDirs returns the directories to scan for Java installations: the configured ones,
+// then the JAVA_HOME environment variable, the usual system locations and SDKMAN.
+func (c JavaConfig) runtimeDirs() []string {
+	dirs := append([]string{}, c.SearchDirs...)
+	if home := os.Getenv(JaveHomeEnvName); home != "" {
+		dirs = append(dirs, home)
+	}
+	dirs = append(dirs, DefaultJavaSearchDirs...)
+	if userHome, err := os.UserHomeDir(); err == nil {
+		dirs = append(dirs, filepath.Join(userHome, filepath.FromSlash(sdkmanJavaSubdir)))
+	}
+	return dirs
+}
+
+// SelectRuntime returns the Java runtime to use for a server requiring the given major version (0 if unknown).
+// The configured home is always used when it is set. Otherwise, the runtime is selected among the detected ones,
+// favoring the required version, then the closest newer one.
+func (c JavaConfig) SelectRuntime(required int) (*JavaRuntime, error) {
+	if c.Home != "" {
+		runtime, err := ReadJavaRuntime(c.Home)
+		if err != nil {
+			log.WithError(err).WithField("home", c.Home).Warn("java.version")
+			return &JavaRuntime{Home: c.Home}, nil
+		}
+		if required > 0 && runtime.Major < required {
+			log.WithFields(runtime).WithField("required", required).Warn("java.incompatible")
+		}
+		return runtime, nil
+	}
+
+	runtimes := FindJavaRuntimes(c.runtimeDirs())
+	if required == 0 {
+		// The order of the search directories prevails.
+		if len(runtimes) > 0 {
+			return runtimes[0], nil
+		}
+		return nil, ErrNoJavaRuntime
+	}
+
+	var compatible []*JavaRuntime
+	for _, runtime := range runtimes {
+		if runtime.Major >= required {
+			compatible = append(compatible, runtime)
+		}
+	}
+	if len(compatible) == 0 {
+		return nil, fmt.Errorf("%w: Java %d or newer is required", ErrNoJavaRuntime, required)
+	}
+	sort.SliceStable(compatible, func(i, j int) bool {
+		return compatible[i].Major < compatible[j].Major
+	})
+	return compatible[0], nil
+}
+
+func (r *JavaRuntime) String() string {
+	version := r.Version
+	if version == "" {
+		version = "unknown version"
+	}
+	if r.Vendor != "" {
+		return fmt.Sprintf("Java %s (%s)", version, r.Vendor)
+	}
+	return fmt.Sprintf("Java %s", version)
+}
+
+func (r *JavaRuntime) Fields() log.Fields {
+	return log.Fields{
+		"home":    r.Home,
+		"version": r.Version,
+		"vendor":  r.Vendor,
+	}
+}
+
+func (v *MinecraftVersion) String() string {
+	if v.Name != "" {
+		return fmt.Sprintf("Minecraft %s", v.Name)
+	}
+	return fmt.Sprintf("Minecraft %s", v.ID)
+}
+
+// withJavaRuntime selects the Java runtime required by the server jar, and returns a copy of the configuration that
+// uses it. The Minecraft version is nil if it could not be read from the jar. Script and custom launchers are started
+// without a runtime if none is found.
+func (c *Config) withJavaRuntime() (*Config, *JavaRuntime, *MinecraftVersion, error) {
+	required := 0
+	version, err := ReadMinecraftVersion(c.Server.AbsJar())
+	if err != nil {
+		log.WithError(err).WithField("jar", c.Server.AbsJar()).Debug("server.version")
+	} else {
+		required = version.JavaVersion
+	}
+
+	runtime, err := c.Java.SelectRuntime(required)
+	if err != nil {
+		if launcher := c.Server.Launcher.Type; launcher != ScriptLauncher && launcher != CustomLauncher {
+			return nil, nil, nil, err
+		}
+		// Scripts and custom commands may find a runtime on their own.
+		log.WithError(err).WithField("launcher", c.Server.Launcher.Type).Warn("java.missing")
+		runtime = &JavaRuntime{}
+	}
+	java := *c.Java
+	java.Home = runtime.Home
+	return &Config{Server: c.Server, Java: &java}, runtime, version, nil
+}
@@ -0,0 +1,114 @@
+package minecraft_test
+
+import (
+	"archive/zip"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Adirelle/mcvisor/pkg/minecraft"
+)
+
+func writeJavaRuntime(t *testing.T, home, version string) {
+	t.Helper()
+	if err := os.MkdirAll(home, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	release := "IMPLEMENTOR=\"Test\"\nJAVA_VERSION=\"" + version + "\"\n"
+	if err := os.WriteFile(filepath.Join(home, minecraft.JavaReleaseFile), []byte(release), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func writeServerJar(t *testing.T, jar, versionJSON string) {
+	t.Helper()
+	file, err := os.Create(jar)
+	if err != nil {
+		t.Fatal(err)
+	}
+	archive := zip.NewWriter(file)
+	entry, err := archive.Create(minecraft.VersionJSONEntry)
+	if err == nil {
+		_, err = entry.Write([]byte(versionJSON))
+	}
+	if err == nil {
+		err = archive.Close()
+	}
+	if err == nil {
+		err = file.Close()
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSelectJavaRuntime(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	writeJavaRuntime(t, filepath.Join(dir, "java-8"), "1.8.0_292")
+	writeJavaRuntime(t, filepath.Join(dir, "java-17"), "17.0.2")
+	writeJavaRuntime(t, filepath.Join(dir, "java-21"), "21")
+
+	config := &minecraft.JavaConfig{SearchDirs: []string{dir}}
+	cases := map[int]string{8: "1.8.0_292", 16: "17.0.2", 17: "17.0.2", 21: "21"}
+	for required, expected := range cases {
+		runtime, err := config.SelectRuntime(required)
+		if err != nil {
+			t.Errorf("Java %d: %s", required, err)
+		} else if runtime.Version != expected {
+			t.Errorf("Java %d: expected %s, got %s", required, expected, runtime.Version)
+		}
+	}
+
+	config.Home = filepath.Join(dir, "java-8")
+	if runtime, err := config.SelectRuntime(17); err != nil || runtime.Major != 8 {
+		t.Errorf("the configured home should be used, got %v, %v", runtime, err)
+	}
+}
+
+func TestReadMinecraftVersion(t *testing.T) {
+	t.Parallel()
+
+	jar := filepath.Join(t.TempDir(), "server.jar")
+	writeServerJar(t, jar, `{"id": "1.20.1", "name": "1.20.1", "java_version": 17}`)
+
+	version, err := minecraft.ReadMinecraftVersion(jar)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version.ID != "1.20.1" || version.JavaVersion != 17 {
+		t.Errorf("unexpected version: %+v", version)
+	}
+}
+
+func TestMissingJavaRuntime(t *testing.T) {
+	t.Parallel()
+
+	config := minecraft.NewConfig(t.TempDir())
+	writeServerJar(t, config.Server.AbsJar(), `{"id": "99.0", "java_version": 999}`)
+
+	if _, _, err := config.WithJavaRuntime(); !errors.Is(err, minecraft.ErrNoJavaRuntime) {
+		t.Errorf("jar launcher: expected ErrNoJavaRuntime, got %v", err)
+	}
+
+	config.Server.Launcher.Type = minecraft.CustomLauncher
+	config.Server.Launcher.Command = []string{"{java}", "-jar", "{jar}"}
+	withRuntime, runtime, err := config.WithJavaRuntime()
+	if err != nil {
+		t.Fatalf("custom launcher: %s", err)
+	}
+	if runtime.Home != "" {
+		t.Errorf("custom launcher: expected no runtime, got %v", runtime)
+	}
+	if cmdLine, err := withRuntime.Command(); err != nil || cmdLine[0] != filepath.Base(minecraft.JavaCmd) {
+		t.Errorf("custom launcher: the Java command should be looked up in the PATH, got %v (%v)", cmdLine, err)
+	}
+	for _, variable := range withRuntime.Env() {
+		if variable == minecraft.JaveHomeEnvName+"=" || strings.HasPrefix(variable, "PATH=bin") {
+			t.Errorf("custom launcher: unexpected %q", variable)
+		}
+	}
+}
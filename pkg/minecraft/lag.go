@@ -0,0 +1,422 @@
+package minecraft
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Adirelle/mcvisor/pkg/commands"
+	"github.com/Adirelle/mcvisor/pkg/discord"
+	"github.com/Adirelle/mcvisor/pkg/events"
+	"github.com/apex/log"
+	"github.com/thejerf/suture/v4"
+)
+
+type (
+	LagConfig struct {
+		// PollInterval is the interval between two polls of the TPS commands; 0 disables polling.
+		PollInterval time.Duration `json:"poll_interval"`
+		// Commands are the console commands that report the TPS or the tick duration; the ones that are not supported
+		// by the server are ignored.
+		Commands []string `json:"commands"`
+		// Window is the duration over which the lag must be sustained to trigger an alert.
+		Window time.Duration `json:"window" validate:"gt=0"`
+		// MinTPS is the average TPS under which an alert is sent; 0 disables alerts.
+		MinTPS float64 `json:"min_tps" validate:"gte=0,lte=20"`
+	}
+
+	// LagTracker keeps track of the server lag, using its "Can't keep up!" warnings and polling TPS commands.
+	LagTracker struct {
+		*LagConfig
+		dispatcher  *events.Dispatcher
+		console     Console
+		statuser    Statuser
+		warnings    chan CantKeepUp
+		polls       chan *lagPoll
+		polling     bool
+		unsupported map[string]bool
+		readySince  time.Time
+		alerted     bool
+
+		mu      sync.Mutex
+		samples []*LagSample
+		// estimate is true when the TPS cannot be polled, and must be estimated from the warnings.
+		estimate bool
+	}
+
+	lagPoll struct {
+		sample      *LagSample
+		unsupported []string
+	}
+
+	// LagSample is either a "Can't keep up!" warning (Source is "log"), or the result of polling the TPS commands.
+	LagSample struct {
+		When        time.Time
+		Source      string
+		TPS         float64
+		MSPT        float64
+		Behind      time.Duration
+		TicksBehind int
+	}
+
+	LagAlert struct {
+		TPS    float64
+		Window time.Duration
+	}
+
+	LagRecovered struct {
+		TPS float64
+	}
+
+	tpsParser func(output string) (tps, mspt float64, ok bool)
+)
+
+const (
+	TPSCommand commands.Name = "tps"
+
+	LogLagSource = "log"
+
+	MaxTPS = 20.0
+
+	// lagHistory is the duration for which the samples are kept.
+	lagHistory = 15 * time.Minute
+)
+
+var (
+	// Interface checks
+	_ suture.Service       = (*LagTracker)(nil)
+	_ log.Fielder          = (*LagSample)(nil)
+	_ discord.Notification = (*LagAlert)(nil)
+	_ discord.Notification = (*LagRecovered)(nil)
+
+	unknownCommandRegexp = regexp.MustCompile(`(?i)unknown (?:or incomplete )?command`)
+
+	// Paper/Spigot: "TPS from last 1m, 5m, 15m: 20.0, *20.0, 19.98"
+	paperTPSRegexp = regexp.MustCompile(`TPS from last 1m, 5m, 15m: \*?(\d+(?:\.\d+)?)`)
+	// Forge: "Overall: Mean tick time: 1.234 ms. Mean TPS: 20.000" or "Overall: 20.000 TPS (1.234 ms/tick)"
+	forgeTPSRegexp    = regexp.MustCompile(`Overall\s*: Mean tick time: (\d+(?:\.\d+)?) ms\. Mean TPS: (\d+(?:\.\d+)?)`)
+	newForgeTPSRegexp = regexp.MustCompile(`Overall\s*: (\d+(?:\.\d+)?) TPS \((\d+(?:\.\d+)?) ms/tick\)`)
+	// Paper: "Server tick times (avg/min/max) from last 5s, 10s, 1m:" followed by "◴ 1.2/0.5/3.4, ..."
+	paperMSPTRegexp = regexp.MustCompile(`(\d+(?:\.\d+)?)/\d+(?:\.\d+)?/\d+(?:\.\d+)?,`)
+	// Vanilla (1.20.3+): "Average time per tick: 2.3ms (Target: 50.0ms)"
+	vanillaMSPTRegexp = regexp.MustCompile(`Average time per tick: (\d+(?:\.\d+)?)ms`)
+
+	tpsParsers = []tpsParser{
+		func(output string) (float64, float64, bool) {
+			if m := paperTPSRegexp.FindStringSubmatch(output); m != nil {
+				return parseFloat(m[1]), 0, true
+			}
+			return 0, 0, false
+		},
+		func(output string) (float64, float64, bool) {
+			if m := forgeTPSRegexp.FindStringSubmatch(output); m != nil {
+				return parseFloat(m[2]), parseFloat(m[1]), true
+			}
+			if m := newForgeTPSRegexp.FindStringSubmatch(output); m != nil {
+				return parseFloat(m[1]), parseFloat(m[2]), true
+			}
+			return 0, 0, false
+		},
+		func(output string) (float64, float64, bool) {
+			if m := vanillaMSPTRegexp.FindStringSubmatch(output); m != nil {
+				return 0, parseFloat(m[1]), true
+			}
+			if m := paperMSPTRegexp.FindStringSubmatch(output); m != nil {
+				return 0, parseFloat(m[1]), true
+			}
+			return 0, 0, false
+		},
+	}
+)
+
+func NewLagConfig() *LagConfig {
+	return &LagConfig{
+		PollInterval: time.Minute,
+		Commands:     []string{"tps", "forge tps", "mspt", "tick query"},
+		Window:       5 * time.Minute,
+		MinTPS:       15,
+	}
+}
+
+func NewLagTracker(config *LagConfig, console Console, statuser Statuser, dispatcher *events.Dispatcher, registry *commands.Registry) *LagTracker {
+	t := &LagTracker{
+		LagConfig:   config,
+		dispatcher:  dispatcher,
+		console:     console,
+		statuser:    statuser,
+		warnings:    events.MakeHandler[CantKeepUp](),
+		polls:       make(chan *lagPoll),
+		unsupported: make(map[string]bool),
+	}
+	t.estimate = len(t.supportedCommands()) == 0
+	registry.Register(TPSCommand, "show the recent server TPS and lag", discord.QueryCategory, commands.HandlerFunc(t.handleTPSCommand))
+	return t
+}
+
+func (t *LagTracker) Serve(ctx context.Context) error {
+	defer t.dispatcher.Subscribe(t.warnings).Cancel()
+
+	var ticks <-chan time.Time
+	if t.PollInterval > 0 {
+		ticker := time.NewTicker(t.PollInterval)
+		defer ticker.Stop()
+		ticks = ticker.C
+	}
+	check := time.NewTicker(t.Window / 10)
+	defer check.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case warning := <-t.warnings:
+			t.add(&LagSample{
+				When:        time.Now(),
+				Source:      LogLagSource,
+				Behind:      warning.Lag,
+				TicksBehind: warning.TicksBehind,
+			})
+		case result := <-t.polls:
+			t.polling = false
+			for _, command := range result.unsupported {
+				t.unsupported[command] = true
+			}
+			t.setEstimate(len(t.supportedCommands()) == 0)
+			if result.sample != nil {
+				t.add(result.sample)
+			}
+		case when := <-ticks:
+			if t.updateReadiness(when) && !t.polling {
+				t.polling = true
+				go t.poll(ctx, t.supportedCommands())
+			}
+		case when := <-check.C:
+			if t.updateReadiness(when) {
+				t.checkAlert(when)
+			}
+		}
+	}
+}
+
+// updateReadiness tracks since when the server is ready, and returns whether it is.
+// The supported commands are detected again after each restart.
+func (t *LagTracker) updateReadiness(when time.Time) bool {
+	if t.statuser.Status() != Ready {
+		if !t.readySince.IsZero() {
+			t.readySince = time.Time{}
+			t.unsupported = make(map[string]bool)
+			t.setEstimate(len(t.supportedCommands()) == 0)
+		}
+		return false
+	}
+	if t.readySince.IsZero() {
+		t.readySince = when
+	}
+	return true
+}
+
+func (t *LagTracker) supportedCommands() []string {
+	if t.PollInterval == 0 {
+		return nil
+	}
+	var supported []string
+	for _, command := range t.Commands {
+		if !t.unsupported[command] {
+			supported = append(supported, command)
+		}
+	}
+	return supported
+}
+
+// poll sends the commands to the server and merges their results in a single sample.
+func (t *LagTracker) poll(ctx context.Context, commands []string) {
+	result := &lagPoll{}
+	var sample *LagSample
+	for _, command := range commands {
+		output, err := t.console.ExecuteConsoleCommand(command)
+		if err != nil {
+			log.WithError(err).WithField("command", command).Debug("lag.poll")
+			continue
+		}
+		output = formattingCodeRegexp.ReplaceAllString(output, "")
+		tps, mspt, ok := parseTPS(output)
+		if !ok {
+			log.WithField("command", command).WithField("output", output).Info("lag.unsupported")
+			result.unsupported = append(result.unsupported, command)
+			continue
+		}
+		if sample == nil {
+			sample = &LagSample{When: time.Now()}
+		}
+		sample.Source = strings.TrimPrefix(sample.Source+", "+command, ", ")
+		if sample.TPS == 0 {
+			sample.TPS = tps
+		}
+		if sample.MSPT == 0 {
+			sample.MSPT = mspt
+		}
+	}
+	if sample != nil && sample.TPS == 0 && sample.MSPT > 0 {
+		sample.TPS = math.Min(MaxTPS, 1000/sample.MSPT)
+	}
+	result.sample = sample
+	select {
+	case t.polls <- result:
+	case <-ctx.Done():
+	}
+}
+
+func (t *LagTracker) setEstimate(estimate bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.estimate = estimate
+}
+
+func parseTPS(output string) (float64, float64, bool) {
+	if unknownCommandRegexp.MatchString(output) {
+		return 0, 0, false
+	}
+	for _, parse := range tpsParsers {
+		if tps, mspt, ok := parse(output); ok {
+			return tps, mspt, true
+		}
+	}
+	return 0, 0, false
+}
+
+func (t *LagTracker) add(sample *LagSample) {
+	log.WithFields(sample).Debug("lag.sample")
+	t.dispatcher.Dispatch(sample)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.samples = append(t.samples, sample)
+	limit := sample.When.Add(-lagHistory)
+	start := 0
+	for start < len(t.samples) && t.samples[start].When.Before(limit) {
+		start++
+	}
+	t.samples = t.samples[start:]
+}
+
+// averageTPS returns the average TPS since the given time: the average of the polled values if any,
+// else an estimation based on the ticks the server reported to be behind.
+func (t *LagTracker) averageTPS(since, now time.Time) (float64, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var sum float64
+	var count, ticksBehind int
+	for _, sample := range t.samples {
+		if sample.When.Before(since) {
+			continue
+		}
+		if sample.Source == LogLagSource {
+			ticksBehind += sample.TicksBehind
+		} else if sample.TPS > 0 {
+			sum += sample.TPS
+			count++
+		}
+	}
+	if count > 0 {
+		return sum / float64(count), true
+	}
+	if !t.estimate {
+		return 0, false
+	}
+	return math.Max(0, MaxTPS-float64(ticksBehind)/now.Sub(since).Seconds()), true
+}
+
+func (t *LagTracker) checkAlert(now time.Time) {
+	if t.MinTPS == 0 || now.Sub(t.readySince) < t.Window {
+		return
+	}
+	tps, known := t.averageTPS(now.Add(-t.Window), now)
+	if !known {
+		return
+	}
+	switch {
+	case tps < t.MinTPS && !t.alerted:
+		t.alerted = true
+		alert := &LagAlert{tps, t.Window}
+		log.WithFields(alert).Warn("lag.alert")
+		t.dispatcher.Dispatch(alert)
+	case tps >= t.MinTPS && t.alerted:
+		t.alerted = false
+		recovered := &LagRecovered{tps}
+		log.WithFields(recovered).Info("lag.recovered")
+		t.dispatcher.Dispatch(recovered)
+	}
+}
+
+func (t *LagTracker) handleTPSCommand(*commands.Command) (string, error) {
+	now := time.Now()
+	var lines []string
+	for _, period := range []time.Duration{time.Minute, 5 * time.Minute, lagHistory} {
+		if tps, known := t.averageTPS(now.Add(-period), now); known {
+			lines = append(lines, fmt.Sprintf("%s: %.1f", humanDuration(period), tps))
+		}
+	}
+	message := "TPS unknown"
+	if len(lines) > 0 {
+		message = "Average TPS over the last " + strings.Join(lines, ", ")
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var warnings int
+	var behind time.Duration
+	var last *LagSample
+	for _, sample := range t.samples {
+		if sample.Source == LogLagSource {
+			warnings++
+			behind += sample.Behind
+		} else {
+			last = sample
+		}
+	}
+	if last != nil && last.MSPT > 0 {
+		message += fmt.Sprintf("\nLast tick time: %.1f ms", last.MSPT)
+	}
+	message += fmt.Sprintf("\n%d \"Can't keep up!\" warning(s) in the last %s", warnings, humanDuration(lagHistory))
+	if warnings > 0 {
+		message += fmt.Sprintf(" (%s behind)", behind.Round(time.Millisecond))
+	}
+	return message, nil
+}
+
+func parseFloat(s string) float64 {
+	value, _ := strconv.ParseFloat(s, 64)
+	return value
+}
+
+func (s *LagSample) Fields() log.Fields {
+	return log.Fields{
+		"source":      s.Source,
+		"tps":         s.TPS,
+		"mspt":        s.MSPT,
+		"behind":      s.Behind,
+		"ticksBehind": s.TicksBehind,
+	}
+}
+
+func (a *LagAlert) Fields() log.Fields {
+	return log.Fields{"tps": a.TPS, "window": a.Window}
+}
+
+func (a *LagAlert) DiscordNotification() string {
+	return fmt.Sprintf("**Server lagging**: %.1f TPS on average over the last %s", a.TPS, humanDuration(a.Window))
+}
+
+func (r *LagRecovered) Fields() log.Fields {
+	return log.Fields{"tps": r.TPS}
+}
+
+func (r *LagRecovered) DiscordNotification() string {
+	return fmt.Sprintf("**Server lag is over**: %.1f TPS", r.TPS)
+}
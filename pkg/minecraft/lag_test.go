@@ -0,0 +1,87 @@
+package minecraft_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Adirelle/mcvisor/pkg/commands"
+	"github.com/Adirelle/mcvisor/pkg/events"
+	"github.com/Adirelle/mcvisor/pkg/minecraft"
+)
+
+type lagConsole map[string]string
+
+func (c lagConsole) ExecuteConsoleCommand(command string) (string, error) {
+	if output, found := c[command]; found {
+		return output, nil
+	}
+	return "Unknown or incomplete command, see below for error", nil
+}
+
+func (lagConsole) Status() minecraft.Status {
+	return minecraft.Ready
+}
+
+func TestLagTracker(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		console lagConsole
+		tps     float64
+		mspt    float64
+	}{
+		"paper": {
+			lagConsole{
+				"tps":  "§6TPS from last 1m, 5m, 15m: §a*20.0, §a19.5, §a19.2",
+				"mspt": "§6Server tick times §e(§7avg§e/§7min§e/§7max§e)§6 from last 5s§7,§6 10s§7,§6 1m§e:\n§6◴ §a12.5§7/§a3.1§7/§a40.2§e, §a11.0§7/§a3.1§7/§a40.2§e, §a10.0§7/§a2.0§7/§a52.3",
+			},
+			20, 12.5,
+		},
+		"forge": {
+			lagConsole{"forge tps": "Dim minecraft:overworld: Mean tick time: 2.000 ms. Mean TPS: 20.000\nOverall: Mean tick time: 62.500 ms. Mean TPS: 16.000"},
+			16, 62.5,
+		},
+		"vanilla": {
+			lagConsole{"tick query": "The game is running normally\nTarget tick rate: 20.0 per second.\nAverage time per tick: 100.0ms (Target: 50.0ms)"},
+			10, 100,
+		},
+	}
+
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			config := minecraft.NewLagConfig()
+			config.PollInterval = 10 * time.Millisecond
+			dispatcher := events.NewDispatcher()
+			samples := events.MakeHandler[*minecraft.LagSample]()
+			defer dispatcher.Subscribe(samples).Cancel()
+			registry := commands.NewRegistry()
+			tracker := minecraft.NewLagTracker(config, c.console, c.console, dispatcher, registry)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			go func() { _ = tracker.Serve(ctx) }()
+
+			select {
+			case sample := <-samples:
+				if sample.TPS != c.tps || sample.MSPT != c.mspt {
+					t.Errorf("expected %.1f TPS and %.1f ms, got %+v", c.tps, c.mspt, sample)
+				}
+			case <-ctx.Done():
+				t.Fatal("no lag sample")
+			}
+
+			reply, err := registry.HandleCommand(commands.NewCommand("tps", commands.System))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(reply, "Average TPS over the last 1 minute") {
+				t.Errorf("unexpected reply: %s", reply)
+			}
+		})
+	}
+}
@@ -0,0 +1,193 @@
+package minecraft
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+type (
+	// LauncherConfig describes how the server is started.
+	//
+	// - "jar" runs `java <options> -jar <jar>`, like vanilla, Paper or Fabric servers,
+	// - "forge-args" runs `java <options> @user_jvm_args.txt @<args file>`, like modern Forge and NeoForge servers,
+	// - "script" runs a start script shipped with the server (e.g. run.sh),
+	// - "custom" runs the given command, in which placeholders are expanded: {java}, {java_options}, {log4j}, {jar}
+	//   and {server_options}.
+	//
+	// The Java options and the log4j configuration of mcvisor are passed on the command line when possible,
+	// else through the JDK_JAVA_OPTIONS environment variable.
+	LauncherConfig struct {
+		Type        LauncherType `json:"type" validate:"oneof=jar forge-args script custom"`
+		JvmArgsFile string       `json:"jvm_args_file,omitempty"`
+		ArgsFile    string       `json:"args_file,omitempty"`
+		Script      string       `json:"script,omitempty"`
+		Command     []string     `json:"command,omitempty" validate:"required_if=Type custom"`
+	}
+
+	LauncherType string
+)
+
+const (
+	JarLauncher       LauncherType = "jar"
+	ForgeArgsLauncher LauncherType = "forge-args"
+	ScriptLauncher    LauncherType = "script"
+	CustomLauncher    LauncherType = "custom"
+
+	DefaultJvmArgsFile = "user_jvm_args.txt"
+	JavaOptionsEnvName = "JDK_JAVA_OPTIONS"
+
+	JavaPlaceholder          = "{java}"
+	JavaOptionsPlaceholder   = "{java_options}"
+	Log4JPlaceholder         = "{log4j}"
+	JarPlaceholder           = "{jar}"
+	ServerOptionsPlaceholder = "{server_options}"
+)
+
+var (
+	// ForgeArgsFilePatterns are the locations of the arguments files of Forge and NeoForge, relative to the working
+	// directory of the server.
+	ForgeArgsFilePatterns = []string{
+		"libraries/net/minecraftforge/forge/*/" + ForgeArgsFileName,
+		"libraries/net/neoforged/forge/*/" + ForgeArgsFileName,
+		"libraries/net/neoforged/neoforge/*/" + ForgeArgsFileName,
+	}
+
+	ErrNoForgeArgsFile = errors.New("no Forge arguments file found, please set args_file")
+)
+
+func NewLauncherConfig() *LauncherConfig {
+	return &LauncherConfig{
+		Type:        JarLauncher,
+		JvmArgsFile: DefaultJvmArgsFile,
+		Script:      DefaultLauncherScript,
+	}
+}
+
+// CommandLine builds the command line that starts the server.
+func (c LauncherConfig) CommandLine(java *JavaConfig, server *ServerConfig) ([]string, error) {
+	javaOptions, err := c.javaOptions(java, server)
+	if err != nil {
+		return nil, err
+	}
+
+	switch c.Type {
+	case ForgeArgsLauncher:
+		argsFile, err := c.forgeArgsFile(server.AbsWorkingDir())
+		if err != nil {
+			return nil, err
+		}
+		cmdLine := append([]string{java.AbsJavaCommand()}, javaOptions...)
+		if jvmArgsFile := absPath(server.AbsWorkingDir(), c.JvmArgsFile); c.JvmArgsFile != "" && fileExists(jvmArgsFile) {
+			cmdLine = append(cmdLine, "@"+jvmArgsFile)
+		}
+		cmdLine = append(cmdLine, "@"+argsFile)
+		return append(cmdLine, server.Options...), nil
+
+	case ScriptLauncher:
+		return append([]string{absPath(server.AbsWorkingDir(), c.Script)}, server.Options...), nil
+
+	case CustomLauncher:
+		var cmdLine []string
+		for _, arg := range c.Command {
+			switch arg {
+			case JavaOptionsPlaceholder:
+				cmdLine = append(cmdLine, javaOptions...)
+			case ServerOptionsPlaceholder:
+				cmdLine = append(cmdLine, server.Options...)
+			default:
+				cmdLine = append(cmdLine, strings.NewReplacer(
+					JavaPlaceholder, java.AbsJavaCommand(),
+					Log4JPlaceholder, server.Log4JOption(),
+					JarPlaceholder, server.Jar,
+				).Replace(arg))
+			}
+		}
+		return cmdLine, nil
+
+	default:
+		cmdLine := append([]string{java.AbsJavaCommand()}, javaOptions...)
+		cmdLine = append(cmdLine, "-jar", server.Jar)
+		return append(cmdLine, server.Options...), nil
+	}
+}
+
+// Env returns the environment variables to add for the launcher.
+// Scripts and custom commands inherit the environment of mcvisor, with the selected Java runtime first in the PATH,
+// and receive the Java options through JDK_JAVA_OPTIONS unless they are on the command line.
+func (c LauncherConfig) Env(java *JavaConfig, server *ServerConfig) []string {
+	if c.Type != ScriptLauncher && c.Type != CustomLauncher {
+		return nil
+	}
+	env := os.Environ()
+	if java.Home != "" {
+		env = append(env, "PATH="+filepath.Join(java.Home, "bin")+string(os.PathListSeparator)+os.Getenv("PATH"))
+	}
+	if c.Type == ScriptLauncher || !c.hasPlaceholder(JavaOptionsPlaceholder) {
+		// Errors are reported when building the command line.
+		options, _ := c.javaOptions(java, server)
+		env = append(env, JavaOptionsEnvName+"="+joinJavaOptions(options))
+	}
+	return env
+}
+
+func (c LauncherConfig) javaOptions(java *JavaConfig, server *ServerConfig) ([]string, error) {
+	options, err := java.JVMOptions()
+	if err != nil {
+		return nil, err
+	}
+	if c.Type != CustomLauncher || !c.hasPlaceholder(Log4JPlaceholder) {
+		options = append(options, server.Log4JOption())
+	}
+	return options, nil
+}
+
+func (c LauncherConfig) hasPlaceholder(placeholder string) bool {
+	for _, arg := range c.Command {
+		if strings.Contains(arg, placeholder) {
+			return true
+		}
+	}
+	return false
+}
+
+// forgeArgsFile returns the configured arguments file or, if it is not set, the one of the most recent version
+// installed in the libraries.
+func (c LauncherConfig) forgeArgsFile(workingDir string) (string, error) {
+	if c.ArgsFile != "" {
+		return absPath(workingDir, c.ArgsFile), nil
+	}
+	var found []string
+	for _, pattern := range ForgeArgsFilePatterns {
+		matches, err := filepath.Glob(filepath.Join(workingDir, filepath.FromSlash(pattern)))
+		if err != nil {
+			return "", err
+		}
+		found = append(found, matches...)
+	}
+	if len(found) == 0 {
+		return "", ErrNoForgeArgsFile
+	}
+	sort.Strings(found)
+	return found[len(found)-1], nil
+}
+
+// joinJavaOptions formats options for JDK_JAVA_OPTIONS, quoting the ones that contain spaces.
+func joinJavaOptions(options []string) string {
+	quoted := make([]string, len(options))
+	for i, option := range options {
+		if strings.ContainsAny(option, " \t") {
+			option = fmt.Sprintf("%q", option)
+		}
+		quoted[i] = option
+	}
+	return strings.Join(quoted, " ")
+}
+
+func fileExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
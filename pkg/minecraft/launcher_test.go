@@ -0,0 +1,108 @@
+package minecraft_test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/Adirelle/mcvisor/pkg/minecraft"
+)
+
+func newLauncherTestConfig(t *testing.T) *minecraft.Config {
+	t.Helper()
+	config := minecraft.NewConfig(t.TempDir())
+	config.Java.Home = "/opt/java"
+	config.Java.Preset = minecraft.NoPreset
+	config.Java.Options = []string{"-Xmx2G"}
+	return config
+}
+
+func TestJarLauncher(t *testing.T) {
+	t.Parallel()
+
+	config := newLauncherTestConfig(t)
+	cmdLine, err := config.Command()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		filepath.Join("/opt/java", minecraft.JavaCmd),
+		"-Xmx2G",
+		"-Dlog4j.configurationFile=" + minecraft.DefaultLog4JConf,
+		"-jar", minecraft.DefaultServerJar,
+		"--nogui",
+	}
+	if !reflect.DeepEqual(cmdLine, expected) {
+		t.Errorf("expected %v, got %v", expected, cmdLine)
+	}
+}
+
+func TestForgeArgsLauncher(t *testing.T) {
+	t.Parallel()
+
+	config := newLauncherTestConfig(t)
+	config.Server.Launcher.Type = minecraft.ForgeArgsLauncher
+	config.Server.Options = []string{"nogui"}
+
+	if _, err := config.Command(); err != minecraft.ErrNoForgeArgsFile {
+		t.Errorf("expected ErrNoForgeArgsFile, got %v", err)
+	}
+
+	dir := config.WorkingDir()
+	var argsFile string
+	for _, version := range []string{"1.20.1-47.1.0", "1.20.1-47.2.0"} {
+		versionDir := filepath.Join(dir, "libraries", "net", "minecraftforge", "forge", version)
+		if err := os.MkdirAll(versionDir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		argsFile = filepath.Join(versionDir, minecraft.ForgeArgsFileName)
+		if err := os.WriteFile(argsFile, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	jvmArgsFile := filepath.Join(dir, minecraft.DefaultJvmArgsFile)
+	if err := os.WriteFile(jvmArgsFile, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cmdLine, err := config.Command()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		filepath.Join("/opt/java", minecraft.JavaCmd),
+		"-Xmx2G",
+		"-Dlog4j.configurationFile=" + minecraft.DefaultLog4JConf,
+		"@" + jvmArgsFile,
+		"@" + argsFile,
+		"nogui",
+	}
+	if !reflect.DeepEqual(cmdLine, expected) {
+		t.Errorf("expected %v, got %v", expected, cmdLine)
+	}
+}
+
+func TestCustomLauncher(t *testing.T) {
+	t.Parallel()
+
+	config := newLauncherTestConfig(t)
+	config.Server.Launcher.Type = minecraft.CustomLauncher
+	config.Server.Launcher.Command = []string{"nice", "{java}", "{java_options}", "-jar", "{jar}", "{server_options}"}
+
+	cmdLine, err := config.Command()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"nice",
+		filepath.Join("/opt/java", minecraft.JavaCmd),
+		"-Xmx2G",
+		"-Dlog4j.configurationFile=" + minecraft.DefaultLog4JConf,
+		"-jar", minecraft.DefaultServerJar,
+		"--nogui",
+	}
+	if !reflect.DeepEqual(cmdLine, expected) {
+		t.Errorf("expected %v, got %v", expected, cmdLine)
+	}
+}
@@ -0,0 +1,149 @@
+package minecraft
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+type (
+	// MemorySize is an amount of memory, either absolute ("512M", "4G") or relative to the total memory of the host
+	// ("50%").
+	MemorySize string
+)
+
+const (
+	MemInfoPath = "/proc/meminfo"
+
+	KiB uint64 = 1 << 10
+	MiB uint64 = 1 << 20
+	GiB uint64 = 1 << 30
+	TiB uint64 = 1 << 40
+)
+
+var (
+	ErrInvalidMemorySize = errors.New("invalid memory size, expected a size (e.g. 512M, 4G) or a percentage (e.g. 50%)")
+	ErrMemoryConflict    = errors.New("the heap size must be set either with min_memory/max_memory or with options, not both")
+	ErrMemoryRange       = errors.New("min_memory must not be greater than max_memory")
+
+	memoryUnits = map[string]uint64{"K": KiB, "M": MiB, "G": GiB, "T": TiB}
+
+	// Java options that set the minimum and the maximum heap size.
+	minHeapOptions = []string{"-Xms", "-XX:InitialHeapSize=", "-XX:InitialRAMPercentage=", "-XX:MinRAMPercentage="}
+	maxHeapOptions = []string{"-Xmx", "-XX:MaxHeapSize=", "-XX:MaxRAMPercentage="}
+)
+
+// Validate checks the syntax of the size.
+func (m MemorySize) Validate() error {
+	_, _, err := m.parse()
+	return err
+}
+
+// IsRelative returns whether the size is a percentage of the total memory.
+func (m MemorySize) IsRelative() bool {
+	return strings.HasSuffix(string(m), "%")
+}
+
+// Bytes returns the size in bytes. Percentages are computed from the total memory reported by /proc/meminfo.
+func (m MemorySize) Bytes() (uint64, error) {
+	value, unit, err := m.parse()
+	if err != nil {
+		return 0, err
+	}
+	if unit != 0 {
+		return uint64(value * float64(unit)), nil
+	}
+	total, err := TotalMemory()
+	if err != nil {
+		return 0, fmt.Errorf("cannot compute %s of the total memory: %w", m, err)
+	}
+	return uint64(value / 100 * float64(total)), nil
+}
+
+// JavaOption formats the size for a Java option, in mebibytes.
+func (m MemorySize) JavaOption(prefix string) (string, error) {
+	size, err := m.Bytes()
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s%dM", prefix, size/MiB), nil
+}
+
+// parse returns the numeric value and its unit in bytes; the unit is 0 for percentages.
+func (m MemorySize) parse() (float64, uint64, error) {
+	s := strings.TrimSpace(string(m))
+	if strings.HasSuffix(s, "%") {
+		value, err := strconv.ParseFloat(strings.TrimSuffix(s, "%"), 64)
+		if err != nil || value <= 0 || value > 100 {
+			return 0, 0, fmt.Errorf("%w: %q", ErrInvalidMemorySize, string(m))
+		}
+		return value, 0, nil
+	}
+
+	s = strings.TrimSuffix(strings.TrimSuffix(strings.ToUpper(s), "B"), "I")
+	end := strings.IndexFunc(s, func(r rune) bool { return (r < '0' || r > '9') && r != '.' })
+	if end < 0 {
+		end = len(s)
+	}
+	value, err := strconv.ParseFloat(s[:end], 64)
+	unit, found := memoryUnits[s[end:]]
+	if err != nil || !found || value <= 0 {
+		return 0, 0, fmt.Errorf("%w: %q", ErrInvalidMemorySize, string(m))
+	}
+	return value, unit, nil
+}
+
+// TotalMemory reads the total memory of the host from /proc/meminfo.
+func TotalMemory() (uint64, error) {
+	file, err := os.Open(MemInfoPath)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && fields[0] == "MemTotal:" {
+			total, err := strconv.ParseUint(fields[1], 10, 64)
+			if err != nil {
+				return 0, err
+			}
+			return total * KiB, nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+	return 0, fmt.Errorf("%s: no MemTotal", MemInfoPath)
+}
+
+// MaxHeap returns the maximum heap size, from max_memory or from the -Xmx option, or 0 if it is not set.
+func (c JavaConfig) MaxHeap() uint64 {
+	size := c.MaxMemory
+	for _, option := range c.Options {
+		if size == "" && strings.HasPrefix(option, "-Xmx") {
+			size = MemorySize(strings.TrimPrefix(option, "-Xmx"))
+		}
+	}
+	bytes, err := size.Bytes()
+	if err != nil {
+		return 0
+	}
+	return bytes
+}
+
+// hasOption returns whether any of the options starts with one of the prefixes.
+func hasOption(options []string, prefixes []string) bool {
+	for _, option := range options {
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(option, prefix) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,394 @@
+package minecraft
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Adirelle/mcvisor/pkg/commands"
+	"github.com/Adirelle/mcvisor/pkg/discord"
+	"github.com/Adirelle/mcvisor/pkg/events"
+	"github.com/Adirelle/mcvisor/pkg/utils"
+	"github.com/apex/log"
+	"github.com/thejerf/suture/v4"
+)
+
+type (
+	MonitorConfig struct {
+		Interval   time.Duration      `json:"interval"`
+		History    time.Duration      `json:"history"`
+		Thresholds []*ThresholdConfig `json:"thresholds,omitempty" validate:"dive"`
+	}
+
+	// ThresholdConfig triggers a notification, and optionally a restart, when a metric stays above a limit.
+	// Metrics are: cpu (percentage of one core), rss (MiB), heap (RSS as a percentage of the maximum heap size),
+	// threads and fds (open file descriptors).
+	ThresholdConfig struct {
+		Metric       Metric        `json:"metric" validate:"oneof=cpu rss heap threads fds"`
+		Above        float64       `json:"above" validate:"gt=0"`
+		For          time.Duration `json:"for"`
+		Restart      bool          `json:"restart"`
+		RestartDelay time.Duration `json:"restart_delay"`
+	}
+
+	Metric string
+
+	// Monitor samples the resource usage of the server process from /proc, so it only works on Linux.
+	//
+	// Script and custom launchers usually run the JVM as a child of a shell: the monitor then samples the first
+	// "java" process among the descendants of the launched process, and samples nothing until it is found.
+	Monitor struct {
+		*Config
+		dispatcher *events.Dispatcher
+		target     Monitored
+		maxHeap    uint64
+		findJVM    bool
+
+		pid       int
+		jvmPID    int
+		last      *procStat
+		exceeded  []time.Time
+		triggered []bool
+
+		mu      sync.Mutex
+		history []*ProcessSample
+	}
+
+	// Monitored is the server whose process is monitored.
+	Monitored interface {
+		PID() int
+		ScheduleRestart(delay time.Duration) error
+	}
+
+	ProcessSample struct {
+		When      time.Time
+		PID       int
+		CPU       float64
+		RSS       uint64
+		Threads   int
+		FDs       int
+		ReadRate  float64
+		WriteRate float64
+	}
+
+	ThresholdExceeded struct {
+		*ThresholdConfig
+		Value    float64
+		Duration time.Duration
+	}
+)
+
+const (
+	CPUMetric     Metric = "cpu"
+	RSSMetric     Metric = "rss"
+	HeapMetric    Metric = "heap"
+	ThreadsMetric Metric = "threads"
+	FDsMetric     Metric = "fds"
+
+	PerfCommand commands.Name = "perf"
+
+	DefaultMonitorInterval = 30 * time.Second
+	DefaultMonitorHistory  = time.Hour
+
+	JavaProcessName = "java"
+)
+
+var (
+	// Interface checks
+	_ suture.Service       = (*Monitor)(nil)
+	_ Monitored            = (*Server)(nil)
+	_ discord.Notification = (*ThresholdExceeded)(nil)
+	_ log.Fielder          = (*ProcessSample)(nil)
+
+	ErrNoSample = errors.New("no resource usage sample yet")
+)
+
+func NewMonitorConfig() *MonitorConfig {
+	return &MonitorConfig{
+		Interval: DefaultMonitorInterval,
+		History:  DefaultMonitorHistory,
+	}
+}
+
+// IsEnabled returns whether the monitor is enabled and supported on this platform.
+func (c *MonitorConfig) IsEnabled() bool {
+	return c.Interval > 0 && MonitorSupported
+}
+
+func NewMonitor(config *Config, target Monitored, dispatcher *events.Dispatcher, registry *commands.Registry) *Monitor {
+	m := &Monitor{
+		Config:     config,
+		dispatcher: dispatcher,
+		target:     target,
+		maxHeap:    config.Java.MaxHeap(),
+		findJVM:    config.Server.Launcher.Type == ScriptLauncher || config.Server.Launcher.Type == CustomLauncher,
+		exceeded:   make([]time.Time, len(config.Server.Monitor.Thresholds)),
+		triggered:  make([]bool, len(config.Server.Monitor.Thresholds)),
+	}
+	registry.Register(PerfCommand, "show the resource usage of the server process", discord.QueryCategory, commands.HandlerFunc(m.handlePerfCommand))
+	return m
+}
+
+func (m *Monitor) Serve(ctx context.Context) error {
+	ticker := time.NewTicker(m.Server.Monitor.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case when := <-ticker.C:
+			m.sample(when)
+		}
+	}
+}
+
+func (m *Monitor) sample(when time.Time) {
+	if pid := m.target.PID(); pid != m.pid {
+		m.pid = pid
+		m.setJVM(0)
+	}
+	if m.pid == 0 {
+		return
+	}
+	if m.jvmPID == 0 {
+		m.setJVM(m.resolveJVM())
+		if m.jvmPID == 0 {
+			log.WithField("pid", m.pid).Debug("monitor.jvm.missing")
+			return
+		}
+	}
+
+	stat, err := readProcStat(m.jvmPID, when)
+	if err != nil {
+		if m.last != nil {
+			log.WithError(err).WithField("pid", m.jvmPID).Warn("monitor.sample")
+		}
+		// The JVM may have been restarted by the launcher.
+		m.setJVM(0)
+		return
+	}
+	first := m.last == nil
+	sample := stat.sample(m.last)
+	sample.PID = m.jvmPID
+	m.last = stat
+	if first {
+		// The CPU usage and the I/O rates need two readings.
+		return
+	}
+
+	log.WithFields(sample).Debug("monitor.sample")
+	m.dispatcher.Dispatch(sample)
+	m.record(sample)
+	m.checkThresholds(sample)
+}
+
+// resolveJVM returns the PID of the JVM of the server, or 0 if it is not running yet.
+func (m *Monitor) resolveJVM() int {
+	if !m.findJVM {
+		return m.pid
+	}
+	pid := findProcess(m.pid, JavaProcessName)
+	if pid != 0 && pid != m.pid {
+		log.WithField("pid", m.pid).WithField("jvm", pid).Debug("monitor.jvm")
+	}
+	return pid
+}
+
+// setJVM changes the sampled process, resetting the readings and the thresholds.
+func (m *Monitor) setJVM(pid int) {
+	if pid == m.jvmPID {
+		return
+	}
+	m.jvmPID = pid
+	m.last = nil
+	for i := range m.exceeded {
+		m.exceeded[i] = time.Time{}
+		m.triggered[i] = false
+	}
+}
+
+func (m *Monitor) record(sample *ProcessSample) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.history = append(m.history, sample)
+	limit := sample.When.Add(-m.Server.Monitor.History)
+	start := 0
+	for start < len(m.history) && m.history[start].When.Before(limit) {
+		start++
+	}
+	m.history = m.history[start:]
+}
+
+func (m *Monitor) checkThresholds(sample *ProcessSample) {
+	for i, threshold := range m.Server.Monitor.Thresholds {
+		value, known := m.value(sample, threshold.Metric)
+		if !known || value <= threshold.Above {
+			m.exceeded[i] = time.Time{}
+			m.triggered[i] = false
+			continue
+		}
+		if m.exceeded[i].IsZero() {
+			m.exceeded[i] = sample.When
+		}
+		duration := sample.When.Sub(m.exceeded[i])
+		if m.triggered[i] || duration < threshold.For {
+			continue
+		}
+
+		m.triggered[i] = true
+		event := &ThresholdExceeded{threshold, value, duration}
+		log.WithFields(event).Warn("monitor.threshold")
+		m.dispatcher.Dispatch(event)
+		if threshold.Restart {
+			if err := m.target.ScheduleRestart(threshold.RestartDelay); err != nil {
+				log.WithError(err).Error("monitor.restart")
+			}
+		}
+	}
+}
+
+// value returns the value of the metric, and false if it cannot be computed.
+func (m *Monitor) value(sample *ProcessSample, metric Metric) (float64, bool) {
+	switch metric {
+	case CPUMetric:
+		return sample.CPU, true
+	case RSSMetric:
+		return float64(sample.RSS) / float64(MiB), true
+	case HeapMetric:
+		if m.maxHeap == 0 {
+			return 0, false
+		}
+		return float64(sample.RSS) / float64(m.maxHeap) * 100, true
+	case ThreadsMetric:
+		return float64(sample.Threads), true
+	case FDsMetric:
+		return float64(sample.FDs), true
+	default:
+		return 0, false
+	}
+}
+
+func (m *Monitor) handlePerfCommand(*commands.Command) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.history) == 0 {
+		return "", ErrNoSample
+	}
+
+	current := m.history[len(m.history)-1]
+	low, high := *current, *current
+	for _, sample := range m.history {
+		low.CPU, high.CPU = minMax(low.CPU, high.CPU, sample.CPU)
+		low.ReadRate, high.ReadRate = minMax(low.ReadRate, high.ReadRate, sample.ReadRate)
+		low.WriteRate, high.WriteRate = minMax(low.WriteRate, high.WriteRate, sample.WriteRate)
+		low.RSS, high.RSS = minMax(low.RSS, high.RSS, sample.RSS)
+		low.Threads, high.Threads = minMax(low.Threads, high.Threads, sample.Threads)
+		low.FDs, high.FDs = minMax(low.FDs, high.FDs, sample.FDs)
+	}
+
+	memory := fmt.Sprintf("Memory: %s (min %s, max %s)", humanBytes(current.RSS), humanBytes(low.RSS), humanBytes(high.RSS))
+	if m.maxHeap > 0 {
+		memory += fmt.Sprintf(", %.0f%% of the maximum heap", float64(current.RSS)/float64(m.maxHeap)*100)
+	}
+	lines := []string{
+		fmt.Sprintf("**Process %d**, over the last %s:", current.PID, humanDuration(current.When.Sub(m.history[0].When))),
+		fmt.Sprintf("CPU: %.1f%% (min %.1f%%, max %.1f%%)", current.CPU, low.CPU, high.CPU),
+		memory,
+		fmt.Sprintf("Threads: %d (min %d, max %d)", current.Threads, low.Threads, high.Threads),
+		fmt.Sprintf("Open files: %d (min %d, max %d)", current.FDs, low.FDs, high.FDs),
+		fmt.Sprintf(
+			"Disk reads: %s/s (max %s/s), writes: %s/s (max %s/s)",
+			humanBytes(uint64(current.ReadRate)), humanBytes(uint64(high.ReadRate)),
+			humanBytes(uint64(current.WriteRate)), humanBytes(uint64(high.WriteRate)),
+		),
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+func minMax[T int | uint64 | float64](low, high, value T) (T, T) {
+	if value < low {
+		low = value
+	}
+	if value > high {
+		high = value
+	}
+	return low, high
+}
+
+func humanBytes(size uint64) string {
+	return utils.HumanSize(int64(size))
+}
+
+func (s *ProcessSample) Fields() log.Fields {
+	return log.Fields{
+		"pid":       s.PID,
+		"cpu":       s.CPU,
+		"rss":       s.RSS,
+		"threads":   s.Threads,
+		"fds":       s.FDs,
+		"readRate":  s.ReadRate,
+		"writeRate": s.WriteRate,
+	}
+}
+
+func (e *ThresholdExceeded) Fields() log.Fields {
+	return log.Fields{
+		"metric":   e.Metric,
+		"value":    e.Value,
+		"limit":    e.Above,
+		"duration": e.Duration,
+		"restart":  e.Restart,
+	}
+}
+
+func (e *ThresholdExceeded) DiscordNotification() string {
+	message := fmt.Sprintf(
+		"**Resource alert**: %s is %s (limit %s) for %s",
+		e.Metric.Description(),
+		e.Metric.Format(e.Value),
+		e.Metric.Format(e.Above),
+		humanDuration(e.Duration),
+	)
+	if e.Restart {
+		message += ", restarting the server"
+	}
+	return message
+}
+
+func (m Metric) Description() string {
+	switch m {
+	case CPUMetric:
+		return "CPU usage"
+	case RSSMetric:
+		return "memory usage"
+	case HeapMetric:
+		return "memory usage relative to the maximum heap"
+	case ThreadsMetric:
+		return "thread count"
+	case FDsMetric:
+		return "open file count"
+	default:
+		return string(m)
+	}
+}
+
+func (m Metric) Format(value float64) string {
+	switch m {
+	case CPUMetric, HeapMetric:
+		return fmt.Sprintf("%.1f%%", value)
+	case RSSMetric:
+		return humanBytes(uint64(value * float64(MiB)))
+	default:
+		return fmt.Sprintf("%.0f", value)
+	}
+}
+
+// ScheduleRestart restarts the server after the given delay, announcing it in-game.
+func (s *Server) ScheduleRestart(delay time.Duration) error {
+	_, err := s.scheduleTarget(RestartTarget, delay)
+	return err
+}
@@ -0,0 +1,76 @@
+package minecraft_test
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Adirelle/mcvisor/pkg/commands"
+	"github.com/Adirelle/mcvisor/pkg/events"
+	"github.com/Adirelle/mcvisor/pkg/minecraft"
+)
+
+type monitoredProcess struct {
+	restarts chan time.Duration
+}
+
+func (monitoredProcess) PID() int {
+	return os.Getpid()
+}
+
+func (p monitoredProcess) ScheduleRestart(delay time.Duration) error {
+	p.restarts <- delay
+	return nil
+}
+
+func TestMonitor(t *testing.T) {
+	t.Parallel()
+	if !minecraft.MonitorSupported {
+		t.Skip("process monitoring is not supported")
+	}
+
+	config := minecraft.NewConfig(t.TempDir())
+	config.Server.Monitor.Interval = 10 * time.Millisecond
+	config.Server.Monitor.Thresholds = []*minecraft.ThresholdConfig{
+		{Metric: minecraft.ThreadsMetric, Above: 0.5, Restart: true, RestartDelay: time.Minute},
+	}
+
+	dispatcher := events.NewDispatcher()
+	alerts := events.MakeHandler[*minecraft.ThresholdExceeded]()
+	defer dispatcher.Subscribe(alerts).Cancel()
+
+	target := monitoredProcess{make(chan time.Duration, 1)}
+	registry := commands.NewRegistry()
+	monitor := minecraft.NewMonitor(config, target, dispatcher, registry)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	go func() { _ = monitor.Serve(ctx) }()
+
+	select {
+	case alert := <-alerts:
+		if alert.Metric != minecraft.ThreadsMetric || alert.Value < 1 {
+			t.Errorf("unexpected alert: %+v", alert)
+		}
+	case <-ctx.Done():
+		t.Fatal("no threshold alert")
+	}
+	select {
+	case delay := <-target.restarts:
+		if delay != time.Minute {
+			t.Errorf("unexpected restart delay: %s", delay)
+		}
+	case <-ctx.Done():
+		t.Fatal("no restart scheduled")
+	}
+
+	reply, err := registry.HandleCommand(commands.NewCommand("perf", commands.System))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(reply, "Threads:") {
+		t.Errorf("unexpected reply: %s", reply)
+	}
+}
@@ -0,0 +1,219 @@
+package minecraft
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/apex/log"
+)
+
+type (
+	LogParser struct {
+		patterns []*logPattern
+	}
+
+	LogEventType string
+
+	LogPattern struct {
+		Event   LogEventType `json:"event" validate:"required,oneof=joined left chat death advancement done lag"`
+		Pattern string       `json:"pattern" validate:"required"`
+	}
+
+	logPattern struct {
+		build  logEventBuilder
+		regexp *regexp.Regexp
+	}
+
+	logEventBuilder func(groups map[string]string) (any, error)
+
+	PlayerJoined struct {
+		Player string
+	}
+
+	PlayerLeft struct {
+		Player string
+	}
+
+	ChatMessage struct {
+		Player  string
+		Message string
+	}
+
+	PlayerDied struct {
+		Player  string
+		Message string
+	}
+
+	AdvancementMade struct {
+		Player      string
+		Advancement string
+	}
+
+	ServerDone struct {
+		StartupDuration time.Duration
+	}
+
+	CantKeepUp struct {
+		Lag         time.Duration
+		TicksBehind int
+	}
+)
+
+const (
+	JoinedEvent      LogEventType = "joined"
+	LeftEvent        LogEventType = "left"
+	ChatEvent        LogEventType = "chat"
+	DeathEvent       LogEventType = "death"
+	AdvancementEvent LogEventType = "advancement"
+	DoneEvent        LogEventType = "done"
+	LagEvent         LogEventType = "lag"
+)
+
+var (
+	// Interface checks
+	_ log.Fielder = (*PlayerJoined)(nil)
+	_ log.Fielder = (*PlayerLeft)(nil)
+	_ log.Fielder = (*ChatMessage)(nil)
+	_ log.Fielder = (*PlayerDied)(nil)
+	_ log.Fielder = (*AdvancementMade)(nil)
+	_ log.Fielder = (*ServerDone)(nil)
+	_ log.Fielder = (*CantKeepUp)(nil)
+
+	// Line prefixes added by the logging configuration of the different server flavors:
+	//	vanilla: [12:34:56] [Server thread/INFO]: message
+	//	Forge:   [12:34:56] [Server thread/INFO] [minecraft/DedicatedServer]: message
+	//	Fabric:  [12:34:56] [Server thread/INFO] (Minecraft) message
+	//	Paper:   [12:34:56 INFO]: message
+	logLinePrefixes = []*regexp.Regexp{
+		regexp.MustCompile(`^\[[^\]]*\d\d:\d\d:\d\d[^\]]*\] \[[^\]]+/[A-Z]+\](?: \[[^\]]+\])?(?: \([^)]+\))?:? `),
+		regexp.MustCompile(`^\[\d\d:\d\d:\d\d [A-Z]+\]: `),
+	}
+
+	DefaultLogPatterns = []LogPattern{
+		{ChatEvent, `^(?:\[Not Secure\] )?<(?P<player>\w{1,16})> (?P<message>.*)$`},
+		{JoinedEvent, `^(?P<player>\w{1,16}) joined the game$`},
+		{LeftEvent, `^(?P<player>\w{1,16}) left the game$`},
+		{AdvancementEvent, `^(?P<player>\w{1,16}) has (?:made the advancement|reached the goal|completed the challenge) \[(?P<advancement>.+)\]$`},
+		{DoneEvent, `^Done \((?P<duration>\d+(?:[.,]\d+)?)s\)!`},
+		{LagEvent, `^Can't keep up! Is the server overloaded\? Running (?P<lag>\d+)ms or (?P<ticks>\d+) ticks behind$`},
+		{DeathEvent, `^(?P<message>(?P<player>\w{1,16}) (?:was |were )?(?:` + strings.Join([]string{
+			"slain", "shot", "killed", "pummeled", "fireballed", "blown up", "impaled", "skewered", "squashed", "squished",
+			"pricked", "poked", "stung", "frozen", "struck by lightning", "burnt", "burned", "roasted", "obliterated",
+			"doomed", "stabbed", "drowned", "died", "fell", "starved", "suffocated", "withered away", "blew up",
+			"hit the ground too hard", "experienced kinetic energy", "froze to death", "tried to swim in lava",
+			"went up in flames", "walked into", "discovered the floor was lava", "went off with a bang",
+			"didn't want to live", "left the confines",
+		}, "|") + `)\b.*)$`},
+	}
+
+	logEventBuilders = map[LogEventType]logEventBuilder{
+		JoinedEvent: func(groups map[string]string) (any, error) {
+			return PlayerJoined{groups["player"]}, nil
+		},
+		LeftEvent: func(groups map[string]string) (any, error) {
+			return PlayerLeft{groups["player"]}, nil
+		},
+		ChatEvent: func(groups map[string]string) (any, error) {
+			return ChatMessage{groups["player"], groups["message"]}, nil
+		},
+		DeathEvent: func(groups map[string]string) (any, error) {
+			return PlayerDied{groups["player"], groups["message"]}, nil
+		},
+		AdvancementEvent: func(groups map[string]string) (any, error) {
+			return AdvancementMade{groups["player"], groups["advancement"]}, nil
+		},
+		DoneEvent: func(groups map[string]string) (any, error) {
+			seconds, err := strconv.ParseFloat(strings.Replace(groups["duration"], ",", ".", 1), 64)
+			return ServerDone{time.Duration(seconds * float64(time.Second))}, err
+		},
+		LagEvent: func(groups map[string]string) (any, error) {
+			lag, err := strconv.Atoi(groups["lag"])
+			if err != nil {
+				return nil, err
+			}
+			ticks, err := strconv.Atoi(groups["ticks"])
+			return CantKeepUp{time.Duration(lag) * time.Millisecond, ticks}, err
+		},
+	}
+)
+
+// NewLogParser compiles the given patterns. They are tried in order, before the default ones.
+func NewLogParser(patterns []LogPattern) (*LogParser, error) {
+	p := &LogParser{}
+	for _, pattern := range append(append([]LogPattern{}, patterns...), DefaultLogPatterns...) {
+		build, found := logEventBuilders[pattern.Event]
+		if !found {
+			return nil, fmt.Errorf("unknown log event type: %q", pattern.Event)
+		}
+		re, err := regexp.Compile(pattern.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern for %s events: %w", pattern.Event, err)
+		}
+		p.patterns = append(p.patterns, &logPattern{build, re})
+	}
+	return p, nil
+}
+
+// Parse returns the event matching the line, if any.
+func (p *LogParser) Parse(line string) any {
+	message := stripLogPrefix(line)
+	for _, pattern := range p.patterns {
+		matches := pattern.regexp.FindStringSubmatch(message)
+		if matches == nil {
+			continue
+		}
+		groups := make(map[string]string, len(matches))
+		for i, name := range pattern.regexp.SubexpNames() {
+			if name != "" {
+				groups[name] = matches[i]
+			}
+		}
+		event, err := pattern.build(groups)
+		if err != nil {
+			log.WithError(err).WithField("line", line).Warn("server.parser")
+			return nil
+		}
+		return event
+	}
+	return nil
+}
+
+func stripLogPrefix(line string) string {
+	for _, prefix := range logLinePrefixes {
+		if loc := prefix.FindStringIndex(line); loc != nil {
+			return line[loc[1]:]
+		}
+	}
+	return line
+}
+
+func (e PlayerJoined) Fields() log.Fields {
+	return log.Fields{"player": e.Player}
+}
+
+func (e PlayerLeft) Fields() log.Fields {
+	return log.Fields{"player": e.Player}
+}
+
+func (e ChatMessage) Fields() log.Fields {
+	return log.Fields{"player": e.Player, "message": e.Message}
+}
+
+func (e PlayerDied) Fields() log.Fields {
+	return log.Fields{"player": e.Player, "message": e.Message}
+}
+
+func (e AdvancementMade) Fields() log.Fields {
+	return log.Fields{"player": e.Player, "advancement": e.Advancement}
+}
+
+func (e ServerDone) Fields() log.Fields {
+	return log.Fields{"startupDuration": e.StartupDuration}
+}
+
+func (e CantKeepUp) Fields() log.Fields {
+	return log.Fields{"lag": e.Lag, "ticksBehind": e.TicksBehind}
+}
@@ -0,0 +1,68 @@
+package minecraft_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Adirelle/mcvisor/pkg/minecraft"
+)
+
+func TestLogParser(t *testing.T) {
+	t.Parallel()
+
+	parser, err := minecraft.NewLogParser([]minecraft.LogPattern{
+		{Event: minecraft.ChatEvent, Pattern: `^\[Discord\] (?P<player>\w+): (?P<message>.*)$`},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string]any{
+		"Adirelle joined the game":                                                                         minecraft.PlayerJoined{Player: "Adirelle"},
+		"[12:34:56] [Server thread/INFO]: Adirelle left the game":                                          minecraft.PlayerLeft{Player: "Adirelle"},
+		"[12:34:56 INFO]: <Adirelle> hello world":                                                          minecraft.ChatMessage{Player: "Adirelle", Message: "hello world"},
+		"[12:34:56] [Server thread/INFO] (Minecraft) <Adirelle> hello":                                     minecraft.ChatMessage{Player: "Adirelle", Message: "hello"},
+		"[Discord] Someone: hi there":                                                                      minecraft.ChatMessage{Player: "Someone", Message: "hi there"},
+		"Adirelle was slain by Zombie":                                                                     minecraft.PlayerDied{Player: "Adirelle", Message: "Adirelle was slain by Zombie"},
+		"Adirelle fell from a high place":                                                                  minecraft.PlayerDied{Player: "Adirelle", Message: "Adirelle fell from a high place"},
+		"Adirelle has made the advancement [Stone Age]":                                                    minecraft.AdvancementMade{Player: "Adirelle", Advancement: "Stone Age"},
+		`Done (12.500s)! For help, type "help"`:                                                            minecraft.ServerDone{StartupDuration: 12500 * time.Millisecond},
+		`[12:34:56] [Server thread/INFO] [minecraft/DedicatedServer]: Done (3,25s)! For help, type "help"`: minecraft.ServerDone{StartupDuration: 3250 * time.Millisecond},
+		"Can't keep up! Is the server overloaded? Running 2051ms or 41 ticks behind":                       minecraft.CantKeepUp{Lag: 2051 * time.Millisecond, TicksBehind: 41},
+		"Preparing level \"world\"":                                                                        nil,
+	}
+
+	for line, expected := range cases {
+		if actual := parser.Parse(line); !reflect.DeepEqual(actual, expected) {
+			t.Errorf("%q: expected %#v, got %#v", line, expected, actual)
+		}
+	}
+}
+
+func TestLogParserInvalidPattern(t *testing.T) {
+	t.Parallel()
+
+	if _, err := minecraft.NewLogParser([]minecraft.LogPattern{{Event: "foo", Pattern: "bar"}}); err == nil {
+		t.Error("expected an error for an unknown event type")
+	}
+	if _, err := minecraft.NewLogParser([]minecraft.LogPattern{{Event: minecraft.ChatEvent, Pattern: "("}}); err == nil {
+		t.Error("expected an error for an invalid pattern")
+	}
+}
+
+func TestLogParserKeepsPatterns(t *testing.T) {
+	t.Parallel()
+
+	spare := minecraft.LogPattern{Event: minecraft.ChatEvent, Pattern: `^spare$`}
+	patterns := make([]minecraft.LogPattern, 1, 1+len(minecraft.DefaultLogPatterns))
+	patterns[0] = minecraft.LogPattern{Event: minecraft.ChatEvent, Pattern: `^custom$`}
+	patterns = append(patterns, spare)[:1]
+
+	if _, err := minecraft.NewLogParser(patterns); err != nil {
+		t.Fatal(err)
+	}
+	if actual := patterns[:2][1]; actual != spare {
+		t.Errorf("the backing array of the patterns was overwritten: %+v", actual)
+	}
+}
@@ -87,14 +87,14 @@ var (
 	ErrPingNever    = errors.New("status unknown")
 )
 
-func NewPinger(config *ServerConfig, statuser Statuser, dispatcher *events.Dispatcher) *Pinger {
+func NewPinger(config *ServerConfig, statuser Statuser, dispatcher *events.Dispatcher, registry *commands.Registry) *Pinger {
 	p := &Pinger{
 		ServerConfig: config,
 		Dispatcher:   dispatcher,
 		statuser:     statuser,
 		pings:        make(chan PingerEvent),
 	}
-	commands.Register(OnlineCommand, "list online players", discord.QueryCategory, commands.HandlerFunc(p.handleOnlineCommand))
+	registry.Register(OnlineCommand, "list online players", discord.QueryCategory, commands.HandlerFunc(p.handleOnlineCommand))
 	return p
 }
 
@@ -116,7 +116,9 @@ func (p *Pinger) Serve(ctx context.Context) (err error) {
 			log.WithField("result", p.lastPing).Debug("pinger.update")
 			p.Dispatch(p.lastPing)
 		case when := <-ticker.C:
-			if p.statuser.Status().IsRunning() {
+			if _, disabled := p.strategy.(nullPingStrategy); disabled {
+				p.lastPing = p.strategy.Ping(when)
+			} else if p.statuser.Status().IsRunning() {
 				go p.Ping(when, ctx)
 			} else {
 				p.lastPing = &PingFailed{when, ErrPingNever}
@@ -156,7 +158,7 @@ func (p *Pinger) getPingStrategy() (pingStrategy, error) {
 		p.Network.Host = props.String("server-ip", "localhost")
 	}
 	if p.Network.Port == 0 {
-		p.Network.Port = uint16(props.Int("server-port", 25565))
+		p.Network.Port = uint16(props.Int("server-port", DefaultServerPort))
 	}
 
 	if props.Bool("enable-query", false) {
@@ -202,7 +204,7 @@ func (p *statusPingStrategy) Ping(when time.Time) PingerEvent {
 }
 
 func (p nullPingStrategy) Ping(when time.Time) PingerEvent {
-	return PingFailed{when, ErrPingDisabled}
+	return &PingFailed{when, ErrPingDisabled}
 }
 
 func (PingSucceeded) IsSuccess() bool {
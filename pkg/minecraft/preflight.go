@@ -0,0 +1,271 @@
+package minecraft
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Adirelle/mcvisor/pkg/discord"
+	"github.com/apex/log"
+)
+
+type (
+	// PreflightConfig lists the checks run before starting the server. The start is blocked if any of them fails,
+	// and retried according to the restart policy.
+	//
+	// - ports checks that the game, query and RCON ports, as set in server.properties, are not used by another process,
+	// - session_lock checks that the session.lock file of the world is not held by another server,
+	// - min_free_space is the minimum free disk space in the working directory, as an absolute size (e.g. "1G"),
+	// - dependencies are TCP services that must be reachable, like a database.
+	PreflightConfig struct {
+		Ports        bool                `json:"ports"`
+		SessionLock  bool                `json:"session_lock"`
+		MinFreeSpace MemorySize          `json:"min_free_space,omitempty"`
+		Dependencies []*DependencyConfig `json:"dependencies,omitempty" validate:"dive"`
+		Timeout      time.Duration       `json:"timeout"`
+	}
+
+	DependencyConfig struct {
+		Name    string `json:"name" validate:"required"`
+		Address string `json:"address" validate:"required,hostname_port"`
+	}
+
+	PreflightCheck string
+
+	PreflightFailure struct {
+		Check PreflightCheck
+		Err   error
+	}
+
+	// PreflightFailed is dispatched when the server cannot be started because some checks failed.
+	PreflightFailed struct {
+		Failures   []*PreflightFailure
+		Retrying   bool
+		RetryDelay time.Duration
+	}
+)
+
+const (
+	PortsCheck       PreflightCheck = "ports"
+	SessionLockCheck PreflightCheck = "session_lock"
+	DiskSpaceCheck   PreflightCheck = "disk_space"
+	DependencyCheck  PreflightCheck = "dependency"
+
+	DefaultLevelName    = "world"
+	SessionLockFile     = "session.lock"
+	DefaultMinFreeSpace = MemorySize("1G")
+)
+
+var (
+	// Interface checks
+	_ discord.Notification = (*PreflightFailed)(nil)
+	_ log.Fielder          = (*PreflightFailed)(nil)
+
+	ErrPreflightFailed      = errors.New("preflight checks failed")
+	ErrRelativeMinFreeSpace = errors.New("min_free_space must be an absolute size")
+)
+
+func NewPreflightConfig() *PreflightConfig {
+	return &PreflightConfig{
+		Ports:        true,
+		SessionLock:  true,
+		MinFreeSpace: DefaultMinFreeSpace,
+		Timeout:      5 * time.Second,
+	}
+}
+
+// Validate checks the minimum free space.
+func (c *PreflightConfig) Validate() error {
+	if c.MinFreeSpace == "" {
+		return nil
+	}
+	if c.MinFreeSpace.IsRelative() {
+		return ErrRelativeMinFreeSpace
+	}
+	return c.MinFreeSpace.Validate()
+}
+
+// RunPreflightChecks runs the configured checks and returns the failed ones.
+func RunPreflightChecks(ctx context.Context, c *ServerConfig) (failures []*PreflightFailure) {
+	config := c.Preflight
+	fail := func(check PreflightCheck, err error) {
+		failures = append(failures, &PreflightFailure{check, err})
+	}
+
+	props, err := LoadPropertiesFile(c.AbsServerProperties())
+	if err != nil {
+		log.WithError(err).WithField("path", c.AbsServerProperties()).Warn("server.preflight")
+		props = &PropertiesFile{}
+	}
+
+	if config.Ports {
+		for _, err := range checkPorts(props) {
+			fail(PortsCheck, err)
+		}
+	}
+	if config.SessionLock {
+		if err := checkSessionLock(c.AbsWorkingDir(), props); err != nil {
+			fail(SessionLockCheck, err)
+		}
+	}
+	if config.MinFreeSpace != "" {
+		if err := checkDiskSpace(c.AbsWorkingDir(), config.MinFreeSpace); err != nil {
+			fail(DiskSpaceCheck, err)
+		}
+	}
+	for _, dependency := range config.Dependencies {
+		if err := dependency.check(ctx, config.Timeout); err != nil {
+			fail(DependencyCheck, err)
+		}
+	}
+	return
+}
+
+// checkPorts tries to bind the ports the server will listen to.
+func checkPorts(props *PropertiesFile) (errs []error) {
+	host, _ := props.Get("server-ip")
+	serverPort := readIntProperty(props, "server-port", DefaultServerPort)
+	check := func(name, network string, port int) {
+		address := net.JoinHostPort(host, strconv.Itoa(port))
+		if err := bindPort(network, address); err != nil {
+			errs = append(errs, fmt.Errorf("%s port %s/%s is not available: %w", name, address, network, err))
+		}
+	}
+
+	check("game", "tcp", serverPort)
+	if readBoolProperty(props, "enable-query") {
+		check("query", "udp", readIntProperty(props, "query.port", serverPort))
+	}
+	if readBoolProperty(props, "enable-rcon") {
+		check("RCON", "tcp", readIntProperty(props, "rcon.port", DefaultRconPort))
+	}
+	return
+}
+
+func bindPort(network, address string) error {
+	if network == "udp" {
+		conn, err := net.ListenPacket(network, address)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}
+	listener, err := net.Listen(network, address)
+	if err != nil {
+		return err
+	}
+	return listener.Close()
+}
+
+// checkSessionLock checks that no other process holds the lock of the world.
+func checkSessionLock(workingDir string, props *PropertiesFile) error {
+	level, found := props.Get("level-name")
+	if !found || level == "" {
+		level = DefaultLevelName
+	}
+	path := absPath(workingDir, filepath.Join(level, SessionLockFile))
+	held, err := isSessionLockHeld(path)
+	if err != nil {
+		return fmt.Errorf("could not check %s: %w", path, err)
+	}
+	if held {
+		return fmt.Errorf("%s is held by another process", path)
+	}
+	return nil
+}
+
+func checkDiskSpace(dir string, minimum MemorySize) error {
+	// Relative sizes are resolved against the host memory, not the disk size.
+	if minimum.IsRelative() {
+		return ErrRelativeMinFreeSpace
+	}
+	required, err := minimum.Bytes()
+	if err != nil {
+		return err
+	}
+	free, err := freeDiskSpace(dir)
+	if err != nil {
+		return fmt.Errorf("could not check the free space of %s: %w", dir, err)
+	}
+	if free < required {
+		return fmt.Errorf("only %s free in %s, %s required", humanBytes(free), dir, humanBytes(required))
+	}
+	return nil
+}
+
+func (d *DependencyConfig) check(ctx context.Context, timeout time.Duration) error {
+	dialer := net.Dialer{Timeout: timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", d.Address)
+	if err != nil {
+		return fmt.Errorf("%s (%s) is not reachable: %w", d.Name, d.Address, err)
+	}
+	return conn.Close()
+}
+
+func readIntProperty(props *PropertiesFile, key string, defaultValue int) int {
+	if value, found := props.Get(key); found {
+		if number, err := strconv.Atoi(strings.TrimSpace(value)); err == nil && number > 0 {
+			return number
+		}
+	}
+	return defaultValue
+}
+
+func readBoolProperty(props *PropertiesFile, key string) bool {
+	value, _ := props.Get(key)
+	return strings.EqualFold(strings.TrimSpace(value), "true")
+}
+
+// preflight runs the checks before launching the server. It returns whether any of them failed and, if so, a channel
+// that fires when the start should be retried, or nil if it should not.
+func (s *Server) preflight(ctx context.Context) (failed bool, retry <-chan time.Time) {
+	failures := RunPreflightChecks(ctx, s.Server)
+	if len(failures) == 0 {
+		return false, nil
+	}
+
+	event := &PreflightFailed{Failures: failures}
+	if s.Server.Restart.ShouldRestart(ErrPreflightFailed) {
+		event.RetryDelay, event.Retrying = s.restarter.NextDelay(time.Now())
+	}
+	log.WithFields(event).Error("server.preflight")
+	s.dispatcher.Dispatch(event)
+	if !event.Retrying {
+		s.setTarget(StopTarget)
+		return true, nil
+	}
+	return true, time.After(event.RetryDelay)
+}
+
+func (f *PreflightFailure) String() string {
+	return fmt.Sprintf("%s: %s", f.Check, f.Err)
+}
+
+func (e *PreflightFailed) Fields() log.Fields {
+	failures := make([]string, len(e.Failures))
+	for i, failure := range e.Failures {
+		failures[i] = failure.String()
+	}
+	return log.Fields{
+		"failures":   failures,
+		"retrying":   e.Retrying,
+		"retryDelay": e.RetryDelay,
+	}
+}
+
+func (e *PreflightFailed) DiscordNotification() string {
+	builder := &strings.Builder{}
+	_, _ = builder.WriteString("**Cannot start the server**, preflight checks failed:")
+	for _, failure := range e.Failures {
+		_, _ = fmt.Fprintf(builder, "\n- %s", failure)
+	}
+	if e.Retrying {
+		_, _ = fmt.Fprintf(builder, "\nRetrying in %s", e.RetryDelay)
+	}
+	return builder.String()
+}
@@ -0,0 +1,128 @@
+package minecraft_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/Adirelle/mcvisor/pkg/commands"
+	"github.com/Adirelle/mcvisor/pkg/events"
+	"github.com/Adirelle/mcvisor/pkg/minecraft"
+)
+
+func newPreflightTestConfig(t *testing.T) (*minecraft.Config, net.Listener) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+
+	config := minecraft.NewConfig(t.TempDir())
+	port := listener.Addr().(*net.TCPAddr).Port
+	properties := fmt.Sprintf("server-ip=127.0.0.1\nserver-port=%d\n", port)
+	if err := os.WriteFile(config.Server.AbsServerProperties(), []byte(properties), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return config, listener
+}
+
+func TestRunPreflightChecks(t *testing.T) {
+	t.Parallel()
+
+	config, listener := newPreflightTestConfig(t)
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = closed.Close()
+	config.Server.Preflight.MinFreeSpace = "1000000T"
+	config.Server.Preflight.Dependencies = []*minecraft.DependencyConfig{
+		{Name: "up", Address: listener.Addr().String()},
+		{Name: "down", Address: closed.Addr().String()},
+	}
+
+	failures := minecraft.RunPreflightChecks(context.Background(), config.Server)
+	checks := make([]minecraft.PreflightCheck, len(failures))
+	for i, failure := range failures {
+		checks[i] = failure.Check
+	}
+	expected := []minecraft.PreflightCheck{minecraft.PortsCheck, minecraft.DiskSpaceCheck, minecraft.DependencyCheck}
+	if fmt.Sprint(checks) != fmt.Sprint(expected) {
+		t.Errorf("expected %v failures, got %v", expected, failures)
+	}
+
+	config.Server.Preflight = minecraft.NewPreflightConfig()
+	config.Server.Preflight.Ports = false
+	if failures := minecraft.RunPreflightChecks(context.Background(), config.Server); len(failures) != 0 {
+		t.Errorf("unexpected failures: %v", failures)
+	}
+}
+
+func TestPreflightConfigValidate(t *testing.T) {
+	t.Parallel()
+
+	config := minecraft.NewPreflightConfig()
+	if err := config.Validate(); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+	config.MinFreeSpace = "10%"
+	if err := config.Validate(); !errors.Is(err, minecraft.ErrRelativeMinFreeSpace) {
+		t.Errorf("expected ErrRelativeMinFreeSpace, got %v", err)
+	}
+	config.MinFreeSpace = "lots"
+	if err := config.Validate(); !errors.Is(err, minecraft.ErrInvalidMemorySize) {
+		t.Errorf("expected ErrInvalidMemorySize, got %v", err)
+	}
+}
+
+func TestPreflightRelativeFreeSpace(t *testing.T) {
+	t.Parallel()
+
+	config, _ := newPreflightTestConfig(t)
+	config.Server.Preflight.Ports = false
+	config.Server.Preflight.MinFreeSpace = "1%"
+
+	failures := minecraft.RunPreflightChecks(context.Background(), config.Server)
+	if len(failures) != 1 || failures[0].Check != minecraft.DiskSpaceCheck || !errors.Is(failures[0].Err, minecraft.ErrRelativeMinFreeSpace) {
+		t.Errorf("expected a relative size to be rejected, got %v", failures)
+	}
+}
+
+func TestPreflightRetry(t *testing.T) {
+	t.Parallel()
+
+	config, _ := newPreflightTestConfig(t)
+	config.Server.Restart.MinBackoff = 10 * time.Millisecond
+	config.Server.Restart.MaxRestarts = 1
+	if err := minecraft.AcceptEULA(config.Server.AbsEULAFile(), "test", time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	dispatcher := events.NewDispatcher()
+	failures := events.MakeHandler[*minecraft.PreflightFailed]()
+	defer dispatcher.Subscribe(failures).Cancel()
+	server := minecraft.NewServer(config, dispatcher, commands.NewRegistry())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	go func() { _ = server.Serve(ctx) }()
+	server.Start()
+
+	// The start is retried until the restart policy gives up.
+	for retrying := true; retrying; {
+		select {
+		case failed := <-failures:
+			if len(failed.Failures) != 1 || failed.Failures[0].Check != minecraft.PortsCheck {
+				t.Errorf("unexpected event: %+v", failed)
+			}
+			retrying = failed.Retrying
+		case <-ctx.Done():
+			t.Fatal("the start was retried indefinitely")
+		}
+	}
+}
@@ -0,0 +1,43 @@
+//go:build windows
+
+package minecraft
+
+import (
+	"errors"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+const errorLockViolation syscall.Errno = 33
+
+var getDiskFreeSpaceEx = syscall.NewLazyDLL("kernel32.dll").NewProc("GetDiskFreeSpaceExW")
+
+// isSessionLockHeld tests whether another process holds a lock on the file: reading it fails then.
+func isSessionLockHeld(path string) (bool, error) {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	buffer := make([]byte, 1)
+	if _, err := file.Read(buffer); errors.Is(err, errorLockViolation) {
+		return true, nil
+	}
+	return false, nil
+}
+
+func freeDiskSpace(dir string) (uint64, error) {
+	name, err := syscall.UTF16PtrFromString(dir)
+	if err != nil {
+		return 0, err
+	}
+	var available uint64
+	if ret, _, err := getDiskFreeSpaceEx.Call(uintptr(unsafe.Pointer(name)), uintptr(unsafe.Pointer(&available)), 0, 0); ret == 0 {
+		return 0, err
+	}
+	return available, nil
+}
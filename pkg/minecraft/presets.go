@@ -0,0 +1,152 @@
+package minecraft
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+type (
+	// JavaPreset is a named set of garbage collector tuning options.
+	JavaPreset string
+)
+
+const (
+	DefaultPreset JavaPreset = "default"
+	AikarPreset   JavaPreset = "aikar"
+	ZGCPreset     JavaPreset = "zgc"
+	NoPreset      JavaPreset = "none"
+
+	// aikarLargeHeap is the heap size above which Aikar's flags are adjusted.
+	aikarLargeHeap = 12 * GiB
+)
+
+// legacyOptions are the options that were written in the configuration before presets existed.
+var legacyOptions = []string{
+	"-XX:+UnlockExperimentalVMOptions",
+	"-XX:+UseG1GC",
+	"-XX:G1NewSizePercent=20",
+	"-XX:G1ReservePercent=20",
+	"-XX:MaxGCPauseMillis=50",
+	"-XX:G1HeapRegionSize=32M",
+}
+
+// Options returns the options of the preset for the given maximum heap size (0 if unknown).
+func (p JavaPreset) Options(maxHeap uint64) []string {
+	switch p {
+	case AikarPreset:
+		// https://docs.papermc.io/paper/aikars-flags
+		newSize, maxNewSize, regionSize, reserve, occupancy := 30, 40, "8M", 20, 15
+		if maxHeap > aikarLargeHeap {
+			newSize, maxNewSize, regionSize, reserve, occupancy = 40, 50, "16M", 15, 20
+		}
+		return []string{
+			"-XX:+UseG1GC",
+			"-XX:+ParallelRefProcEnabled",
+			"-XX:MaxGCPauseMillis=200",
+			"-XX:+UnlockExperimentalVMOptions",
+			"-XX:+DisableExplicitGC",
+			"-XX:+AlwaysPreTouch",
+			fmt.Sprintf("-XX:G1NewSizePercent=%d", newSize),
+			fmt.Sprintf("-XX:G1MaxNewSizePercent=%d", maxNewSize),
+			"-XX:G1HeapRegionSize=" + regionSize,
+			fmt.Sprintf("-XX:G1ReservePercent=%d", reserve),
+			"-XX:G1HeapWastePercent=5",
+			"-XX:G1MixedGCCountTarget=4",
+			fmt.Sprintf("-XX:InitiatingHeapOccupancyPercent=%d", occupancy),
+			"-XX:G1MixedGCLiveThresholdPercent=90",
+			"-XX:G1RSetUpdatingPauseTimePercent=5",
+			"-XX:SurvivorRatio=32",
+			"-XX:+PerfDisableSharedMem",
+			"-XX:MaxTenuringThreshold=1",
+			"-Dusing.aikars.flags=https://mcflags.emc.gs",
+			"-Daikars.new.flags=true",
+		}
+	case ZGCPreset:
+		return []string{
+			"-XX:+UnlockExperimentalVMOptions",
+			"-XX:+UseZGC",
+			"-XX:+DisableExplicitGC",
+			"-XX:+AlwaysPreTouch",
+			"-XX:+PerfDisableSharedMem",
+		}
+	case DefaultPreset:
+		return append([]string{}, legacyOptions...)
+	default:
+		return nil
+	}
+}
+
+// UnmarshalJSON drops the legacy default options, which would otherwise be added again to the ones of the preset.
+func (c *JavaConfig) UnmarshalJSON(data []byte) error {
+	type plain JavaConfig
+	if err := json.Unmarshal(data, (*plain)(c)); err != nil {
+		return err
+	}
+	if c.Preset != NoPreset && equalOptions(c.Options, legacyOptions) {
+		c.Options = []string{}
+	}
+	return nil
+}
+
+// equalOptions returns whether both lists hold the same options in the same order.
+func equalOptions(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Validate checks that the heap size is not set both by the memory settings and by the options.
+func (c JavaConfig) Validate() error {
+	for _, size := range []MemorySize{c.MinMemory, c.MaxMemory} {
+		if size == "" {
+			continue
+		}
+		if err := size.Validate(); err != nil {
+			return err
+		}
+	}
+	if c.MinMemory != "" && hasOption(c.Options, minHeapOptions) || c.MaxMemory != "" && hasOption(c.Options, maxHeapOptions) {
+		return ErrMemoryConflict
+	}
+	if c.MinMemory != "" && c.MaxMemory != "" && !c.MinMemory.IsRelative() && !c.MaxMemory.IsRelative() {
+		minHeap, _ := c.MinMemory.Bytes()
+		maxHeap, _ := c.MaxMemory.Bytes()
+		if minHeap > maxHeap {
+			return ErrMemoryRange
+		}
+	}
+	return nil
+}
+
+// JVMOptions returns the heap size options, followed by the options of the preset and the configured ones.
+func (c JavaConfig) JVMOptions() ([]string, error) {
+	var options []string
+	var minHeap, maxHeap uint64
+	if c.MinMemory != "" {
+		option, err := c.MinMemory.JavaOption("-Xms")
+		if err != nil {
+			return nil, err
+		}
+		minHeap, _ = c.MinMemory.Bytes()
+		options = append(options, option)
+	}
+	if c.MaxMemory != "" {
+		option, err := c.MaxMemory.JavaOption("-Xmx")
+		if err != nil {
+			return nil, err
+		}
+		maxHeap, _ = c.MaxMemory.Bytes()
+		options = append(options, option)
+	}
+	if minHeap > 0 && maxHeap > 0 && minHeap > maxHeap {
+		return nil, ErrMemoryRange
+	}
+	options = append(options, c.Preset.Options(c.MaxHeap())...)
+	return append(options, c.Options...), nil
+}
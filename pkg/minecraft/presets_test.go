@@ -0,0 +1,121 @@
+package minecraft_test
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/Adirelle/mcvisor/pkg/minecraft"
+)
+
+func TestMemorySize(t *testing.T) {
+	t.Parallel()
+
+	cases := map[minecraft.MemorySize]uint64{
+		"512M":  512 * minecraft.MiB,
+		"4G":    4 * minecraft.GiB,
+		"4GiB":  4 * minecraft.GiB,
+		"1.5g":  3 * minecraft.GiB / 2,
+		"256mb": 256 * minecraft.MiB,
+	}
+	for size, expected := range cases {
+		if actual, err := size.Bytes(); err != nil || actual != expected {
+			t.Errorf("%s: expected %d, got %d (%v)", size, expected, actual, err)
+		}
+	}
+
+	for _, size := range []minecraft.MemorySize{"4096", "4X", "G", "-1G", "0%", "150%"} {
+		if err := size.Validate(); !errors.Is(err, minecraft.ErrInvalidMemorySize) {
+			t.Errorf("%s: expected ErrInvalidMemorySize, got %v", size, err)
+		}
+	}
+}
+
+func TestJavaConfigValidate(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		config   minecraft.JavaConfig
+		expected error
+	}{
+		{minecraft.JavaConfig{MaxMemory: "4G"}, nil},
+		{minecraft.JavaConfig{Options: []string{"-Xmx4G"}}, nil},
+		{minecraft.JavaConfig{MaxMemory: "4G", Options: []string{"-Xmx4G"}}, minecraft.ErrMemoryConflict},
+		{minecraft.JavaConfig{MinMemory: "1G", Options: []string{"-Xms512M"}}, minecraft.ErrMemoryConflict},
+		{minecraft.JavaConfig{MinMemory: "1G", Options: []string{"-Xmx4G"}}, nil},
+		{minecraft.JavaConfig{MinMemory: "8G", MaxMemory: "4G"}, minecraft.ErrMemoryRange},
+		{minecraft.JavaConfig{MaxMemory: "lots"}, minecraft.ErrInvalidMemorySize},
+	}
+	for _, c := range cases {
+		if err := c.config.Validate(); !errors.Is(err, c.expected) {
+			t.Errorf("%+v: expected %v, got %v", c.config, c.expected, err)
+		}
+	}
+}
+
+func TestJVMOptions(t *testing.T) {
+	t.Parallel()
+
+	config := minecraft.JavaConfig{
+		MinMemory: "2G",
+		MaxMemory: "16G",
+		Preset:    minecraft.AikarPreset,
+		Options:   []string{"-Dfoo=bar"},
+	}
+	options, err := config.JVMOptions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(options[:2], []string{"-Xms2048M", "-Xmx16384M"}) {
+		t.Errorf("unexpected heap options: %v", options[:2])
+	}
+	if options[len(options)-1] != "-Dfoo=bar" {
+		t.Errorf("configured options should come last: %v", options)
+	}
+	found := false
+	for _, option := range options {
+		found = found || option == "-XX:G1HeapRegionSize=16M"
+	}
+	if !found {
+		t.Errorf("large heap variant of Aikar's flags expected: %v", options)
+	}
+}
+
+func TestJVMOptionsHeapFromOptions(t *testing.T) {
+	t.Parallel()
+
+	config := minecraft.JavaConfig{Preset: minecraft.AikarPreset, Options: []string{"-Xmx16G"}}
+	options, err := config.JVMOptions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, option := range options {
+		found = found || option == "-XX:G1HeapRegionSize=16M"
+	}
+	if !found {
+		t.Errorf("large heap variant of Aikar's flags expected: %v", options)
+	}
+}
+
+func TestJavaConfigLegacyOptions(t *testing.T) {
+	t.Parallel()
+
+	legacy := `["-XX:+UnlockExperimentalVMOptions","-XX:+UseG1GC","-XX:G1NewSizePercent=20",` +
+		`"-XX:G1ReservePercent=20","-XX:MaxGCPauseMillis=50","-XX:G1HeapRegionSize=32M"]`
+	cases := map[string]int{
+		`{"options":` + legacy + `}`:                 0,
+		`{"preset":"none","options":` + legacy + `}`: 6,
+		`{"options":["-XX:+UseG1GC","-Dfoo=bar"]}`:   2,
+	}
+	for data, expected := range cases {
+		config := minecraft.NewConfig(t.TempDir()).Java
+		if err := json.Unmarshal([]byte(data), config); err != nil {
+			t.Fatal(err)
+		}
+		if len(config.Options) != expected {
+			t.Errorf("%s: expected %d options, got %v", data, expected, config.Options)
+		}
+	}
+}
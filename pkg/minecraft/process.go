@@ -2,50 +2,101 @@ package minecraft
 
 import (
 	"bufio"
-	"context"
 	_ "embed"
 	"fmt"
 	"io"
 	"os"
 	"os/exec"
+	"sync/atomic"
+	"time"
 
+	"github.com/Adirelle/mcvisor/pkg/discord"
 	"github.com/Adirelle/mcvisor/pkg/events"
 	"github.com/apex/log"
 )
 
 type (
 	process struct {
-		Cmd   *exec.Cmd
-		Done  chan struct{}
-		Err   error
-		Stdin io.Writer
-		Stop  func()
+		// Cmd is the command that started the server, nil if the process has been adopted.
+		Cmd     *exec.Cmd
+		Process *os.Process
+		Adopted bool
+		Done    chan struct{}
+		Err     error
+		Failure error
+		Stdin   io.WriteCloser
+		// Java is the Java runtime running the server.
+		Java *JavaRuntime
+		// Minecraft is the version of the server, if known.
+		Minecraft *MinecraftVersion
 		*events.Dispatcher
+		parser *LogParser
+
+		// Detached servers only.
+		detached        *ServerConfig
+		tailer          *logTailer
+		closeAfterStart []io.Closer
+		// stopping is set to 1 once the server has been asked to stop, or has logged that it is stopping.
+		stopping int32
 	}
 
 	ServerOutput string
+
+	ForcedStop struct {
+		Signal  string
+		Timeout time.Duration
+	}
+)
+
+var (
+	// Interface check
+	_ discord.Notification = (*ForcedStop)(nil)
 )
 
 //go:embed log4j.xml
 var log4jFile []byte
 
-func newProcess(c *Config, d *events.Dispatcher) (p *process, err error) {
-	if err = os.WriteFile(c.Server.AbsLog4JConf(), log4jFile, os.FileMode(0o644)); err != nil {
+func newProcess(c *Config, d *events.Dispatcher, parser *LogParser) (p *process, err error) {
+	log4jConf := log4jFile
+	if c.Server.Detached.Enabled {
+		// Detached servers have no standard output, their console output is written to their log file.
+		log4jConf = log4jDetachedFile
+	}
+	if err = os.WriteFile(c.Server.AbsLog4JConf(), log4jConf, os.FileMode(0o644)); err != nil {
 		return
 	}
 
+	c, java, version, err := c.withJavaRuntime()
+	if err != nil {
+		return nil, err
+	}
+	logger := log.WithFields(java)
+	if version != nil {
+		logger = logger.WithField("minecraft", version.ID)
+	}
+	logger.Info("server.java")
+
 	p = &process{
 		Done:       make(chan struct{}),
 		Dispatcher: d,
+		parser:     parser,
+		Java:       java,
+		Minecraft:  version,
 	}
 
-	cmdLine := c.Command()
+	cmdLine, err := c.Command()
+	if err != nil {
+		return nil, err
+	}
+	log.WithField("command", cmdLine).Info("server.command")
 
-	var stopCtx context.Context
-	stopCtx, p.Stop = context.WithCancel(context.Background())
-	p.Cmd = exec.CommandContext(stopCtx, cmdLine[0], cmdLine[1:]...)
+	p.Cmd = exec.Command(cmdLine[0], cmdLine[1:]...)
 	p.Cmd.Dir = c.WorkingDir()
 	p.Cmd.Env = c.Env()
+	if c.Server.Detached.Enabled {
+		return p, p.detach(c.Server)
+	}
+	setProcessGroup(p.Cmd)
 
 	if p.Stdin, err = p.Cmd.StdinPipe(); err != nil {
 		return
@@ -67,9 +118,23 @@ func newProcess(c *Config, d *events.Dispatcher) (p *process, err error) {
 }
 
 func (p *process) Start() error {
-	if err := p.Cmd.Start(); err != nil {
+	err := p.Cmd.Start()
+	for _, closer := range p.closeAfterStart {
+		_ = closer.Close()
+	}
+	if err != nil {
 		return fmt.Errorf("could not start server: %w", err)
 	}
+	p.Process = p.Cmd.Process
+
+	if p.detached != nil {
+		if err := p.started(); err != nil {
+			log.WithError(err).WithField("pid", p.Process.Pid).Error("server.detached")
+			_ = killProcessGroup(p.Process)
+			_ = p.Cmd.Wait()
+			return fmt.Errorf("could not start detached server: %w", err)
+		}
+	}
 
 	go p.Wait()
 	return nil
@@ -77,7 +142,65 @@ func (p *process) Start() error {
 
 func (p *process) Wait() {
 	defer close(p.Done)
-	p.Err = p.Cmd.Wait()
+	defer p.cleanup()
+	if p.Adopted {
+		p.waitAdopted()
+	} else {
+		p.Err = p.Cmd.Wait()
+	}
+}
+
+// ExitError returns the reason why the server had to be stopped, or the error returned by the process.
+func (p *process) ExitError() error {
+	if p.Failure != nil {
+		return p.Failure
+	}
+	return p.Err
+}
+
+// Stop asks the server to stop using the "stop" console command. If the server is still running after stopTimeout,
+// its process group receives SIGTERM, then SIGKILL if it is still running after termTimeout.
+func (p *process) Stop(stopTimeout, termTimeout time.Duration) {
+	go p.stop(stopTimeout, termTimeout)
+}
+
+func (p *process) stop(stopTimeout, termTimeout time.Duration) {
+	logger := log.WithField("pid", p.Process.Pid)
+	atomic.StoreInt32(&p.stopping, 1)
+
+	logger.Info("server.stop.console")
+	if _, err := io.WriteString(p.Stdin, "stop\n"); err != nil {
+		logger.WithError(err).Warn("server.stop.console")
+	}
+	if p.waitFor(stopTimeout) {
+		return
+	}
+
+	logger.WithField("timeout", stopTimeout).Warn("server.stop.terminate")
+	p.Dispatch(&ForcedStop{"SIGTERM", stopTimeout})
+	if err := terminateProcessGroup(p.Process); err != nil {
+		logger.WithError(err).Error("server.stop.terminate")
+	}
+	if p.waitFor(termTimeout) {
+		return
+	}
+
+	logger.WithField("timeout", termTimeout).Warn("server.stop.kill")
+	p.Dispatch(&ForcedStop{"SIGKILL", termTimeout})
+	if err := killProcessGroup(p.Process); err != nil {
+		logger.WithError(err).Error("server.stop.kill")
+	}
+}
+
+func (p *process) waitFor(timeout time.Duration) bool {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-p.Done:
+		return true
+	case <-timer.C:
+		return false
+	}
 }
 
 func readLines(rd io.Reader, f func(string)) {
@@ -92,8 +215,19 @@ func readLines(rd io.Reader, f func(string)) {
 
 func (p *process) DispatchStdout(line string) {
 	p.Dispatch(ServerOutput(line))
+	if event := p.parser.Parse(line); event != nil {
+		p.Dispatch(event)
+	}
 }
 
 func (p *process) LogStderr(line string) {
 	log.WithField("output", line).Warn("server.stderr")
 }
+
+func (f *ForcedStop) Fields() log.Fields {
+	return log.Fields{"signal": f.Signal, "timeout": f.Timeout}
+}
+
+func (f *ForcedStop) DiscordNotification() string {
+	return fmt.Sprintf("**Server did not stop within %s, sending %s**", f.Timeout, f.Signal)
+}
@@ -0,0 +1,48 @@
+package minecraft
+
+import (
+	"time"
+)
+
+type (
+	// procStat holds the raw counters of a process, read from /proc/<pid> on Linux.
+	procStat struct {
+		When       time.Time
+		CPUTicks   uint64
+		RSS        uint64
+		Threads    int
+		FDs        int
+		ReadBytes  uint64
+		WriteBytes uint64
+	}
+)
+
+// clockTicks is the value of USER_HZ, which is 100 on all mainstream Linux architectures.
+const clockTicks = 100
+
+// sample computes the rates since the previous reading, if any.
+func (s *procStat) sample(previous *procStat) *ProcessSample {
+	sample := &ProcessSample{
+		When:    s.When,
+		RSS:     s.RSS,
+		Threads: s.Threads,
+		FDs:     s.FDs,
+	}
+	if previous == nil {
+		return sample
+	}
+	elapsed := s.When.Sub(previous.When).Seconds()
+	if elapsed <= 0 {
+		return sample
+	}
+	if s.CPUTicks >= previous.CPUTicks {
+		sample.CPU = float64(s.CPUTicks-previous.CPUTicks) / clockTicks / elapsed * 100
+	}
+	if s.ReadBytes >= previous.ReadBytes {
+		sample.ReadRate = float64(s.ReadBytes-previous.ReadBytes) / elapsed
+	}
+	if s.WriteBytes >= previous.WriteBytes {
+		sample.WriteRate = float64(s.WriteBytes-previous.WriteBytes) / elapsed
+	}
+	return sample
+}
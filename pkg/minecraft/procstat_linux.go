@@ -0,0 +1,119 @@
+//go:build linux
+
+package minecraft
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	ProcDir = "/proc"
+
+	MonitorSupported = true
+)
+
+var pageSize = uint64(os.Getpagesize())
+
+// readProcStat reads the resource usage of a process from /proc.
+func readProcStat(pid int, when time.Time) (*procStat, error) {
+	dir := filepath.Join(ProcDir, strconv.Itoa(pid))
+	stat := &procStat{When: when}
+
+	content, err := os.ReadFile(filepath.Join(dir, "stat"))
+	if err != nil {
+		return nil, err
+	}
+	// The command name, in parentheses, may contain spaces.
+	end := strings.LastIndexByte(string(content), ')')
+	if end < 0 {
+		return nil, fmt.Errorf("%s/stat: unexpected format", dir)
+	}
+	// Fields after the command name, starting with the state (field 3 in proc(5)).
+	fields := strings.Fields(string(content[end+1:]))
+	if len(fields) < 22 {
+		return nil, fmt.Errorf("%s/stat: unexpected format", dir)
+	}
+	utime, _ := strconv.ParseUint(fields[11], 10, 64)
+	stime, _ := strconv.ParseUint(fields[12], 10, 64)
+	threads, _ := strconv.Atoi(fields[17])
+	rss, _ := strconv.ParseUint(fields[21], 10, 64)
+	stat.CPUTicks = utime + stime
+	stat.Threads = threads
+	stat.RSS = rss * pageSize
+
+	if fds, err := os.ReadDir(filepath.Join(dir, "fd")); err == nil {
+		stat.FDs = len(fds)
+	}
+
+	// /proc/<pid>/io is only readable by the owner of the process.
+	if file, err := os.Open(filepath.Join(dir, "io")); err == nil {
+		defer file.Close()
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			key, value, _ := strings.Cut(scanner.Text(), ": ")
+			switch key {
+			case "read_bytes":
+				stat.ReadBytes, _ = strconv.ParseUint(value, 10, 64)
+			case "write_bytes":
+				stat.WriteBytes, _ = strconv.ParseUint(value, 10, 64)
+			}
+		}
+	}
+
+	return stat, nil
+}
+
+// findProcess returns the PID of the first process named name among pid and its descendants, or 0 if there is none.
+func findProcess(pid int, name string) int {
+	entries, err := os.ReadDir(ProcDir)
+	if err != nil {
+		return 0
+	}
+	names := make(map[int]string)
+	children := make(map[int][]int)
+	for _, entry := range entries {
+		child, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		comm, parent, err := readProcParent(child)
+		if err != nil {
+			continue
+		}
+		names[child] = comm
+		children[parent] = append(children[parent], child)
+	}
+
+	for queue := []int{pid}; len(queue) > 0; queue = queue[1:] {
+		if names[queue[0]] == name {
+			return queue[0]
+		}
+		queue = append(queue, children[queue[0]]...)
+	}
+	return 0
+}
+
+// readProcParent returns the command name and the parent PID of a process.
+func readProcParent(pid int) (comm string, parent int, err error) {
+	content, err := os.ReadFile(filepath.Join(ProcDir, strconv.Itoa(pid), "stat"))
+	if err != nil {
+		return "", 0, err
+	}
+	start, end := strings.IndexByte(string(content), '('), strings.LastIndexByte(string(content), ')')
+	if start < 0 || end < start {
+		return "", 0, fmt.Errorf("%d/stat: unexpected format", pid)
+	}
+	// The state and the parent PID follow the command name.
+	fields := strings.Fields(string(content[end+1:]))
+	if len(fields) < 2 {
+		return "", 0, fmt.Errorf("%d/stat: unexpected format", pid)
+	}
+	parent, err = strconv.Atoi(fields[1])
+	return string(content[start+1 : end]), parent, err
+}
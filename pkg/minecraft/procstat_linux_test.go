@@ -0,0 +1,51 @@
+//go:build linux
+
+package minecraft_test
+
+import (
+	"os"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/Adirelle/mcvisor/pkg/minecraft"
+)
+
+func TestFindProcess(t *testing.T) {
+	t.Parallel()
+
+	// The trailing command keeps the shell from replacing itself with sleep.
+	cmd := exec.Command("/bin/sh", "-c", "sleep 10; true")
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+	})
+	shell := cmd.Process.Pid
+
+	var sleep int
+	for deadline := time.Now().Add(5 * time.Second); sleep == 0 || sleep == shell; {
+		if time.Now().After(deadline) {
+			t.Fatal("sleep was not found")
+		}
+		time.Sleep(10 * time.Millisecond)
+		sleep = minecraft.FindProcess(shell, "sleep")
+	}
+	t.Cleanup(func() {
+		if process, err := os.FindProcess(sleep); err == nil {
+			_ = process.Kill()
+		}
+	})
+
+	if pid := minecraft.FindProcess(shell, "sh"); pid != shell {
+		t.Errorf("expected the shell itself (%d), got %d", shell, pid)
+	}
+	if pid := minecraft.FindProcess(shell, minecraft.JavaProcessName); pid != 0 {
+		t.Errorf("expected no java process, got %d", pid)
+	}
+	if pid := minecraft.FindProcess(os.Getpid(), "sleep"); pid != sleep {
+		t.Errorf("expected the grandchild %d, got %d", sleep, pid)
+	}
+}
@@ -0,0 +1,21 @@
+//go:build !linux
+
+package minecraft
+
+import (
+	"errors"
+	"time"
+)
+
+// The monitor reads /proc, which only Linux provides in the expected format.
+const MonitorSupported = false
+
+var ErrMonitorUnsupported = errors.New("process monitoring is only supported on Linux")
+
+func readProcStat(int, time.Time) (*procStat, error) {
+	return nil, ErrMonitorUnsupported
+}
+
+func findProcess(int, string) int {
+	return 0
+}
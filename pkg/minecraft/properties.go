@@ -0,0 +1,318 @@
+package minecraft
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/Adirelle/mcvisor/pkg/commands"
+	"github.com/Adirelle/mcvisor/pkg/discord"
+	"github.com/apex/log"
+	"golang.org/x/exp/slices"
+)
+
+type (
+	// propertySpec describes the values accepted by a known server property.
+	propertySpec struct {
+		kind   propertyKind
+		min    int
+		max    int
+		values []string
+		secret bool
+		// live is the console command that applies the value to a running server, if any.
+		live func(value string) string
+	}
+
+	propertyKind int
+
+	// propertiesTracker remembers the properties the running server has been started with,
+	// to tell which changes require a restart.
+	propertiesTracker struct {
+		mu     sync.Mutex
+		loaded map[string]string
+	}
+)
+
+const (
+	stringProperty propertyKind = iota
+	boolProperty
+	intProperty
+	enumProperty
+
+	PropsCommand commands.Name = "props"
+
+	hiddenPropertyValue = "********"
+)
+
+var (
+	ErrPropsUsage      = errors.New("usage: `props get <key>`, `props set <key> <value>` or `props diff`")
+	ErrUnknownProperty = errors.New("unknown property")
+
+	knownProperties = map[string]propertySpec{
+		"allow-flight":                      {kind: boolProperty},
+		"allow-nether":                      {kind: boolProperty},
+		"broadcast-console-to-ops":          {kind: boolProperty},
+		"broadcast-rcon-to-ops":             {kind: boolProperty},
+		"difficulty":                        {kind: enumProperty, values: []string{"peaceful", "easy", "normal", "hard"}, live: consoleSetter("difficulty")},
+		"enable-command-block":              {kind: boolProperty},
+		"enable-jmx-monitoring":             {kind: boolProperty},
+		"enable-query":                      {kind: boolProperty},
+		"enable-rcon":                       {kind: boolProperty},
+		"enable-status":                     {kind: boolProperty},
+		"enforce-secure-profile":            {kind: boolProperty},
+		"enforce-whitelist":                 {kind: boolProperty},
+		"entity-broadcast-range-percentage": {kind: intProperty, min: 10, max: 1000},
+		"force-gamemode":                    {kind: boolProperty},
+		"function-permission-level":         {kind: intProperty, min: 1, max: 4},
+		"gamemode":                          {kind: enumProperty, values: []string{"survival", "creative", "adventure", "spectator"}, live: consoleSetter("defaultgamemode")},
+		"generate-structures":               {kind: boolProperty},
+		"generator-settings":                {kind: stringProperty},
+		"hardcore":                          {kind: boolProperty},
+		"hide-online-players":               {kind: boolProperty},
+		"initial-disabled-packs":            {kind: stringProperty},
+		"initial-enabled-packs":             {kind: stringProperty},
+		"level-name":                        {kind: stringProperty},
+		"level-seed":                        {kind: stringProperty},
+		"level-type":                        {kind: stringProperty},
+		"max-chained-neighbor-updates":      {kind: intProperty, min: -1, max: 1 << 24},
+		"max-players":                       {kind: intProperty, min: 0, max: 1<<31 - 1},
+		"max-tick-time":                     {kind: intProperty, min: -1, max: 1<<31 - 1},
+		"max-world-size":                    {kind: intProperty, min: 1, max: 29999984},
+		"motd":                              {kind: stringProperty},
+		"network-compression-threshold":     {kind: intProperty, min: -1, max: 1<<31 - 1},
+		"online-mode":                       {kind: boolProperty},
+		"op-permission-level":               {kind: intProperty, min: 0, max: 4},
+		"player-idle-timeout":               {kind: intProperty, min: 0, max: 1<<31 - 1, live: consoleSetter("setidletimeout")},
+		"prevent-proxy-connections":         {kind: boolProperty},
+		"pvp":                               {kind: boolProperty},
+		"query.port":                        {kind: intProperty, min: 1, max: 65535},
+		"rate-limit":                        {kind: intProperty, min: 0, max: 1<<31 - 1},
+		"rcon.password":                     {kind: stringProperty, secret: true},
+		"rcon.port":                         {kind: intProperty, min: 1, max: 65535},
+		"require-resource-pack":             {kind: boolProperty},
+		"resource-pack":                     {kind: stringProperty},
+		"resource-pack-prompt":              {kind: stringProperty},
+		"resource-pack-sha1":                {kind: stringProperty},
+		"server-ip":                         {kind: stringProperty},
+		"server-port":                       {kind: intProperty, min: 1, max: 65535},
+		"simulation-distance":               {kind: intProperty, min: 3, max: 32},
+		"spawn-animals":                     {kind: boolProperty},
+		"spawn-monsters":                    {kind: boolProperty},
+		"spawn-npcs":                        {kind: boolProperty},
+		"spawn-protection":                  {kind: intProperty, min: 0, max: 1<<31 - 1},
+		"sync-chunk-writes":                 {kind: boolProperty},
+		"text-filtering-config":             {kind: stringProperty},
+		"use-native-transport":              {kind: boolProperty},
+		"view-distance":                     {kind: intProperty, min: 3, max: 32},
+		"white-list":                        {kind: boolProperty, live: whitelistSetter},
+	}
+)
+
+func consoleSetter(command string) func(string) string {
+	return func(value string) string {
+		return command + " " + value
+	}
+}
+
+func whitelistSetter(value string) string {
+	if value == "true" {
+		return "whitelist on"
+	}
+	return "whitelist off"
+}
+
+// ValidateProperty checks the value of a known server property.
+func ValidateProperty(key, value string) error {
+	spec, known := knownProperties[key]
+	if !known {
+		return fmt.Errorf("%w: %q", ErrUnknownProperty, key)
+	}
+	return spec.validate(value)
+}
+
+func (p propertySpec) validate(value string) error {
+	switch p.kind {
+	case boolProperty:
+		if value != "true" && value != "false" {
+			return fmt.Errorf("invalid value %q, expected true or false", value)
+		}
+	case intProperty:
+		n, err := strconv.Atoi(value)
+		if err != nil || n < p.min || n > p.max {
+			return fmt.Errorf("invalid value %q, expected an integer between %d and %d", value, p.min, p.max)
+		}
+	case enumProperty:
+		if !slices.Contains(p.values, value) {
+			return fmt.Errorf("invalid value %q, expected one of: %s", value, strings.Join(p.values, ", "))
+		}
+	}
+	return nil
+}
+
+func displayProperty(key, value string) string {
+	if knownProperties[key].secret && value != "" {
+		return hiddenPropertyValue
+	}
+	return fmt.Sprintf("`%s`", value)
+}
+
+// Load remembers the properties the server is started with.
+func (t *propertiesTracker) Load(path string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	file, err := LoadPropertiesFile(path)
+	if err != nil {
+		log.WithError(err).WithField("path", path).Warn("server.properties")
+		t.loaded = nil
+		return
+	}
+	t.loaded = file.Values()
+}
+
+// Clear forgets the properties once the server has stopped.
+func (t *propertiesTracker) Clear() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.loaded = nil
+}
+
+// Applied records that a value has been applied to the running server.
+func (t *propertiesTracker) Applied(key, value string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.loaded != nil {
+		t.loaded[key] = value
+	}
+}
+
+// Diff returns the keys whose values differ from the ones the server has been started with, sorted.
+// It returns false if the server is not running.
+func (t *propertiesTracker) Diff(current map[string]string) ([]string, map[string]string, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.loaded == nil {
+		return nil, nil, false
+	}
+	var keys []string
+	for key, value := range current {
+		if loaded, found := t.loaded[key]; !found || loaded != value {
+			keys = append(keys, key)
+		}
+	}
+	for key := range t.loaded {
+		if _, found := current[key]; !found {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	loaded := make(map[string]string, len(keys))
+	for _, key := range keys {
+		loaded[key] = t.loaded[key]
+	}
+	return keys, loaded, true
+}
+
+// pendingProperties returns the changed properties that will only be applied on the next restart.
+func (s *Server) pendingProperties() []string {
+	file, err := LoadPropertiesFile(s.Server.AbsServerProperties())
+	if err != nil {
+		return nil
+	}
+	keys, _, _ := s.properties.Diff(file.Values())
+	return keys
+}
+
+func (s *Server) handlePropsCommand(cmd *commands.Command) (string, error) {
+	switch {
+	case len(cmd.Arguments) == 2 && cmd.Arguments[0] == "get":
+		return s.getProperty(cmd.Arguments[1])
+	case len(cmd.Arguments) >= 3 && cmd.Arguments[0] == "set":
+		if !cmd.HasPermission(discord.AdminCategory) {
+			return "", commands.ErrPermissionDenied
+		}
+		return s.setProperty(cmd.Arguments[1], strings.Join(cmd.Arguments[2:], " "))
+	case len(cmd.Arguments) == 1 && cmd.Arguments[0] == "diff":
+		return s.diffProperties()
+	default:
+		return "", ErrPropsUsage
+	}
+}
+
+func (s *Server) getProperty(key string) (string, error) {
+	file, err := LoadPropertiesFile(s.Server.AbsServerProperties())
+	if err != nil {
+		return "", err
+	}
+	value, found := file.Get(key)
+	if !found {
+		return fmt.Sprintf("`%s` is not set", key), nil
+	}
+	return fmt.Sprintf("`%s` = %s", key, displayProperty(key, value)), nil
+}
+
+func (s *Server) setProperty(key, value string) (string, error) {
+	path := s.Server.AbsServerProperties()
+	file, err := LoadPropertiesFile(path)
+	if err != nil {
+		return "", err
+	}
+	// Properties added by mods or newer server versions can be changed, but not created.
+	if _, known := knownProperties[key]; known {
+		if err := ValidateProperty(key, value); err != nil {
+			return "", err
+		}
+	} else if _, found := file.Get(key); !found {
+		return "", fmt.Errorf("%w: %q", ErrUnknownProperty, key)
+	}
+
+	file.Set(key, value)
+	if err := file.Save(); err != nil {
+		log.WithError(err).WithField("path", path).Error("server.properties")
+		return "", err
+	}
+	log.WithField("key", key).Info("server.properties.set")
+
+	message := fmt.Sprintf("`%s` set to %s", key, displayProperty(key, value))
+	if live := knownProperties[key].live; live != nil && s.Status().IsRunning() {
+		if _, err := s.ExecuteConsoleCommand(live(value)); err != nil {
+			return message + ", but it could not be applied: " + err.Error(), nil
+		}
+		s.properties.Applied(key, value)
+		return message + " and applied", nil
+	}
+	if s.Status() != Stopped {
+		return message + ", restart the server to apply it", nil
+	}
+	return message, nil
+}
+
+func (s *Server) diffProperties() (string, error) {
+	file, err := LoadPropertiesFile(s.Server.AbsServerProperties())
+	if err != nil {
+		return "", err
+	}
+	current := file.Values()
+	keys, loaded, running := s.properties.Diff(current)
+	if !running {
+		return "The server is not running (or not ready yet), the changes will be applied when it starts", nil
+	}
+	if len(keys) == 0 {
+		return "No changes since the server has been started", nil
+	}
+	lines := make([]string, 0, len(keys)+1)
+	lines = append(lines, "Changes since the server has been started (restart required):")
+	for _, key := range keys {
+		before, after := "*unset*", "*unset*"
+		if value, found := loaded[key]; found {
+			before = displayProperty(key, value)
+		}
+		if value, found := current[key]; found {
+			after = displayProperty(key, value)
+		}
+		lines = append(lines, fmt.Sprintf("- `%s`: %s → %s", key, before, after))
+	}
+	return strings.Join(lines, "\n"), nil
+}
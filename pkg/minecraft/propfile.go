@@ -0,0 +1,256 @@
+package minecraft
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+type (
+	// PropertiesFile is a Java properties file, like server.properties, that keeps its comments and the order
+	// of its lines when it is modified.
+	PropertiesFile struct {
+		Path    string
+		entries []*propertyEntry
+	}
+
+	// propertyEntry is a logical line of a properties file. Comments and blank lines have no key.
+	propertyEntry struct {
+		raw   string
+		key   string
+		value string
+	}
+)
+
+// LoadPropertiesFile reads a properties file. A missing file is read as an empty one.
+func LoadPropertiesFile(path string) (*PropertiesFile, error) {
+	f := &PropertiesFile{Path: path}
+	content, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return f, nil
+	} else if err != nil {
+		return nil, err
+	}
+	f.parse(content)
+	return f, nil
+}
+
+func (f *PropertiesFile) parse(content []byte) {
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	var logical, raw []string
+	for scanner.Scan() {
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+		raw = append(raw, line)
+		if len(logical) > 0 {
+			line = strings.TrimLeft(line, " \t\f")
+		}
+		logical = append(logical, line)
+		if len(logical) == 1 && isPropertyComment(line) || !hasContinuation(line) {
+			f.entries = append(f.entries, newPropertyEntry(strings.Join(raw, "\n"), logical))
+			logical, raw = nil, nil
+		}
+	}
+	if len(raw) > 0 {
+		f.entries = append(f.entries, newPropertyEntry(strings.Join(raw, "\n"), logical))
+	}
+}
+
+// Get returns the value of a property, and whether it is defined.
+func (f *PropertiesFile) Get(key string) (string, bool) {
+	for i := len(f.entries) - 1; i >= 0; i-- {
+		if entry := f.entries[i]; entry.key == key {
+			return entry.value, true
+		}
+	}
+	return "", false
+}
+
+// Set changes the value of a property in place, or appends it to the file if it is not defined yet.
+func (f *PropertiesFile) Set(key, value string) {
+	entry := &propertyEntry{
+		raw:   escapeProperty(key, true) + "=" + escapeProperty(value, false),
+		key:   key,
+		value: value,
+	}
+	for i := len(f.entries) - 1; i >= 0; i-- {
+		if f.entries[i].key == key {
+			f.entries[i] = entry
+			return
+		}
+	}
+	f.entries = append(f.entries, entry)
+}
+
+// Values returns all the properties of the file.
+func (f *PropertiesFile) Values() map[string]string {
+	values := make(map[string]string, len(f.entries))
+	for _, entry := range f.entries {
+		if entry.key != "" {
+			values[entry.key] = entry.value
+		}
+	}
+	return values
+}
+
+// Bytes returns the content of the file.
+func (f *PropertiesFile) Bytes() []byte {
+	var buf bytes.Buffer
+	for _, entry := range f.entries {
+		buf.WriteString(entry.raw)
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes()
+}
+
+// Save writes the file, replacing it atomically.
+func (f *PropertiesFile) Save() error {
+	mode := os.FileMode(0o644)
+	if info, err := os.Stat(f.Path); err == nil {
+		mode = info.Mode().Perm()
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(f.Path), "."+filepath.Base(f.Path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err = tmp.Write(f.Bytes()); err == nil {
+		err = tmp.Chmod(mode)
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), f.Path)
+}
+
+func isPropertyComment(line string) bool {
+	line = strings.TrimLeft(line, " \t\f")
+	return line == "" || line[0] == '#' || line[0] == '!'
+}
+
+// hasContinuation returns whether the line ends with an odd number of backslashes.
+func hasContinuation(line string) bool {
+	count := len(line) - len(strings.TrimRight(line, "\\"))
+	return count%2 == 1
+}
+
+func newPropertyEntry(raw string, logical []string) *propertyEntry {
+	entry := &propertyEntry{raw: raw}
+	if isPropertyComment(logical[0]) {
+		return entry
+	}
+	for i, line := range logical {
+		if i < len(logical)-1 || hasContinuation(line) {
+			logical[i] = line[:len(line)-1]
+		}
+	}
+	line := strings.TrimLeft(strings.Join(logical, ""), " \t\f")
+
+	end := 0
+	for end < len(line) && !strings.ContainsRune("=: \t\f", rune(line[end])) {
+		if line[end] == '\\' {
+			end++
+		}
+		end++
+	}
+	if end > len(line) {
+		end = len(line)
+	}
+	value := strings.TrimLeft(line[end:], " \t\f")
+	if value != "" && (value[0] == '=' || value[0] == ':') {
+		value = strings.TrimLeft(value[1:], " \t\f")
+	}
+
+	entry.key = unescapeProperty(line[:end])
+	entry.value = unescapeProperty(value)
+	return entry
+}
+
+func unescapeProperty(s string) string {
+	if !strings.Contains(s, "\\") {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c != '\\' || i == len(s)-1 {
+			b.WriteByte(c)
+			continue
+		}
+		i++
+		switch s[i] {
+		case 't':
+			b.WriteByte('\t')
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 'f':
+			b.WriteByte('\f')
+		case 'u':
+			if i+4 < len(s) {
+				if code, err := strconv.ParseUint(s[i+1:i+5], 16, 16); err == nil {
+					i += 4
+					b.WriteString(decodeUTF16Escape(rune(code), s, &i))
+					continue
+				}
+			}
+			b.WriteByte('u')
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
+
+// decodeUTF16Escape combines a high surrogate with the following \uXXXX escape, if any.
+func decodeUTF16Escape(code rune, s string, i *int) string {
+	if code < 0xD800 || code > 0xDBFF || *i+6 >= len(s) || s[*i+1:*i+3] != "\\u" {
+		return string(code)
+	}
+	low, err := strconv.ParseUint(s[*i+3:*i+7], 16, 16)
+	if err != nil || low < 0xDC00 || low > 0xDFFF {
+		return string(code)
+	}
+	*i += 6
+	return string((code-0xD800)<<10 + (rune(low) - 0xDC00) + 0x10000)
+}
+
+// escapeProperty escapes a key or a value the way Java does, using \uXXXX escapes for non-ASCII characters
+// so that the file can be read whatever the charset the server expects.
+func escapeProperty(s string, isKey bool) string {
+	var b strings.Builder
+	for i, r := range s {
+		switch {
+		case r == ' ' && (isKey || i == 0):
+			b.WriteString("\\ ")
+		case r == '\t':
+			b.WriteString("\\t")
+		case r == '\n':
+			b.WriteString("\\n")
+		case r == '\r':
+			b.WriteString("\\r")
+		case r == '\f':
+			b.WriteString("\\f")
+		case strings.ContainsRune("\\=:#!", r):
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r < 0x20 || r > 0x7e:
+			if r > 0xFFFF {
+				r -= 0x10000
+				fmt.Fprintf(&b, "\\u%04X\\u%04X", 0xD800+(r>>10), 0xDC00+(r&0x3FF))
+			} else {
+				fmt.Fprintf(&b, "\\u%04X", r)
+			}
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,111 @@
+package minecraft_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Adirelle/mcvisor/pkg/minecraft"
+)
+
+const sampleProperties = `#Minecraft server properties
+#Sun Oct 16 12:00:00 CEST 2022
+motd=A Minecraft Server
+difficulty = easy
+
+! legacy comment
+level-name : world
+resource-pack=https\://example.com/pack.zip
+long-value=first \
+    second
+view-distance=10
+`
+
+func TestPropertiesFile(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "server.properties")
+	if err := os.WriteFile(path, []byte(sampleProperties), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	file, err := minecraft.LoadPropertiesFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]string{
+		"motd":          "A Minecraft Server",
+		"difficulty":    "easy",
+		"level-name":    "world",
+		"resource-pack": "https://example.com/pack.zip",
+		"long-value":    "first second",
+		"view-distance": "10",
+	}
+	for key, value := range expected {
+		if actual, found := file.Get(key); !found || actual != value {
+			t.Errorf("%s: expected %q, got %q", key, value, actual)
+		}
+	}
+	if string(file.Bytes()) != sampleProperties {
+		t.Errorf("unmodified file should be preserved, got:\n%s", file.Bytes())
+	}
+
+	file.Set("difficulty", "hard")
+	file.Set("motd", " Héllo: world")
+	file.Set("pvp", "false")
+	if err := file.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedContent := `#Minecraft server properties
+#Sun Oct 16 12:00:00 CEST 2022
+motd=\ H\u00E9llo\: world
+difficulty=hard
+
+! legacy comment
+level-name : world
+resource-pack=https\://example.com/pack.zip
+long-value=first \
+    second
+view-distance=10
+pvp=false
+`
+	if string(content) != expectedContent {
+		t.Errorf("unexpected content:\n%s", content)
+	}
+
+	reloaded, err := minecraft.LoadPropertiesFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if motd, _ := reloaded.Get("motd"); motd != " Héllo: world" {
+		t.Errorf("unexpected motd after reloading: %q", motd)
+	}
+}
+
+func TestValidateProperty(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		key, value string
+		valid      bool
+	}{
+		{"pvp", "true", true},
+		{"pvp", "yes", false},
+		{"view-distance", "12", true},
+		{"view-distance", "64", false},
+		{"difficulty", "hard", true},
+		{"difficulty", "insane", false},
+		{"motd", "anything goes", true},
+		{"unknown-key", "1", false},
+	}
+	for _, c := range cases {
+		if err := minecraft.ValidateProperty(c.key, c.value); (err == nil) != c.valid {
+			t.Errorf("%s=%s: expected valid=%v, got %v", c.key, c.value, c.valid, err)
+		}
+	}
+}
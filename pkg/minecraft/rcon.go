@@ -0,0 +1,125 @@
+package minecraft
+
+import (
+	"errors"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/Adirelle/mcvisor/pkg/rcon"
+	"github.com/apex/log"
+	properties "github.com/dmotylev/goproperties"
+)
+
+type (
+	// rconTransport sends console commands through RCON, when it is enabled in server.properties.
+	rconTransport struct {
+		*ServerConfig
+		mu     sync.Mutex
+		client *rcon.Client
+	}
+
+	rconSettings struct {
+		Address  string
+		Password string
+	}
+)
+
+const (
+	DefaultRconPort = 25575
+)
+
+var (
+	formattingCodeRegexp = regexp.MustCompile(`§.`)
+)
+
+// loadRconSettings reads the RCON settings from server.properties. It returns nil if RCON is disabled.
+func loadRconSettings(config *ServerConfig) (*rconSettings, error) {
+	props, err := properties.Load(config.AbsServerProperties())
+	if err != nil {
+		return nil, err
+	}
+
+	password := props.String("rcon.password", "")
+	if !props.Bool("enable-rcon", false) || password == "" {
+		return nil, nil
+	}
+
+	host := config.Network.Host
+	if host == "" {
+		host = props.String("server-ip", "")
+	}
+	if host == "" {
+		host = "localhost"
+	}
+	port := props.Int("rcon.port", DefaultRconPort)
+
+	return &rconSettings{net.JoinHostPort(host, strconv.FormatInt(port, 10)), password}, nil
+}
+
+// Execute sends the command through RCON and returns its response lines.
+// It returns false if RCON is not available, e.g. because the connection failed, so that the command is sent through
+// the standard input instead. Once the command has been sent, errors are returned as is, since the command may have
+// run already.
+func (t *rconTransport) Execute(command string) (lines []string, available bool, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.client == nil && !t.connect() {
+		return nil, false, nil
+	}
+
+	response, err := t.client.Execute(command)
+	if errors.Is(err, rcon.ErrCommandTooLong) {
+		return nil, true, err
+	} else if err != nil {
+		log.WithError(err).WithField("command", command).Warn("server.rcon")
+		_ = t.client.Close()
+		t.client = nil
+		if errors.Is(err, rcon.ErrNotSent) {
+			return nil, false, nil
+		}
+		return nil, true, err
+	}
+	log.WithField("command", command).Info("server.rcon")
+
+	response = strings.TrimRight(formattingCodeRegexp.ReplaceAllString(response, ""), "\n")
+	if response == "" {
+		return nil, true, nil
+	}
+	return strings.Split(response, "\n"), true, nil
+}
+
+func (t *rconTransport) connect() bool {
+	settings, err := loadRconSettings(t.ServerConfig)
+	if err != nil {
+		log.WithError(err).WithField("path", t.AbsServerProperties()).Warn("server.rcon.config")
+		return false
+	}
+	if settings == nil {
+		return false
+	}
+
+	t.client, err = rcon.Dial(settings.Address, settings.Password, ConsoleCommandTimeout)
+	if errors.Is(err, rcon.ErrAuthFailed) {
+		log.WithError(err).WithField("address", settings.Address).Warn("server.rcon.connect")
+		return false
+	} else if err != nil {
+		log.WithError(err).WithField("address", settings.Address).Debug("server.rcon.connect")
+		return false
+	}
+	log.WithField("address", settings.Address).Info("server.rcon.connected")
+	return true
+}
+
+// Close drops the connection, if any.
+func (t *rconTransport) Close() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.client != nil {
+		_ = t.client.Close()
+		t.client = nil
+	}
+}
@@ -0,0 +1,116 @@
+package minecraft_test
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/Adirelle/mcvisor/pkg/minecraft"
+	"github.com/Adirelle/mcvisor/pkg/rcon"
+)
+
+// serveRcon accepts RCON connections with the given password. It answers "echo <text>" commands with text and drops
+// the connection on any other command.
+func serveRcon(t *testing.T, password string) *net.TCPAddr {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				for {
+					packet, err := rcon.ReadPacket(conn)
+					if err != nil {
+						return
+					}
+					reply := &rcon.Packet{ID: packet.ID, Type: rcon.ResponseType}
+					switch {
+					case packet.Type == rcon.AuthType && packet.Body != password:
+						reply.ID, reply.Type = rcon.AuthFailedID, rcon.AuthResponseType
+					case packet.Type == rcon.AuthType:
+						reply.Type = rcon.AuthResponseType
+					case strings.HasPrefix(packet.Body, "echo "):
+						reply.Body = strings.TrimPrefix(packet.Body, "echo ")
+					default:
+						return
+					}
+					_, _ = reply.WriteTo(conn)
+				}
+			}()
+		}
+	}()
+	return listener.Addr().(*net.TCPAddr)
+}
+
+func newRconTransport(t *testing.T, address *net.TCPAddr, password string) *minecraft.RconTransport {
+	t.Helper()
+	config := minecraft.NewConfig(t.TempDir())
+	config.Server.Network.Host = "127.0.0.1"
+	properties := fmt.Sprintf("enable-rcon=true\nrcon.port=%d\nrcon.password=%s\n", address.Port, password)
+	if err := os.WriteFile(config.Server.AbsServerProperties(), []byte(properties), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	transport := minecraft.NewRconTransport(config.Server)
+	t.Cleanup(transport.Close)
+	return transport
+}
+
+func TestRconTransport(t *testing.T) {
+	t.Parallel()
+
+	transport := newRconTransport(t, serveRcon(t, "secret"), "secret")
+
+	lines, available, err := transport.Execute("echo hello")
+	if !available || err != nil || fmt.Sprint(lines) != "[hello]" {
+		t.Errorf("unexpected result: %v, %v, %v", lines, available, err)
+	}
+
+	// The connection is dropped after the command has been read: it must not be sent again.
+	if lines, available, err = transport.Execute("list"); !available || err == nil {
+		t.Errorf("expected an error, got %v, %v, %v", lines, available, err)
+	}
+
+	// The next command reconnects.
+	if lines, available, err = transport.Execute("echo again"); !available || err != nil || fmt.Sprint(lines) != "[again]" {
+		t.Errorf("unexpected result: %v, %v, %v", lines, available, err)
+	}
+
+	if _, available, err = transport.Execute("echo " + strings.Repeat("x", rcon.MaxCommandLength)); !available || !errors.Is(err, rcon.ErrCommandTooLong) {
+		t.Errorf("expected ErrCommandTooLong, got %v, %v", available, err)
+	}
+}
+
+func TestRconTransportFallback(t *testing.T) {
+	t.Parallel()
+
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = closed.Close()
+
+	tests := []struct {
+		name      string
+		transport *minecraft.RconTransport
+	}{
+		{"wrong password", newRconTransport(t, serveRcon(t, "secret"), "wrong")},
+		{"connection refused", newRconTransport(t, closed.Addr().(*net.TCPAddr), "secret")},
+	}
+	for _, test := range tests {
+		if lines, available, err := test.transport.Execute("echo hello"); available || err != nil {
+			t.Errorf("%s: expected a fallback, got %v, %v, %v", test.name, lines, available, err)
+		}
+	}
+}
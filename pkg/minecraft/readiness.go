@@ -0,0 +1,108 @@
+package minecraft
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/Adirelle/mcvisor/pkg/discord"
+	"github.com/Adirelle/mcvisor/pkg/utils"
+	"github.com/apex/log"
+)
+
+type (
+	ReadinessConfig struct {
+		Conditions     []ReadinessCondition `json:"conditions" validate:"min=1,dive,oneof=log port ping"`
+		Mode           ReadinessMode        `json:"mode" validate:"oneof=any all"`
+		StartupTimeout time.Duration        `json:"startup_timeout"`
+	}
+
+	ReadinessCondition string
+
+	ReadinessMode string
+
+	StartupTimedOut struct {
+		Timeout time.Duration
+	}
+)
+
+const (
+	LogCondition  ReadinessCondition = "log"
+	PortCondition ReadinessCondition = "port"
+	PingCondition ReadinessCondition = "ping"
+
+	AnyCondition  ReadinessMode = "any"
+	AllConditions ReadinessMode = "all"
+
+	DefaultStartupTimeout = 5 * time.Minute
+)
+
+var (
+	// Interface check
+	_ discord.Notification = (*StartupTimedOut)(nil)
+
+	PortProbeInterval = time.Second
+
+	ErrStartupTimeout = errors.New("server did not become ready in time")
+)
+
+func NewReadinessConfig() *ReadinessConfig {
+	return &ReadinessConfig{
+		Conditions:     []ReadinessCondition{LogCondition, PingCondition},
+		Mode:           AnyCondition,
+		StartupTimeout: DefaultStartupTimeout,
+	}
+}
+
+func (c *ReadinessConfig) Has(condition ReadinessCondition) bool {
+	for _, cond := range c.Conditions {
+		if cond == condition {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *ReadinessConfig) IsSatisfied(met map[ReadinessCondition]bool) bool {
+	for _, cond := range c.Conditions {
+		if met[cond] && c.Mode == AnyCondition {
+			return true
+		} else if !met[cond] && c.Mode == AllConditions {
+			return false
+		}
+	}
+	return c.Mode == AllConditions
+}
+
+// probePort tries to connect to the server port until it succeeds, the process exits or ctx is cancelled.
+func probePort(ctx context.Context, done <-chan struct{}, network *NetworkConfig, result chan<- ReadinessCondition) {
+	address := network.Address()
+	ticker := time.NewTicker(PortProbeInterval)
+	defer ticker.Stop()
+
+	for {
+		if conn, err := net.DialTimeout("tcp", address, network.ConnectionTimeout); err == nil {
+			_ = conn.Close()
+			log.WithField("address", address).Debug("server.readiness.port")
+			_ = utils.SendWithContext(result, PortCondition, ctx)
+			return
+		}
+		select {
+		case <-ticker.C:
+		case <-done:
+			return
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (e *StartupTimedOut) Fields() log.Fields {
+	return log.Fields{"timeout": e.Timeout}
+}
+
+func (e *StartupTimedOut) DiscordNotification() string {
+	return fmt.Sprintf("**Server did not become ready within %s, stopping it**", e.Timeout)
+}
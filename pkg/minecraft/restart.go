@@ -0,0 +1,164 @@
+package minecraft
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/Adirelle/mcvisor/pkg/discord"
+	"github.com/apex/log"
+)
+
+type (
+	RestartConfig struct {
+		Policy      RestartPolicy `json:"policy" validate:"oneof=always on-failure never"`
+		MinBackoff  time.Duration `json:"min_backoff"`
+		MaxBackoff  time.Duration `json:"max_backoff"`
+		MaxRestarts uint          `json:"max_restarts"`
+		Window      time.Duration `json:"window"`
+	}
+
+	RestartPolicy string
+
+	restarter struct {
+		*RestartConfig
+		failures uint
+		history  []time.Time
+	}
+
+	UnexpectedExit struct {
+		ExitCode     int
+		Reason       error
+		Restarting   bool
+		RestartDelay time.Duration
+	}
+
+	CrashLoop struct {
+		ExitCode int
+		Reason   error
+		Restarts int
+		Window   time.Duration
+	}
+)
+
+const (
+	AlwaysRestart    RestartPolicy = "always"
+	RestartOnFailure RestartPolicy = "on-failure"
+	NeverRestart     RestartPolicy = "never"
+)
+
+var (
+	// Interface checks
+	_ discord.Notification = (*UnexpectedExit)(nil)
+	_ discord.Notification = (*CrashLoop)(nil)
+)
+
+func NewRestartConfig() *RestartConfig {
+	return &RestartConfig{
+		Policy:      RestartOnFailure,
+		MinBackoff:  5 * time.Second,
+		MaxBackoff:  5 * time.Minute,
+		MaxRestarts: 5,
+		Window:      10 * time.Minute,
+	}
+}
+
+func (c *RestartConfig) ShouldRestart(exitErr error) bool {
+	switch c.Policy {
+	case AlwaysRestart:
+		return true
+	case RestartOnFailure:
+		return exitErr != nil
+	default:
+		return false
+	}
+}
+
+// NextDelay records a restart at the given time and returns the delay to wait before it.
+// It returns false if too many restarts happened in the configured window.
+func (r *restarter) NextDelay(now time.Time) (time.Duration, bool) {
+	if r.MaxRestarts > 0 && r.Window > 0 {
+		start := 0
+		for start < len(r.history) && now.Sub(r.history[start]) > r.Window {
+			start++
+		}
+		r.history = r.history[start:]
+		if uint(len(r.history)) >= r.MaxRestarts {
+			return 0, false
+		}
+		r.history = append(r.history, now)
+	}
+
+	delay := r.MinBackoff
+	for i := uint(0); i < r.failures && delay < r.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if r.MaxBackoff > 0 && delay > r.MaxBackoff {
+		delay = r.MaxBackoff
+	}
+	r.failures++
+
+	return delay, true
+}
+
+// Reset clears the backoff once the server has successfully started.
+func (r *restarter) Reset() {
+	r.failures = 0
+}
+
+// Clear forgets about everything, e.g. after an explicit start.
+func (r *restarter) Clear() {
+	r.failures = 0
+	r.history = nil
+}
+
+func exitCode(err error) int {
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+		return 0
+	case errors.As(err, &exitErr):
+		return exitErr.ExitCode()
+	default:
+		return -1
+	}
+}
+
+func (e *UnexpectedExit) Fields() log.Fields {
+	return log.Fields{
+		"exitCode":     e.ExitCode,
+		"error":        e.Reason,
+		"restarting":   e.Restarting,
+		"restartDelay": e.RestartDelay,
+	}
+}
+
+func (e *UnexpectedExit) DiscordNotification() string {
+	builder := &strings.Builder{}
+	_, _ = fmt.Fprintf(builder, "**Server exited unexpectedly** (exit code %d)", e.ExitCode)
+	if e.Restarting {
+		_, _ = fmt.Fprintf(builder, ", restarting in %s", e.RestartDelay)
+	}
+	return builder.String()
+}
+
+func (e *CrashLoop) Fields() log.Fields {
+	return log.Fields{
+		"exitCode": e.ExitCode,
+		"error":    e.Reason,
+		"restarts": e.Restarts,
+		"window":   e.Window,
+	}
+}
+
+func (e *CrashLoop) DiscordNotification() string {
+	builder := &strings.Builder{}
+	_, _ = fmt.Fprintf(builder, "**Server is crash-looping**: %d restarts within %s, last exit code %d", e.Restarts, e.Window, e.ExitCode)
+	if e.Reason != nil {
+		_, _ = fmt.Fprintf(builder, " (%s)", e.Reason)
+	}
+	_, _ = builder.WriteString(", giving up")
+	return builder.String()
+}
@@ -0,0 +1,100 @@
+package minecraft_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Adirelle/mcvisor/pkg/minecraft"
+)
+
+func TestRestartConfigShouldRestart(t *testing.T) {
+	t.Parallel()
+
+	failure := errors.New("exit status 1")
+	tests := []struct {
+		policy   minecraft.RestartPolicy
+		exitErr  error
+		expected bool
+	}{
+		{minecraft.AlwaysRestart, nil, true},
+		{minecraft.AlwaysRestart, failure, true},
+		{minecraft.RestartOnFailure, nil, false},
+		{minecraft.RestartOnFailure, failure, true},
+		{minecraft.NeverRestart, nil, false},
+		{minecraft.NeverRestart, failure, false},
+	}
+	for _, test := range tests {
+		config := &minecraft.RestartConfig{Policy: test.policy}
+		if actual := config.ShouldRestart(test.exitErr); actual != test.expected {
+			t.Errorf("%s, %v: expected %v, got %v", test.policy, test.exitErr, test.expected, actual)
+		}
+	}
+}
+
+func TestRestarterNextDelay(t *testing.T) {
+	t.Parallel()
+
+	config := minecraft.NewRestartConfig()
+	config.MaxRestarts = 0
+	restarter := minecraft.NewRestarter(config)
+	now := time.Now()
+
+	expected := []time.Duration{
+		5 * time.Second,
+		10 * time.Second,
+		20 * time.Second,
+		40 * time.Second,
+		80 * time.Second,
+		160 * time.Second,
+		5 * time.Minute,
+		5 * time.Minute,
+	}
+	for i, delay := range expected {
+		actual, ok := restarter.NextDelay(now)
+		if !ok || actual != delay {
+			t.Errorf("restart #%d: expected %s, got %s, %v", i+1, delay, actual, ok)
+		}
+	}
+
+	restarter.Reset()
+	if actual, _ := restarter.NextDelay(now); actual != config.MinBackoff {
+		t.Errorf("expected the backoff to be reset, got %s", actual)
+	}
+}
+
+func TestRestarterCrashLoop(t *testing.T) {
+	t.Parallel()
+
+	config := minecraft.NewRestartConfig()
+	config.MaxRestarts = 3
+	config.Window = time.Minute
+	restarter := minecraft.NewRestarter(config)
+	start := time.Now()
+
+	tests := []struct {
+		offset   time.Duration
+		expected bool
+	}{
+		{0, true},
+		{time.Second, true},
+		{2 * time.Second, true},
+		{3 * time.Second, false},
+		{30 * time.Second, false},
+		// The oldest restarts leave the window.
+		{62 * time.Second, true},
+		{63 * time.Second, true},
+		{64 * time.Second, true},
+		{65 * time.Second, false},
+	}
+	for _, test := range tests {
+		if _, ok := restarter.NextDelay(start.Add(test.offset)); ok != test.expected {
+			t.Errorf("restart after %s: expected %v, got %v", test.offset, test.expected, ok)
+		}
+	}
+
+	restarter.Clear()
+	if _, ok := restarter.NextDelay(start.Add(66 * time.Second)); !ok {
+		t.Error("expected the history to be cleared")
+	}
+}
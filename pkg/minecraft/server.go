@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/Adirelle/mcvisor/pkg/commands"
@@ -22,13 +23,33 @@ type (
 		*Config
 
 		dispatcher *events.Dispatcher
+		parser     *LogParser
+		collector  *outputCollector
+		consoleMu  sync.Mutex
+		rcon       *rconTransport
 		status     Status
+		statusMu   sync.Mutex
 		target     Target
 		process    *process
 		targets    chan Target
 		pings      chan PingerEvent
 		console    chan *consoleCommand
 		outputs    chan ServerOutput
+		dones      chan ServerDone
+		readiness  chan ReadinessCondition
+		conditions map[ReadinessCondition]bool
+		watchdog   *watchdog
+		restarter  *restarter
+		countdown  *countdown
+		countdowns chan *countdownRequest
+		properties propertiesTracker
+		infoMu     sync.Mutex
+		versions   string
+		pid        int
+	}
+
+	Console interface {
+		ExecuteConsoleCommand(command string) (string, error)
 	}
 
 	Status string
@@ -39,11 +60,6 @@ type (
 		target Target
 		server *Server
 	}
-
-	consoleCommand struct {
-		command string
-		reply   chan<- string
-	}
 )
 
 const (
@@ -58,6 +74,8 @@ const (
 	StopTarget     Target = "stop"
 	RestartTarget  Target = "restart"
 	ShutdownTarget Target = "shutdown"
+	// DetachTarget stops supervising the server, leaving a detached server running.
+	DetachTarget Target = "detach"
 
 	StatusCommand   commands.Name = "status"
 	StartCommand    commands.Name = "start"
@@ -71,6 +89,7 @@ var (
 	// Interface check
 	_ suture.Service         = (*Server)(nil)
 	_ Statuser               = (*Server)(nil)
+	_ Console                = (*Server)(nil)
 	_ commands.Handler       = (*targetSetter)(nil)
 	_ discord.Notification   = Started
 	_ discord.StatusProvider = Started
@@ -81,7 +100,7 @@ var (
 	ErrStoppedServer = errors.New("server is stopped")
 )
 
-func NewServer(conf *Config, dispatcher *events.Dispatcher) *Server {
+func NewServer(conf *Config, dispatcher *events.Dispatcher, registry *commands.Registry) *Server {
 	s := &Server{
 		Config:     conf,
 		target:     StopTarget,
@@ -90,47 +109,103 @@ func NewServer(conf *Config, dispatcher *events.Dispatcher) *Server {
 		pings:      events.MakeHandler[PingerEvent](),
 		console:    events.MakeHandler[*consoleCommand](),
 		outputs:    events.MakeHandler[ServerOutput](),
+		dones:      events.MakeHandler[ServerDone](),
+		readiness:  make(chan ReadinessCondition),
+		rcon:       &rconTransport{ServerConfig: conf.Server},
+		watchdog:   &watchdog{WatchdogConfig: conf.Server.Network.Watchdog},
+		restarter:  &restarter{RestartConfig: conf.Server.Restart},
+		countdowns: make(chan *countdownRequest),
 		dispatcher: dispatcher,
 	}
-	commands.Register(StartCommand, "start the server", discord.ControlCategory, &targetSetter{StartTarget, s})
-	commands.Register(StopCommand, "stop the server", discord.ControlCategory, &targetSetter{StopTarget, s})
-	commands.Register(RestartCommand, "restart the server", discord.ControlCategory, &targetSetter{RestartTarget, s})
-	commands.Register(ShutdownCommand, "stop the server *and* mcvisor", discord.AdminCategory, &targetSetter{ShutdownTarget, s})
-	commands.Register(StatusCommand, "show serve status", discord.QueryCategory, commands.HandlerFunc(s.handleStatusCommand))
-	commands.Register(ConsoleCommand, "send a console command to the server", discord.ControlCategory, commands.HandlerFunc(s.handleConsoleCommand))
+	registry.Register(StartCommand, "start the server", discord.ControlCategory, &targetSetter{StartTarget, s})
+	registry.Register(StopCommand, "stop the server, optionally after a delay (e.g. `stop 10m`)", discord.ControlCategory, &targetSetter{StopTarget, s})
+	registry.Register(RestartCommand, "restart the server, optionally after a delay (e.g. `restart 5m`)", discord.ControlCategory, &targetSetter{RestartTarget, s})
+	registry.Register(ShutdownCommand, "stop the server *and* mcvisor (once all servers are shut down), optionally after a delay", discord.AdminCategory, &targetSetter{ShutdownTarget, s})
+	registry.Register(CancelCommand, "cancel a pending stop or restart", discord.ControlCategory, commands.HandlerFunc(s.handleCancelCommand))
+	registry.Register(StatusCommand, "show serve status", discord.QueryCategory, commands.HandlerFunc(s.handleStatusCommand))
+	registry.Register(ConsoleCommand, "send a console command to the server", discord.ControlCategory, commands.HandlerFunc(s.handleConsoleCommand))
+	registry.Register(EULACommand, "show whether the Minecraft EULA has been accepted, or accept it (`eula accept`) and start the server", discord.AdminCategory, commands.HandlerFunc(s.handleEULACommand))
+	registry.Register(PropsCommand, "show (`props get <key>`), change (`props set <key> <value>`) or list the pending changes (`props diff`) of server.properties", discord.QueryCategory, commands.HandlerFunc(s.handlePropsCommand))
 	return s
 }
 
 func (s *Server) Serve(ctx context.Context) (err error) {
+	if s.parser, err = NewLogParser(s.Server.LogPatterns); err != nil {
+		log.WithError(err).Error("server.config")
+		return err
+	}
+	if s.collector, err = newOutputCollector(s.Server.Console, s.parser); err != nil {
+		log.WithError(err).Error("server.config")
+		return err
+	}
+
 	defer s.dispatcher.Subscribe(s.targets).Cancel()
 	defer s.dispatcher.Subscribe(s.pings).Cancel()
 	defer s.dispatcher.Subscribe(s.outputs).Cancel()
+	defer s.dispatcher.Subscribe(s.dones).Cancel()
 
 	var processDone chan struct{}
+	var startupTimeout <-chan time.Time
+	var restartDelay <-chan time.Time
 
 	for {
 		switch {
+		case s.target == DetachTarget:
+			if s.process != nil {
+				log.WithField("pid", s.process.Process.Pid).Warn("server.detach")
+			}
+			return suture.ErrTerminateSupervisorTree
 		case s.target == RestartTarget && s.status == Stopped:
 			s.targets <- StartTarget
-		case s.target.MustStart() && !s.status.IsOneOf(Starting, Started, Ready, Unreachable):
+		case s.target.MustStart() && restartDelay == nil && !s.status.IsOneOf(Starting, Started, Ready, Unreachable):
 			s.setStatus(Starting)
+			if s.process == nil && s.Server.Detached.Enabled {
+				if s.process, err = adoptProcess(s.Config, s.dispatcher, s.parser); err != nil {
+					return err
+				}
+			}
+			if s.process == nil && !s.checkEULA() {
+				// The server would exit right away; it can be started again once the EULA is accepted.
+				s.setTarget(StopTarget)
+				s.setStatus(Stopped)
+				break
+			}
 			if s.process == nil {
-				s.process, err = newProcess(s.Config, s.dispatcher)
+				if failed, retry := s.preflight(ctx); failed {
+					s.setStatus(Stopped)
+					restartDelay = retry
+					break
+				}
+				s.process, err = newProcess(s.Config, s.dispatcher, s.parser)
 				if err != nil {
 					return err
 				}
 			}
-			if err = s.process.Start(); err != nil {
-				return err
+			if !s.process.Adopted {
+				if err = s.process.Start(); err != nil {
+					return err
+				}
 			}
+			s.setProcessInfo(s.process)
 			processDone = s.process.Done
 			s.setStatus(Started)
+			s.conditions = make(map[ReadinessCondition]bool)
+			if s.process.Adopted {
+				// The adopted server logged its startup before mcvisor was started.
+				s.setConditionMet(LogCondition)
+			}
+			if s.Server.Readiness.StartupTimeout > 0 {
+				startupTimeout = time.After(s.Server.Readiness.StartupTimeout)
+			}
+			if s.Server.Readiness.Has(PortCondition) {
+				go probePort(ctx, processDone, s.Server.Network, s.readiness)
+			}
 		case s.target.MustStop() && !s.status.IsOneOf(Stopping, Stopped):
 			if s.process == nil {
 				break
 			}
 			s.setStatus(Stopping)
-			s.process.Stop()
+			s.process.Stop(s.Server.StopTimeout, s.Server.TermTimeout)
 		case s.target == ShutdownTarget && s.status == Stopped:
 			return suture.ErrTerminateSupervisorTree
 		default:
@@ -138,22 +213,71 @@ func (s *Server) Serve(ctx context.Context) (err error) {
 
 		select {
 		case <-processDone:
-			if s.process.Err != nil {
-				log.WithError(s.process.Err).Info("server.exited")
+			exitErr := s.process.ExitError()
+			if exitErr != nil {
+				log.WithError(exitErr).Info("server.exited")
 			}
 			processDone = nil
+			startupTimeout = nil
+			s.watchdog.Reset()
+			s.rcon.Close()
+			s.properties.Clear()
 			s.process = nil
+			s.setProcessInfo(nil)
 			s.setStatus(Stopped)
+			if s.target == StartTarget {
+				restartDelay = s.planRestart(exitErr)
+			}
+		case <-restartDelay:
+			restartDelay = nil
 		case ping := <-s.pings:
-			if ping.IsSuccess() && s.status.IsOneOf(Started, Unreachable) {
+			switch {
+			case ping.IsSuccess() && s.status == Unreachable:
 				s.setStatus(Ready)
-			} else if !ping.IsSuccess() && s.status == Ready {
+			case ping.IsSuccess():
+				s.setConditionMet(PingCondition)
+			case s.status == Ready:
 				s.setStatus(Unreachable)
 			}
+			if s.status.IsOneOf(Ready, Unreachable) {
+				if restart := s.watchdog.Update(ping); restart != nil {
+					log.WithFields(restart).Warn("server.watchdog.restart")
+					s.dispatcher.Dispatch(restart)
+					if s.countdown != nil {
+						s.dropCountdown(RestartTarget)
+					}
+					s.setTarget(RestartTarget)
+				}
+			}
+		case <-s.dones:
+			s.setConditionMet(LogCondition)
+		case condition := <-s.readiness:
+			s.setConditionMet(condition)
+		case <-startupTimeout:
+			startupTimeout = nil
+			if s.status == Started {
+				timeout := s.Server.Readiness.StartupTimeout
+				log.WithField("timeout", timeout).Error("server.readiness.timeout")
+				s.dispatcher.Dispatch(&StartupTimedOut{timeout})
+				s.process.Failure = ErrStartupTimeout
+				s.setStatus(Stopping)
+				s.process.Stop(s.Server.StopTimeout, s.Server.TermTimeout)
+			}
 		case newTarget := <-s.targets:
+			if newTarget == StartTarget {
+				restartDelay = nil
+				s.restarter.Clear()
+			}
+			if s.countdown != nil && newTarget != s.target {
+				s.dropCountdown(newTarget)
+			}
 			s.setTarget(newTarget)
+		case req := <-s.countdowns:
+			req.reply <- s.setCountdown(req.countdown)
+		case <-s.countdown.Timer():
+			s.tickCountdown()
 		case cmd := <-s.console:
-			s.executeConsoleCommand(cmd.command, cmd.reply)
+			cmd.reply <- s.executeConsoleCommand(cmd.command)
 		case output := <-s.outputs:
 			log.WithField("output", output).Debug("server.stdout")
 		case <-ctx.Done():
@@ -162,16 +286,101 @@ func (s *Server) Serve(ctx context.Context) (err error) {
 	}
 }
 
+// Status returns the current status; it is safe to call from any goroutine.
 func (s *Server) Status() Status {
+	s.statusMu.Lock()
+	defer s.statusMu.Unlock()
 	return s.status
 }
 
-func (s *Server) setStatus(status Status) {
-	if s.status == status {
+// Versions describes the Minecraft and Java versions of the last started server.
+func (s *Server) Versions() string {
+	s.infoMu.Lock()
+	defer s.infoMu.Unlock()
+	return s.versions
+}
+
+// PID returns the process ID of the server, or 0 if it is not running.
+func (s *Server) PID() int {
+	s.infoMu.Lock()
+	defer s.infoMu.Unlock()
+	return s.pid
+}
+
+func (s *Server) setProcessInfo(p *process) {
+	s.infoMu.Lock()
+	defer s.infoMu.Unlock()
+	if p == nil {
+		s.pid = 0
+		return
+	}
+	s.pid = p.Process.Pid
+	if p.Java == nil {
+		// The versions of an adopted server are unknown.
+		s.versions = ""
 		return
 	}
+	s.versions = p.Java.String()
+	if p.Minecraft != nil {
+		s.versions = p.Minecraft.String() + ", " + s.versions
+	}
+}
+
+func (s *Server) setStatus(status Status) {
+	s.statusMu.Lock()
+	changed := s.status != status
 	s.status = status
-	s.dispatcher.Dispatch(status)
+	s.statusMu.Unlock()
+	if changed {
+		s.dispatcher.Dispatch(status)
+	}
+}
+
+func (s *Server) setConditionMet(condition ReadinessCondition) {
+	if s.status != Started {
+		return
+	}
+	log.WithField("condition", condition).Debug("server.readiness")
+	s.conditions[condition] = true
+	if s.Server.Readiness.IsSatisfied(s.conditions) {
+		// The server rewrites its properties while starting, they are only read once it is ready.
+		s.properties.Load(s.Server.AbsServerProperties())
+		s.setStatus(Ready)
+		s.restarter.Reset()
+	}
+}
+
+// planRestart applies the restart policy after the server exited on its own.
+// It returns a channel that fires when the server should be restarted, or nil if it should not.
+// Only a failure is reported as an unexpected exit.
+func (s *Server) planRestart(exitErr error) <-chan time.Time {
+	if !s.Server.Restart.ShouldRestart(exitErr) {
+		if exitErr != nil {
+			exit := &UnexpectedExit{ExitCode: exitCode(exitErr), Reason: exitErr}
+			log.WithFields(exit).Warn("server.restart.policy")
+			s.dispatcher.Dispatch(exit)
+		}
+		s.setTarget(StopTarget)
+		return nil
+	}
+
+	delay, ok := s.restarter.NextDelay(time.Now())
+	if !ok {
+		loop := &CrashLoop{exitCode(exitErr), exitErr, len(s.restarter.history), s.Server.Restart.Window}
+		log.WithFields(loop).Error("server.restart.crashloop")
+		s.dispatcher.Dispatch(loop)
+		s.setTarget(StopTarget)
+		return nil
+	}
+
+	if exitErr == nil {
+		log.WithField("restartDelay", delay).Info("server.restart.backoff")
+		return time.After(delay)
+	}
+	exit := &UnexpectedExit{exitCode(exitErr), exitErr, true, delay}
+	log.WithFields(exit).Warn("server.restart.backoff")
+	s.dispatcher.Dispatch(exit)
+	return time.After(delay)
 }
 
 func (s *Server) Start() {
@@ -182,6 +391,15 @@ func (s *Server) Shutdown() {
 	s.targets <- ShutdownTarget
 }
 
+// Detach stops supervising the server, leaving it running if it is detached, else shuts it down.
+func (s *Server) Detach() {
+	if s.Server.Detached.Enabled {
+		s.targets <- DetachTarget
+	} else {
+		s.Shutdown()
+	}
+}
+
 func (s *Server) setTarget(target Target) {
 	if s.target == target {
 		return
@@ -190,48 +408,23 @@ func (s *Server) setTarget(target Target) {
 	s.dispatcher.Dispatch(target)
 }
 
-func (s *Server) executeConsoleCommand(command string, reply chan<- string) {
-	defer close(reply)
-	_, err := io.WriteString(s.process.Stdin, command+"\n")
-	if err == nil {
-		log.WithField("command", command).Info("server.console")
-		var output ServerOutput
-		if output, err = utils.RecvWithTimeout(s.outputs, time.Second); err == nil {
-			reply <- "`" + string(output) + "`"
-			return
-		} else if err == context.DeadlineExceeded {
-			return
-		}
+func (s *Server) sendConsoleCommand(command string) error {
+	if s.process == nil {
+		return ErrStoppedServer
 	}
-	log.WithError(err).WithField("command", command).Warn("server.console")
-	reply <- err.Error()
+	_, err := io.WriteString(s.process.Stdin, command+"\n")
+	return err
 }
 
 func (s *Server) handleStatusCommand(cmd *commands.Command) (string, error) {
-	return fmt.Sprintf("Server %s", s.status), nil
-}
-
-func (s *Server) handleConsoleCommand(cmd *commands.Command) (reply string, err error) {
-	if !s.status.IsRunning() {
-		err = ErrStoppedServer
-		return
+	status := fmt.Sprintf("Server %s", s.Status())
+	if versions := s.Versions(); versions != "" {
+		status += fmt.Sprintf(" (%s)", versions)
 	}
-
-	ctx, cleanup := context.WithTimeout(context.Background(), ConsoleCommandTimeout)
-	defer cleanup()
-	replyC := make(chan string)
-
-	cmdStruct := &consoleCommand{
-		command: strings.Join(cmd.Arguments, " "),
-		reply:   replyC,
-	}
-	if err = utils.SendWithContext(s.console, cmdStruct, ctx); err != nil {
-		return
+	if pending := s.pendingProperties(); len(pending) > 0 {
+		status += fmt.Sprintf("\n:warning: restart required to apply the changes of: %s", strings.Join(pending, ", "))
 	}
-
-	reply, err = utils.RecvWithContext(replyC, ctx)
-
-	return
+	return status, nil
 }
 
 func (t Target) DiscordNotification() string {
@@ -244,6 +437,8 @@ func (t Target) DiscordNotification() string {
 		return "**Stopping the server**"
 	case ShutdownTarget:
 		return "**Shutting down**"
+	case DetachTarget:
+		return "**Leaving the server running while mcvisor is stopped**"
 	default:
 		return ""
 	}
@@ -258,8 +453,14 @@ func (t Target) MustStop() bool {
 }
 
 func (s *targetSetter) HandleCommand(cmd *commands.Command) (string, error) {
-	s.server.setTarget(s.target)
-	return "", nil
+	if len(cmd.Arguments) == 0 || cmd.Arguments[0] == "" || !s.target.MustStop() {
+		return "", utils.SendWithTimeout(s.server.targets, s.target, ConsoleCommandTimeout)
+	}
+	delay, err := time.ParseDuration(cmd.Arguments[0])
+	if err != nil || delay < 0 {
+		return "", fmt.Errorf("invalid delay: %q", cmd.Arguments[0])
+	}
+	return s.server.scheduleTarget(s.target, delay)
 }
 
 func (s Status) IsOneOf(status ...Status) bool {
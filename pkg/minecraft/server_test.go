@@ -0,0 +1,261 @@
+//go:build !windows
+
+package minecraft_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Adirelle/mcvisor/pkg/commands"
+	"github.com/Adirelle/mcvisor/pkg/events"
+	"github.com/Adirelle/mcvisor/pkg/minecraft"
+)
+
+func TestServerExit(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		exitCode   string
+		unexpected bool
+	}{
+		{"clean exit", "0", false},
+		{"failure", "3", true},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			config := minecraft.NewConfig(t.TempDir())
+			config.Java.Home = t.TempDir()
+			config.Server.Launcher.Type = minecraft.CustomLauncher
+			config.Server.Launcher.Command = []string{"/bin/sh", "-c", "exit " + test.exitCode}
+			config.Server.Restart.Policy = minecraft.RestartOnFailure
+			config.Server.Restart.MaxRestarts = 1
+			config.Server.Restart.MinBackoff = time.Minute
+			config.Server.Preflight.Ports = false
+			if err := minecraft.AcceptEULA(config.Server.AbsEULAFile(), "test", time.Now()); err != nil {
+				t.Fatal(err)
+			}
+
+			dispatcher := events.NewDispatcher()
+			exits := events.MakeHandler[*minecraft.UnexpectedExit]()
+			defer dispatcher.Subscribe(exits).Cancel()
+			statuses := events.MakeHandler[minecraft.Status]()
+			defer dispatcher.Subscribe(statuses).Cancel()
+			server := minecraft.NewServer(config, dispatcher, commands.NewRegistry())
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			go func() { _ = server.Serve(ctx) }()
+			server.Start()
+
+			for started := false; ; {
+				select {
+				case status := <-statuses:
+					started = started || status == minecraft.Started
+					if !started || status != minecraft.Stopped {
+						continue
+					}
+				case <-ctx.Done():
+					t.Fatal("the server did not stop")
+				}
+				break
+			}
+
+			select {
+			case exit := <-exits:
+				if !test.unexpected {
+					t.Errorf("unexpected event: %+v", exit)
+				} else if exit.ExitCode != 3 || !exit.Restarting || exit.RestartDelay != time.Minute {
+					t.Errorf("unexpected event: %+v", exit)
+				}
+			case <-time.After(200 * time.Millisecond):
+				if test.unexpected {
+					t.Error("expected an UnexpectedExit event")
+				}
+			}
+		})
+	}
+}
+
+// consoleScript mimics a server: it logs its startup, then echoes its console until it reads "stop".
+const consoleScript = `echo "[12:00:00] [Server thread/INFO]: Done (0.1s)!"
+while read -r line; do
+	echo "[12:00:01] [Server thread/INFO]: > $line"
+	[ "$line" = stop ] && exit 0
+done`
+
+func TestServerCountdown(t *testing.T) {
+	t.Parallel()
+
+	config := minecraft.NewConfig(t.TempDir())
+	config.Java.Home = t.TempDir()
+	config.Server.Launcher.Type = minecraft.CustomLauncher
+	config.Server.Launcher.Command = []string{"/bin/sh", "-c", consoleScript}
+	config.Server.Readiness.Conditions = []minecraft.ReadinessCondition{minecraft.LogCondition}
+	config.Server.Preflight.Ports = false
+	if err := minecraft.AcceptEULA(config.Server.AbsEULAFile(), "test", time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	dispatcher := events.NewDispatcher()
+	cancellations := events.MakeHandler[*minecraft.CountdownCancelled]()
+	defer dispatcher.Subscribe(cancellations).Cancel()
+	statuses := events.MakeHandler[minecraft.Status]()
+	defer dispatcher.Subscribe(statuses).Cancel()
+	registry := commands.NewRegistry()
+	server := minecraft.NewServer(config, dispatcher, registry)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	go func() { _ = server.Serve(ctx) }()
+	server.Start()
+	waitForStatus := func(expected minecraft.Status) {
+		t.Helper()
+		for {
+			select {
+			case status := <-statuses:
+				if status == expected {
+					return
+				}
+			case <-ctx.Done():
+				t.Fatalf("the server is not %s", expected)
+			}
+		}
+	}
+	waitForStatus(minecraft.Ready)
+
+	run := func(line string) string {
+		t.Helper()
+		reply, err := registry.HandleCommand(commands.NewCommand(line, commands.System))
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", line, err)
+		}
+		return reply
+	}
+	expectCancelled := func(expected minecraft.Target) {
+		t.Helper()
+		select {
+		case cancelled := <-cancellations:
+			if expected == "" || cancelled.Target != expected {
+				t.Errorf("unexpected cancellation: %+v", cancelled)
+			}
+		case <-time.After(200 * time.Millisecond):
+			if expected != "" {
+				t.Errorf("expected the %s to be cancelled", expected.Noun())
+			}
+		}
+	}
+
+	if reply := run("restart 10m"); reply != "" {
+		t.Errorf("unexpected reply: %q", reply)
+	}
+	if reply := run("restart 5m"); !strings.HasPrefix(reply, "The pending server restart, due in 10 minutes, has been replaced") {
+		t.Errorf("unexpected reply: %q", reply)
+	}
+	expectCancelled("")
+
+	if reply := run("stop 10m"); !strings.HasPrefix(reply, "The pending server restart, due in 5 minutes, has been replaced") {
+		t.Errorf("unexpected reply: %q", reply)
+	}
+	expectCancelled(minecraft.RestartTarget)
+
+	// Stopping now fulfills the pending stop rather than cancelling it.
+	run("stop")
+	expectCancelled("")
+	waitForStatus(minecraft.Stopped)
+}
+
+func TestServerRconDropped(t *testing.T) {
+	t.Parallel()
+
+	config := minecraft.NewConfig(t.TempDir())
+	config.Java.Home = t.TempDir()
+	config.Server.Launcher.Type = minecraft.CustomLauncher
+	config.Server.Launcher.Command = []string{"/bin/sh", "-c", "while read -r line; do echo \"$line\" >> stdin.log; done"}
+	config.Server.Network.Host = "127.0.0.1"
+	config.Server.Preflight.Ports = false
+	if err := minecraft.AcceptEULA(config.Server.AbsEULAFile(), "test", time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	// The RCON server reads the command, then drops the connection without answering.
+	address := serveRcon(t, "secret")
+	properties := fmt.Sprintf("enable-rcon=true\nrcon.port=%d\nrcon.password=secret\n", address.Port)
+	if err := os.WriteFile(config.Server.AbsServerProperties(), []byte(properties), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	dispatcher := events.NewDispatcher()
+	statuses := events.MakeHandler[minecraft.Status]()
+	defer dispatcher.Subscribe(statuses).Cancel()
+	server := minecraft.NewServer(config, dispatcher, commands.NewRegistry())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	go func() { _ = server.Serve(ctx) }()
+	server.Start()
+	defer server.Shutdown()
+	for status := minecraft.Stopped; status != minecraft.Started; {
+		select {
+		case status = <-statuses:
+		case <-ctx.Done():
+			t.Fatal("the server did not start")
+		}
+	}
+
+	if _, err := server.ExecuteConsoleCommand("say hello"); err == nil {
+		t.Error("expected an error")
+	}
+	if _, err := server.ExecuteConsoleCommand("echo sent"); err != nil {
+		t.Errorf("the next command should reconnect: %s", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if content, err := os.ReadFile(filepath.Join(config.Server.AbsWorkingDir(), "stdin.log")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("the command was sent again through the standard input: %q, %v", content, err)
+	}
+}
+
+func TestServerStatusCommand(t *testing.T) {
+	t.Parallel()
+
+	config := minecraft.NewConfig(t.TempDir())
+	config.Java.Home = t.TempDir()
+	config.Server.Launcher.Type = minecraft.CustomLauncher
+	config.Server.Launcher.Command = []string{"/bin/sh", "-c", "read -r line"}
+	config.Server.Preflight.Ports = false
+	if err := minecraft.AcceptEULA(config.Server.AbsEULAFile(), "test", time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	registry := commands.NewRegistry()
+	server := minecraft.NewServer(config, events.NewDispatcher(), registry)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	go func() { _ = server.Serve(ctx) }()
+	server.Start()
+	defer server.Shutdown()
+
+	// The status command runs outside of the Serve goroutine, while the status changes.
+	for {
+		reply, err := registry.HandleCommand(commands.NewCommand("status", commands.System))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.HasPrefix(reply, "Server started") {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			t.Fatalf("the server did not start, last status: %q", reply)
+		case <-time.After(time.Millisecond):
+		}
+	}
+}
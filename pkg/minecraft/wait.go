@@ -0,0 +1,47 @@
+package minecraft
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Adirelle/mcvisor/pkg/events"
+)
+
+// ReachTarget sets the server target and waits for the matching status:
+// Stopped for StopTarget, and Ready for StartTarget and RestartTarget.
+func ReachTarget(dispatcher *events.Dispatcher, statuser Statuser, target Target, timeout time.Duration) (Status, error) {
+	statuses := events.MakeHandler[Status]()
+	defer dispatcher.Subscribe(statuses).Cancel()
+
+	var reached func(Status) bool
+	switch target {
+	case StopTarget, ShutdownTarget:
+		reached = func(status Status) bool { return status == Stopped }
+	case StartTarget:
+		reached = func(status Status) bool { return status == Ready }
+	default:
+		stopped := statuser.Status() == Stopped
+		reached = func(status Status) bool {
+			stopped = stopped || status == Stopped
+			return stopped && status == Ready
+		}
+	}
+
+	dispatcher.Dispatch(target)
+	if status := statuser.Status(); target != RestartTarget && reached(status) {
+		return status, nil
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for {
+		select {
+		case status := <-statuses:
+			if reached(status) {
+				return status, nil
+			}
+		case <-timer.C:
+			return statuser.Status(), fmt.Errorf("server did not reach the %s target within %s", target, timeout)
+		}
+	}
+}
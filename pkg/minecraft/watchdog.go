@@ -0,0 +1,101 @@
+package minecraft
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Adirelle/mcvisor/pkg/discord"
+	"github.com/apex/log"
+)
+
+type (
+	WatchdogConfig struct {
+		MaxFailedPings uint          `json:"max_failed_pings"`
+		MaxUnreachable time.Duration `json:"max_unreachable"`
+		Cooldown       time.Duration `json:"cooldown"`
+	}
+
+	watchdog struct {
+		*WatchdogConfig
+		failedPings      uint
+		unreachableSince time.Time
+		lastRestart      time.Time
+	}
+
+	WatchdogRestart struct {
+		FailedPings uint
+		Unreachable time.Duration
+		Reason      error
+	}
+)
+
+const (
+	DefaultWatchdogCooldown = 15 * time.Minute
+)
+
+var (
+	// Interface check
+	_ discord.Notification = (*WatchdogRestart)(nil)
+)
+
+func NewWatchdogConfig() *WatchdogConfig {
+	return &WatchdogConfig{Cooldown: DefaultWatchdogCooldown}
+}
+
+func (c *WatchdogConfig) IsEnabled() bool {
+	return c.MaxFailedPings > 0 || c.MaxUnreachable > 0
+}
+
+// Update keeps track of the unreachability of the server and returns a WatchdogRestart when it should be restarted.
+func (w *watchdog) Update(ping PingerEvent) *WatchdogRestart {
+	failure, isFailure := ping.(*PingFailed)
+	if !isFailure {
+		w.Reset()
+		return nil
+	}
+
+	w.failedPings++
+	if w.unreachableSince.IsZero() {
+		w.unreachableSince = failure.When
+	}
+	unreachable := failure.When.Sub(w.unreachableSince)
+
+	if !w.IsEnabled() ||
+		(w.MaxFailedPings == 0 || w.failedPings < w.MaxFailedPings) &&
+			(w.MaxUnreachable == 0 || unreachable < w.MaxUnreachable) {
+		return nil
+	}
+
+	if !w.lastRestart.IsZero() && failure.When.Sub(w.lastRestart) < w.Cooldown {
+		log.WithField("lastRestart", w.lastRestart).Debug("server.watchdog.cooldown")
+		return nil
+	}
+
+	w.lastRestart = failure.When
+	restart := &WatchdogRestart{w.failedPings, unreachable, failure.Reason}
+	w.Reset()
+	return restart
+}
+
+// Reset forgets about previous failures, but not about the last restart.
+func (w *watchdog) Reset() {
+	w.failedPings = 0
+	w.unreachableSince = time.Time{}
+}
+
+func (r *WatchdogRestart) Fields() log.Fields {
+	return log.Fields{
+		"failedPings": r.FailedPings,
+		"unreachable": r.Unreachable,
+		"error":       r.Reason,
+	}
+}
+
+func (r *WatchdogRestart) DiscordNotification() string {
+	return fmt.Sprintf(
+		"**Automatic watchdog restart**: server unreachable for %s (%d failed pings), last error: %s",
+		r.Unreachable.Round(time.Second),
+		r.FailedPings,
+		r.Reason,
+	)
+}
@@ -0,0 +1,139 @@
+package rcon
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+type (
+	// Client is a connection to a RCON server. It can be used by several goroutines, one command at a time.
+	Client struct {
+		conn    net.Conn
+		timeout time.Duration
+		mu      sync.Mutex
+		lastID  int32
+	}
+)
+
+const (
+	// MaxCommandLength is the longest command that Minecraft reads in one packet.
+	MaxCommandLength = 1446
+)
+
+var (
+	// SplitResponseDelay is how long to wait for the next part of a response that has been split into several packets.
+	SplitResponseDelay = 200 * time.Millisecond
+
+	ErrAuthFailed      = errors.New("rcon: authentication failed")
+	ErrCommandTooLong  = fmt.Errorf("rcon: command is longer than %d bytes", MaxCommandLength)
+	ErrUnexpectedReply = errors.New("rcon: unexpected reply")
+	ErrNotSent         = errors.New("rcon: command not sent")
+)
+
+// Dial connects to a RCON server and authenticates. The timeout applies to the connection and to every command.
+func Dial(address, password string, timeout time.Duration) (*Client, error) {
+	conn, err := net.DialTimeout("tcp", address, timeout)
+	if err != nil {
+		return nil, err
+	}
+	c := &Client{conn: conn, timeout: timeout}
+	if err = c.authenticate(password); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *Client) String() string {
+	return fmt.Sprintf("rcon://%s", c.conn.RemoteAddr())
+}
+
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+func (c *Client) authenticate(password string) error {
+	id := c.newID()
+	if err := c.send(&Packet{id, AuthType, password}); err != nil {
+		return err
+	}
+	for {
+		reply, err := ReadPacket(c.conn)
+		if err != nil {
+			return err
+		}
+		// Some servers send an empty response before the actual authentication response.
+		if reply.Type == ResponseType {
+			continue
+		}
+		switch {
+		case reply.Type != AuthResponseType:
+			return ErrUnexpectedReply
+		case reply.ID == AuthFailedID:
+			return ErrAuthFailed
+		case reply.ID != id:
+			return ErrUnexpectedReply
+		default:
+			return nil
+		}
+	}
+}
+
+// Execute runs the command and returns its response.
+//
+// Minecraft only reads the first packet of each network read, so the end of a response split into several packets
+// cannot be detected by sending a second request. Instead, a response part of the maximum size is assumed to be
+// followed by another one, which is waited for during SplitResponseDelay.
+func (c *Client) Execute(command string) (string, error) {
+	if len(command) > MaxCommandLength {
+		return "", ErrCommandTooLong
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	id := c.newID()
+	if err := c.send(&Packet{id, CommandType, command}); err != nil {
+		return "", fmt.Errorf("%w: %v", ErrNotSent, err)
+	}
+
+	var response strings.Builder
+	for {
+		reply, err := ReadPacket(c.conn)
+		if netErr, ok := err.(net.Error); ok && netErr.Timeout() && response.Len() > 0 {
+			return response.String(), nil
+		} else if err != nil {
+			return response.String(), err
+		}
+		if reply.ID != id || reply.Type != ResponseType {
+			continue
+		}
+		response.WriteString(reply.Body)
+		if len(reply.Body) < MaxResponseBody {
+			return response.String(), nil
+		}
+		if err = c.conn.SetReadDeadline(time.Now().Add(SplitResponseDelay)); err != nil {
+			return response.String(), err
+		}
+	}
+}
+
+func (c *Client) send(packet *Packet) error {
+	if err := c.conn.SetDeadline(time.Now().Add(c.timeout)); err != nil {
+		return err
+	}
+	_, err := packet.WriteTo(c.conn)
+	return err
+}
+
+func (c *Client) newID() int32 {
+	c.lastID++
+	if c.lastID <= 0 {
+		c.lastID = 1
+	}
+	return c.lastID
+}
@@ -0,0 +1,112 @@
+package rcon_test
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Adirelle/mcvisor/pkg/rcon"
+)
+
+const testPassword = "secret"
+
+// serveFake answers like Minecraft: "echo <text>" returns text and "repeat <n>" returns n bytes,
+// split into several packets if needed.
+func serveFake(t *testing.T) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go handleFake(conn)
+		}
+	}()
+	return listener.Addr().String()
+}
+
+func handleFake(conn net.Conn) {
+	defer conn.Close()
+	for {
+		packet, err := rcon.ReadPacket(conn)
+		if err != nil {
+			return
+		}
+		switch packet.Type {
+		case rcon.AuthType:
+			id := packet.ID
+			if packet.Body != testPassword {
+				id = rcon.AuthFailedID
+			}
+			_, _ = (&rcon.Packet{ID: id, Type: rcon.AuthResponseType}).WriteTo(conn)
+		case rcon.CommandType:
+			body := strings.TrimPrefix(packet.Body, "echo ")
+			if count, err := strconv.Atoi(strings.TrimPrefix(packet.Body, "repeat ")); err == nil {
+				body = strings.Repeat("x", count)
+			}
+			for {
+				part := body
+				if len(part) > rcon.MaxResponseBody {
+					part = part[:rcon.MaxResponseBody]
+				}
+				_, _ = (&rcon.Packet{ID: packet.ID, Type: rcon.ResponseType, Body: part}).WriteTo(conn)
+				body = body[len(part):]
+				if body == "" {
+					break
+				}
+			}
+		}
+	}
+}
+
+func TestClientExecute(t *testing.T) {
+	t.Parallel()
+
+	client, err := rcon.Dial(serveFake(t), testPassword, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	response, err := client.Execute("echo hello")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if response != "hello" {
+		t.Errorf("expected %q, got %q", "hello", response)
+	}
+
+	for _, size := range []int{0, 10000, rcon.MaxResponseBody, 2 * rcon.MaxResponseBody} {
+		response, err := client.Execute(fmt.Sprintf("repeat %d", size))
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if len(response) != size {
+			t.Errorf("expected a %d-byte response, got %d bytes", size, len(response))
+		}
+	}
+
+	if _, err = client.Execute(strings.Repeat("x", rcon.MaxCommandLength+1)); !errors.Is(err, rcon.ErrCommandTooLong) {
+		t.Errorf("expected ErrCommandTooLong, got %v", err)
+	}
+}
+
+func TestClientWrongPassword(t *testing.T) {
+	t.Parallel()
+
+	_, err := rcon.Dial(serveFake(t), "wrong", time.Second)
+	if !errors.Is(err, rcon.ErrAuthFailed) {
+		t.Errorf("expected ErrAuthFailed, got %v", err)
+	}
+}
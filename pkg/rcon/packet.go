@@ -0,0 +1,81 @@
+package rcon
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+type (
+	// Packet is a message of the Source RCON protocol, as implemented by Minecraft.
+	Packet struct {
+		ID   int32
+		Type PacketType
+		Body string
+	}
+
+	PacketType int32
+)
+
+const (
+	ResponseType PacketType = 0
+	CommandType  PacketType = 2
+	AuthType     PacketType = 3
+
+	// AuthResponseType has the same value as CommandType, the direction tells them apart.
+	AuthResponseType PacketType = 2
+
+	// AuthFailedID is the ID of the authentication response when the password is wrong.
+	AuthFailedID int32 = -1
+
+	// MaxResponseBody is the size above which Minecraft splits the responses into several packets.
+	MaxResponseBody = 4096
+
+	// MaxPacketSize is the largest packet accepted, including its length field.
+	MaxPacketSize = 4 + 4 + 4 + MaxResponseBody + 2
+
+	headerSize = 4 + 4 + 2
+)
+
+var (
+	ErrPacketSize = errors.New("invalid packet size")
+	ErrPadding    = errors.New("invalid packet padding")
+)
+
+// ReadPacket reads a single packet.
+func ReadPacket(r io.Reader) (*Packet, error) {
+	var size int32
+	if err := binary.Read(r, binary.LittleEndian, &size); err != nil {
+		return nil, err
+	}
+	if size < headerSize || size > MaxPacketSize-4 {
+		return nil, fmt.Errorf("%w: %d", ErrPacketSize, size)
+	}
+
+	buf := make([]byte, size)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(buf[size-2:], []byte{0, 0}) {
+		return nil, ErrPadding
+	}
+	return &Packet{
+		ID:   int32(binary.LittleEndian.Uint32(buf[0:4])),
+		Type: PacketType(binary.LittleEndian.Uint32(buf[4:8])),
+		Body: string(buf[8 : size-2]),
+	}, nil
+}
+
+// WriteTo writes the packet in a single call to w.
+func (p *Packet) WriteTo(w io.Writer) (int64, error) {
+	size := headerSize + len(p.Body)
+	buf := make([]byte, 4+size)
+	binary.LittleEndian.PutUint32(buf[0:4], uint32(size))
+	binary.LittleEndian.PutUint32(buf[4:8], uint32(p.ID))
+	binary.LittleEndian.PutUint32(buf[8:12], uint32(p.Type))
+	copy(buf[12:], p.Body)
+	n, err := w.Write(buf)
+	return int64(n), err
+}
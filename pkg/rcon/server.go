@@ -0,0 +1,209 @@
+package rcon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/apex/log"
+)
+
+type (
+	// Handler authenticates the clients of a RCON server.
+	Handler interface {
+		// Login returns the session for the given password, or false if the password is wrong.
+		Login(password string, remote net.Addr) (Session, bool)
+	}
+
+	// Session executes the commands of an authenticated client.
+	Session interface {
+		Execute(command string) string
+	}
+
+	// authLimiter slows down password guessing: it tracks the failed logins of each remote host and locks it out
+	// after too many of them.
+	authLimiter struct {
+		mu    sync.Mutex
+		hosts map[string]*authFailures
+	}
+
+	authFailures struct {
+		count int
+		last  time.Time
+		until time.Time
+	}
+)
+
+var (
+	// AuthFailureDelay is how long to wait before answering a failed login.
+	AuthFailureDelay = time.Second
+
+	// MaxAuthFailures is the number of failed logins after which a host is locked out for AuthLockout.
+	MaxAuthFailures = 5
+	AuthLockout     = 5 * time.Minute
+)
+
+// Serve accepts RCON connections on the listener until the context is done.
+// It closes the listener and all the connections before returning.
+func Serve(ctx context.Context, listener net.Listener, handler Handler) error {
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	conns := make(map[net.Conn]bool)
+	limiter := &authLimiter{hosts: make(map[string]*authFailures)}
+
+	// Cancelling on return also stops the goroutine below when Accept fails for another reason.
+	defer wg.Wait()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	go func() {
+		<-ctx.Done()
+		_ = listener.Close()
+		mu.Lock()
+		defer mu.Unlock()
+		for conn := range conns {
+			_ = conn.Close()
+		}
+	}()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if ctx.Err() != nil || errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+
+		mu.Lock()
+		conns[conn] = true
+		mu.Unlock()
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			serveConn(ctx, conn, handler, limiter)
+			mu.Lock()
+			delete(conns, conn)
+			mu.Unlock()
+		}()
+	}
+}
+
+func serveConn(ctx context.Context, conn net.Conn, handler Handler, limiter *authLimiter) {
+	defer conn.Close()
+	logger := log.WithField("remote", conn.RemoteAddr().String())
+	host := remoteHost(conn.RemoteAddr())
+
+	var session Session
+	for {
+		packet, err := ReadPacket(conn)
+		if err != nil {
+			logger.WithError(err).Debug("rcon.server.disconnected")
+			return
+		}
+
+		switch {
+		case packet.Type == AuthType:
+			var ok bool
+			if limiter.IsLocked(host, time.Now()) {
+				logger.Warn("rcon.server.auth.locked")
+			} else if session, ok = handler.Login(packet.Body, conn.RemoteAddr()); !ok {
+				logger.WithField("lockedOut", limiter.Fail(host, time.Now())).Warn("rcon.server.auth.failed")
+			}
+			if !ok {
+				select {
+				case <-time.After(AuthFailureDelay):
+				case <-ctx.Done():
+					return
+				}
+				_, _ = (&Packet{AuthFailedID, AuthResponseType, ""}).WriteTo(conn)
+				return
+			}
+			limiter.Reset(host)
+			logger.Debug("rcon.server.auth")
+			err = writePackets(conn, &Packet{packet.ID, AuthResponseType, ""})
+		case session == nil:
+			logger.WithField("type", packet.Type).Warn("rcon.server.unauthenticated")
+			return
+		case packet.Type == CommandType:
+			err = writeResponse(conn, packet.ID, session.Execute(packet.Body))
+		default:
+			err = writeResponse(conn, packet.ID, fmt.Sprintf("Unknown request %x", packet.Type))
+		}
+		if err != nil {
+			logger.WithError(err).Debug("rcon.server.write")
+			return
+		}
+	}
+}
+
+func remoteHost(addr net.Addr) string {
+	if host, _, err := net.SplitHostPort(addr.String()); err == nil {
+		return host
+	}
+	return addr.String()
+}
+
+// IsLocked returns whether the host is locked out.
+func (l *authLimiter) IsLocked(host string, now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	failures, found := l.hosts[host]
+	return found && now.Before(failures.until)
+}
+
+// Fail records a failed login and returns whether the host is now locked out.
+// The failures are forgotten after AuthLockout without any of them.
+func (l *authLimiter) Fail(host string, now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	failures, found := l.hosts[host]
+	if !found || now.Sub(failures.last) > AuthLockout {
+		failures = &authFailures{}
+		l.hosts[host] = failures
+	}
+	failures.count++
+	failures.last = now
+	if failures.count < MaxAuthFailures {
+		return false
+	}
+	failures.count = 0
+	failures.until = now.Add(AuthLockout)
+	return true
+}
+
+// Reset forgets the failed logins of the host after a successful one.
+func (l *authLimiter) Reset(host string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.hosts, host)
+}
+
+// writeResponse sends the response, split into several packets if it is too long.
+func writeResponse(conn net.Conn, id int32, response string) error {
+	packets := make([]*Packet, 0, len(response)/MaxResponseBody+1)
+	for {
+		part := response
+		if len(part) > MaxResponseBody {
+			part = part[:MaxResponseBody]
+		}
+		packets = append(packets, &Packet{id, ResponseType, part})
+		response = response[len(part):]
+		if response == "" {
+			return writePackets(conn, packets...)
+		}
+	}
+}
+
+func writePackets(conn net.Conn, packets ...*Packet) error {
+	for _, packet := range packets {
+		if _, err := packet.WriteTo(conn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package rcon_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Adirelle/mcvisor/pkg/rcon"
+)
+
+type upperHandler struct{}
+
+type upperSession struct{}
+
+func (upperHandler) Login(password string, remote net.Addr) (rcon.Session, bool) {
+	return upperSession{}, password == testPassword
+}
+
+func (upperSession) Execute(command string) string {
+	return strings.ToUpper(command)
+}
+
+func TestServe(t *testing.T) {
+	t.Parallel()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- rcon.Serve(ctx, listener, upperHandler{}) }()
+
+	if _, err = rcon.Dial(listener.Addr().String(), "wrong", rcon.AuthFailureDelay+time.Second); !errors.Is(err, rcon.ErrAuthFailed) {
+		t.Errorf("expected ErrAuthFailed, got %v", err)
+	}
+
+	client, err := rcon.Dial(listener.Addr().String(), testPassword, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	long := strings.Repeat("abc", rcon.MaxResponseBody)
+	for _, command := range []string{"hello", long[:rcon.MaxCommandLength]} {
+		response, err := client.Execute(command)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if response != strings.ToUpper(command) {
+			t.Errorf("unexpected response to %q: %q", command, response)
+		}
+	}
+
+	cancel()
+	select {
+	case err = <-done:
+		if err != nil {
+			t.Errorf("unexpected error: %s", err)
+		}
+	case <-time.After(time.Second):
+		t.Error("Serve did not return")
+	}
+	if _, err = client.Execute("hello"); err == nil {
+		t.Error("the connection should have been closed")
+	}
+}
+
+// failingListener fails to accept connections, and records whether it has been closed.
+type failingListener struct {
+	net.Listener
+	closed chan struct{}
+}
+
+func (l *failingListener) Accept() (net.Conn, error) {
+	return nil, errors.New("too many open files")
+}
+
+func (l *failingListener) Close() error {
+	close(l.closed)
+	return l.Listener.Close()
+}
+
+func TestServeAcceptError(t *testing.T) {
+	t.Parallel()
+
+	inner, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	listener := &failingListener{inner, make(chan struct{})}
+
+	if err = rcon.Serve(context.Background(), listener, upperHandler{}); err == nil {
+		t.Error("expected the accept error")
+	}
+	select {
+	case <-listener.closed:
+	case <-time.After(time.Second):
+		t.Error("the listener was not closed")
+	}
+}
+
+func TestServeAuthLockout(t *testing.T) {
+	delay, maxFailures := rcon.AuthFailureDelay, rcon.MaxAuthFailures
+	rcon.AuthFailureDelay, rcon.MaxAuthFailures = 50*time.Millisecond, 2
+	t.Cleanup(func() { rcon.AuthFailureDelay, rcon.MaxAuthFailures = delay, maxFailures })
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = rcon.Serve(ctx, listener, upperHandler{}) }()
+	address := listener.Addr().String()
+
+	for i := 0; i < 2; i++ {
+		start := time.Now()
+		if _, err = rcon.Dial(address, "wrong", time.Second); !errors.Is(err, rcon.ErrAuthFailed) {
+			t.Fatalf("expected ErrAuthFailed, got %v", err)
+		}
+		if elapsed := time.Since(start); elapsed < rcon.AuthFailureDelay {
+			t.Errorf("the failure was answered after %s only", elapsed)
+		}
+	}
+
+	if _, err = rcon.Dial(address, testPassword, time.Second); !errors.Is(err, rcon.ErrAuthFailed) {
+		t.Errorf("expected the host to be locked out, got %v", err)
+	}
+}
@@ -0,0 +1,39 @@
+package rconproxy
+
+import (
+	"github.com/Adirelle/mcvisor/pkg/utils"
+)
+
+type (
+	Config struct {
+		Enabled   bool                `json:"enabled"`
+		Address   string              `json:"address" validate:"required,hostname_port"`
+		Users     []*UserConfig       `json:"users,omitempty" validate:"required_if=Enabled true,dive"`
+		Allowlist map[string][]string `json:"allowlist" validate:"dive,keys,oneof=public query control admin,endkeys"`
+	}
+
+	UserConfig struct {
+		Name       string       `json:"name" validate:"required"`
+		Password   utils.Secret `json:"password" validate:"required"`
+		Permission string       `json:"permission" validate:"oneof=public query control admin"`
+		Server     string       `json:"server,omitempty"`
+	}
+)
+
+const (
+	DefaultAddress = "127.0.0.1:25576"
+
+	// AnyCommand in an allowlist allows all console commands.
+	AnyCommand = "*"
+)
+
+func NewConfig() *Config {
+	return &Config{
+		Address: DefaultAddress,
+		Allowlist: map[string][]string{
+			"query":   {"list", "seed", "banlist"},
+			"control": {"say", "tell", "msg", "kick", "whitelist", "save-all"},
+			"admin":   {AnyCommand},
+		},
+	}
+}
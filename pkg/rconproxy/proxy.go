@@ -0,0 +1,184 @@
+package rconproxy
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/Adirelle/mcvisor/pkg/commands"
+	"github.com/Adirelle/mcvisor/pkg/discord"
+	"github.com/Adirelle/mcvisor/pkg/minecraft"
+	"github.com/Adirelle/mcvisor/pkg/rcon"
+	"github.com/apex/log"
+	"github.com/thejerf/suture/v4"
+)
+
+type (
+	// Proxy is a RCON server that forwards the console commands of its users to the Minecraft server,
+	// provided they are allowed to.
+	Proxy struct {
+		*Config
+		users     []*user
+		allowlist map[string][]commands.Permission
+	}
+
+	user struct {
+		*UserConfig
+		permission commands.Permission
+		console    minecraft.Console
+	}
+
+	session struct {
+		*user
+		remote net.Addr
+		proxy  *Proxy
+	}
+)
+
+var (
+	// Interface checks
+	_ suture.Service = (*Proxy)(nil)
+	_ rcon.Handler   = (*Proxy)(nil)
+	_ rcon.Session   = (*session)(nil)
+	_ commands.Actor = (*session)(nil)
+	_ log.Fielder    = (*session)(nil)
+
+	ErrEmptyCommand = errors.New("empty command")
+	ErrNoServer     = errors.New("a server name is required when there are several servers")
+)
+
+// NewProxy creates a proxy to the given servers. Each user is bound to one of them, which can be omitted if there is
+// only one server.
+func NewProxy(config *Config, consoles map[string]minecraft.Console) (*Proxy, error) {
+	p := &Proxy{
+		Config:    config,
+		allowlist: make(map[string][]commands.Permission),
+	}
+	for _, conf := range config.Users {
+		permission, err := discord.ParseCategory(conf.Permission)
+		if err != nil {
+			return nil, fmt.Errorf("rcon user %s: %w", conf.Name, err)
+		}
+		console, err := selectConsole(consoles, conf.Server)
+		if err != nil {
+			return nil, fmt.Errorf("rcon user %s: %w", conf.Name, err)
+		}
+		p.users = append(p.users, &user{conf, permission, console})
+	}
+	for name, commandNames := range config.Allowlist {
+		permission, err := discord.ParseCategory(name)
+		if err != nil {
+			return nil, err
+		}
+		for _, command := range commandNames {
+			p.allowlist[command] = append(p.allowlist[command], permission)
+		}
+	}
+	return p, nil
+}
+
+func selectConsole(consoles map[string]minecraft.Console, name string) (minecraft.Console, error) {
+	if name != "" {
+		if console, found := consoles[name]; found {
+			return console, nil
+		}
+		return nil, fmt.Errorf("unknown server: %q", name)
+	}
+	if len(consoles) != 1 {
+		return nil, ErrNoServer
+	}
+	for _, console := range consoles {
+		return console, nil
+	}
+	return nil, ErrNoServer
+}
+
+func (p *Proxy) String() string {
+	return fmt.Sprintf("RconProxy(%s)", p.Address)
+}
+
+func (p *Proxy) Serve(ctx context.Context) error {
+	listener, err := net.Listen("tcp", p.Address)
+	if err != nil {
+		log.WithError(err).WithField("address", p.Address).Error("rcon.proxy.listen")
+		return err
+	}
+	log.WithField("address", p.Address).Info("rcon.proxy.listen")
+	return rcon.Serve(ctx, listener, p)
+}
+
+// Login returns a session for the user with the given password.
+func (p *Proxy) Login(password string, remote net.Addr) (rcon.Session, bool) {
+	for _, user := range p.users {
+		if subtle.ConstantTimeCompare([]byte(password), []byte(user.Password.Reveal())) == 1 {
+			s := &session{user, remote, p}
+			log.WithFields(s).Info("rcon.proxy.login")
+			return s, true
+		}
+	}
+	log.WithField("remote", remote.String()).Warn("rcon.proxy.login")
+	return nil, false
+}
+
+// IsAllowed returns whether the actor can send the console command.
+func (p *Proxy) IsAllowed(actor commands.Actor, command string) bool {
+	words := strings.Fields(command)
+	if len(words) == 0 {
+		return false
+	}
+	name := strings.TrimPrefix(words[0], "/")
+	for _, permissions := range [][]commands.Permission{p.allowlist[name], p.allowlist[AnyCommand]} {
+		for _, permission := range permissions {
+			if actor.HasPermission(permission) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Execute forwards the command to the server console.
+func (s *session) Execute(command string) string {
+	logger := log.WithFields(s).WithField("command", command)
+
+	var reply string
+	var err error
+	switch {
+	case strings.TrimSpace(command) == "":
+		err = ErrEmptyCommand
+	case !s.proxy.IsAllowed(s, command):
+		err = commands.ErrPermissionDenied
+	default:
+		reply, err = s.console.ExecuteConsoleCommand(command)
+	}
+
+	if err != nil {
+		logger.WithError(err).Warn("rcon.proxy.command")
+		if reply == "" {
+			return err.Error()
+		}
+		return reply + "\n" + err.Error()
+	}
+	logger.WithField("reply", reply).Info("rcon.proxy.command")
+	return reply
+}
+
+func (s *session) HasPermission(permission commands.Permission) bool {
+	return discord.CategoryIncludes(s.permission, permission)
+}
+
+func (s *session) String() string {
+	return fmt.Sprintf("RCON user %s", s.Name)
+}
+
+func (s *session) Fields() log.Fields {
+	return log.Fields{
+		"actor":  "rcon",
+		"user":   s.Name,
+		"server": s.Server,
+		"remote": s.remote.String(),
+	}
+}
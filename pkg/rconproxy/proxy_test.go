@@ -0,0 +1,73 @@
+package rconproxy_test
+
+import (
+	"net"
+	"testing"
+
+	"github.com/Adirelle/mcvisor/pkg/minecraft"
+	"github.com/Adirelle/mcvisor/pkg/rconproxy"
+)
+
+type fakeConsole struct {
+	commands []string
+}
+
+func (c *fakeConsole) ExecuteConsoleCommand(command string) (string, error) {
+	c.commands = append(c.commands, command)
+	return "ok: " + command, nil
+}
+
+func newProxy(t *testing.T, console *fakeConsole) *rconproxy.Proxy {
+	t.Helper()
+	config := rconproxy.NewConfig()
+	config.Users = []*rconproxy.UserConfig{
+		{Name: "monitor", Password: "query-pass", Permission: "query"},
+		{Name: "moderator", Password: "control-pass", Permission: "control"},
+		{Name: "owner", Password: "admin-pass", Permission: "admin"},
+	}
+	proxy, err := rconproxy.NewProxy(config, map[string]minecraft.Console{"survival": console})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return proxy
+}
+
+func TestProxyPermissions(t *testing.T) {
+	t.Parallel()
+
+	console := &fakeConsole{}
+	proxy := newProxy(t, console)
+	remote := &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 12345}
+
+	if _, ok := proxy.Login("wrong", remote); ok {
+		t.Error("login should fail with a wrong password")
+	}
+
+	cases := []struct {
+		password string
+		command  string
+		allowed  bool
+	}{
+		{"query-pass", "list", true},
+		{"query-pass", "/list uuids", true},
+		{"query-pass", "say hello", false},
+		{"control-pass", "list", true},
+		{"control-pass", "say hello", true},
+		{"control-pass", "op someone", false},
+		{"admin-pass", "op someone", true},
+		{"admin-pass", "", false},
+	}
+	for _, c := range cases {
+		session, ok := proxy.Login(c.password, remote)
+		if !ok {
+			t.Fatalf("login failed with %q", c.password)
+		}
+		console.commands = nil
+		reply := session.Execute(c.command)
+		if executed := len(console.commands) == 1; executed != c.allowed {
+			t.Errorf("%s: expected %q to be allowed=%v, got reply %q", c.password, c.command, c.allowed, reply)
+		} else if executed && reply != "ok: "+c.command {
+			t.Errorf("%s: unexpected reply to %q: %q", c.password, c.command, reply)
+		}
+	}
+}
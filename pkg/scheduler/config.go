@@ -0,0 +1,90 @@
+package scheduler
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+
+	"github.com/Adirelle/mcvisor/pkg/minecraft"
+)
+
+type (
+	Config struct {
+		TimeZone  string   `json:"timezone,omitempty"`
+		StateFile string   `json:"state_file,omitempty"`
+		Entries   []*Entry `json:"entries,omitempty" validate:"dive"`
+	}
+
+	Entry struct {
+		Schedule string `json:"schedule" validate:"required"`
+		Action
+	}
+
+	Action struct {
+		Server  string           `json:"server,omitempty"`
+		Target  minecraft.Target `json:"target,omitempty" validate:"omitempty,oneof=start stop restart shutdown"`
+		Console string           `json:"console,omitempty"`
+		Command string           `json:"command,omitempty"`
+	}
+)
+
+const (
+	DefaultStateFile = "mcvisor_schedule.json"
+)
+
+var ErrInvalidAction = errors.New("exactly one of target, console or command must be set")
+
+func NewConfig(baseDir string) *Config {
+	return &Config{
+		TimeZone:  "Local",
+		StateFile: filepath.Join(filepath.Clean(baseDir), DefaultStateFile),
+	}
+}
+
+func (a Action) Validate() error {
+	count := 0
+	for _, isSet := range []bool{a.Target != "", a.Console != "", a.Command != ""} {
+		if isSet {
+			count++
+		}
+	}
+	if count != 1 {
+		return ErrInvalidAction
+	}
+	return nil
+}
+
+func (a Action) String() string {
+	switch {
+	case a.Target != "":
+		return "target " + string(a.Target) + a.serverSuffix()
+	case a.Console != "":
+		return "console " + a.Console + a.serverSuffix()
+	default:
+		return a.Command
+	}
+}
+
+// CommandLine returns the command to run. Target and console actions are sent to the given server, if any.
+func (a Action) CommandLine() string {
+	var words []string
+	switch {
+	case a.Target != "":
+		words = []string{string(a.Target), a.Server}
+	case a.Console != "":
+		words = []string{string(minecraft.ConsoleCommand), a.Server, a.Console}
+	default:
+		return a.Command
+	}
+	if a.Server == "" {
+		words = append(words[:1], words[2:]...)
+	}
+	return strings.Join(words, " ")
+}
+
+func (a Action) serverSuffix() string {
+	if a.Server == "" {
+		return ""
+	}
+	return " on " + a.Server
+}
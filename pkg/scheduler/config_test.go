@@ -0,0 +1,52 @@
+package scheduler_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Adirelle/mcvisor/pkg/minecraft"
+	"github.com/Adirelle/mcvisor/pkg/scheduler"
+)
+
+func TestAction(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		action      scheduler.Action
+		commandLine string
+		str         string
+	}{
+		{scheduler.Action{Target: minecraft.RestartTarget}, "restart", "target restart"},
+		{scheduler.Action{Target: minecraft.StopTarget, Server: "survival"}, "stop survival", "target stop on survival"},
+		{scheduler.Action{Console: "say hello world"}, "console say hello world", "console say hello world"},
+		{scheduler.Action{Console: "save-all", Server: "creative"}, "console creative save-all", "console save-all on creative"},
+		{scheduler.Action{Command: "backup"}, "backup", "backup"},
+	}
+	for _, test := range tests {
+		if err := test.action.Validate(); err != nil {
+			t.Errorf("%#v: unexpected error: %s", test.action, err)
+		}
+		if actual := test.action.CommandLine(); actual != test.commandLine {
+			t.Errorf("%#v: expected command line %q, got %q", test.action, test.commandLine, actual)
+		}
+		if actual := test.action.String(); actual != test.str {
+			t.Errorf("%#v: expected %q, got %q", test.action, test.str, actual)
+		}
+	}
+}
+
+func TestActionValidate(t *testing.T) {
+	t.Parallel()
+
+	for _, action := range []scheduler.Action{
+		{},
+		{Server: "survival"},
+		{Target: minecraft.StopTarget, Console: "say bye"},
+		{Console: "say bye", Command: "backup"},
+		{Target: minecraft.StartTarget, Console: "list", Command: "backup"},
+	} {
+		if err := action.Validate(); !errors.Is(err, scheduler.ErrInvalidAction) {
+			t.Errorf("%#v: expected ErrInvalidAction, got %v", action, err)
+		}
+	}
+}
@@ -0,0 +1,12 @@
+package scheduler
+
+import "github.com/Adirelle/mcvisor/pkg/commands"
+
+// Exported for testing
+var ParseTime = parseTime
+
+type Once = once
+
+func (s *Scheduler) HandleScheduleCommand(cmd *commands.Command) (string, error) {
+	return s.handleScheduleCommand(cmd)
+}
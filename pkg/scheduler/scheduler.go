@@ -0,0 +1,335 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Adirelle/mcvisor/pkg/commands"
+	"github.com/Adirelle/mcvisor/pkg/discord"
+	"github.com/Adirelle/mcvisor/pkg/events"
+	"github.com/apex/log"
+	"github.com/robfig/cron/v3"
+	"github.com/thejerf/suture/v4"
+)
+
+type (
+	Scheduler struct {
+		*Config
+		dispatcher *events.Dispatcher
+
+		mu        sync.Mutex
+		cron      *cron.Cron
+		location  *time.Location
+		recurring []cron.EntryID
+		oneOffs   map[uint]*OneOff
+		nextID    uint
+	}
+
+	OneOff struct {
+		ID      uint      `json:"id"`
+		At      time.Time `json:"at"`
+		Command string    `json:"command"`
+
+		entryID cron.EntryID
+	}
+
+	// once is a cron.Schedule that fires only one time.
+	once time.Time
+
+	ActionFailed struct {
+		Action
+		Reason error
+	}
+)
+
+const (
+	ScheduleCommand commands.Name = "schedule"
+)
+
+var (
+	// Interface checks
+	_ suture.Service       = (*Scheduler)(nil)
+	_ cron.Schedule        = once{}
+	_ discord.Notification = (*ActionFailed)(nil)
+
+	ErrNotRunning    = errors.New("scheduler is not running")
+	ErrScheduleUsage = errors.New("usage: `schedule list`, `schedule add <delay|HH:MM|YYYY-MM-DDTHH:MM> <command...>` or `schedule remove <id>`")
+	ErrUnknownJob    = errors.New("unknown scheduled command")
+	ErrInvalidTime   = errors.New("invalid time")
+	ErrPastTime      = errors.New("time is in the past")
+	ErrNotSaved      = errors.New("the schedule could not be saved, nothing changed")
+
+	timeLayouts = []string{"2006-01-02T15:04", "2006-01-02 15:04", time.RFC3339}
+)
+
+func NewScheduler(config *Config, dispatcher *events.Dispatcher) *Scheduler {
+	s := &Scheduler{
+		Config:     config,
+		dispatcher: dispatcher,
+		oneOffs:    make(map[uint]*OneOff),
+		nextID:     1,
+	}
+	commands.Register(ScheduleCommand, "list, add or remove scheduled commands", discord.AdminCategory, commands.HandlerFunc(s.handleScheduleCommand))
+	return s
+}
+
+func (s *Scheduler) Serve(ctx context.Context) error {
+	location, err := time.LoadLocation(s.TimeZone)
+	if err != nil {
+		log.WithError(err).WithField("timezone", s.TimeZone).Error("scheduler.config")
+		return err
+	}
+
+	c := cron.New(cron.WithLocation(location))
+	recurring := make([]cron.EntryID, 0, len(s.Entries))
+	for _, entry := range s.Entries {
+		action := entry.Action
+		if err = action.Validate(); err == nil {
+			var id cron.EntryID
+			id, err = c.AddFunc(entry.Schedule, func() { s.run(action) })
+			recurring = append(recurring, id)
+		}
+		if err != nil {
+			log.WithError(err).WithField("schedule", entry.Schedule).Error("scheduler.config")
+			return fmt.Errorf("invalid scheduler entry %q: %w", entry.Schedule, err)
+		}
+	}
+
+	s.mu.Lock()
+	s.cron, s.location, s.recurring = c, location, recurring
+	s.oneOffs = make(map[uint]*OneOff)
+	err = s.load()
+	s.mu.Unlock()
+	if err != nil {
+		log.WithError(err).WithField("path", s.StateFile).Warn("scheduler.load")
+	}
+
+	c.Start()
+	log.WithField("entries", len(recurring)).Debug("scheduler.started")
+	defer func() {
+		<-c.Stop().Done()
+		s.mu.Lock()
+		s.cron = nil
+		s.mu.Unlock()
+	}()
+
+	<-ctx.Done()
+	return nil
+}
+
+func (s *Scheduler) run(action Action) {
+	logger := log.WithField("action", action.String())
+
+	reply, err := commands.HandleCommandLine(action.CommandLine(), commands.System)
+	if err != nil {
+		logger.WithError(err).Warn("scheduler.run")
+		s.dispatcher.Dispatch(&ActionFailed{action, err})
+		return
+	}
+	logger.WithField("reply", reply).Info("scheduler.run")
+}
+
+func (s *Scheduler) handleScheduleCommand(cmd *commands.Command) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cron == nil {
+		return "", ErrNotRunning
+	}
+
+	args := cmd.Arguments
+	if len(args) == 0 || args[0] == "" {
+		args = []string{"list"}
+	}
+
+	switch {
+	case args[0] == "list":
+		return s.list(), nil
+	case args[0] == "add" && len(args) >= 3:
+		at, err := parseTime(args[1], time.Now().In(s.location))
+		if err != nil {
+			return "", err
+		}
+		oneOff := &OneOff{ID: s.nextID, At: at, Command: strings.Join(args[2:], " ")}
+		s.nextID++
+		s.add(oneOff)
+		if err = s.save(); err != nil {
+			s.remove(oneOff)
+			log.WithError(err).WithField("path", s.StateFile).Error("scheduler.save")
+			return "", fmt.Errorf("%w: %s", ErrNotSaved, err)
+		}
+		log.WithFields(cmd).WithField("id", oneOff.ID).WithField("at", at).Info("scheduler.add")
+		return fmt.Sprintf("Scheduled #%d `%s` <t:%d:R>", oneOff.ID, oneOff.Command, at.Unix()), nil
+	case args[0] == "remove" && len(args) == 2:
+		id, err := strconv.ParseUint(args[1], 10, 0)
+		if err != nil {
+			return "", ErrScheduleUsage
+		}
+		oneOff, found := s.oneOffs[uint(id)]
+		if !found {
+			return "", ErrUnknownJob
+		}
+		s.remove(oneOff)
+		if err = s.save(); err != nil {
+			s.add(oneOff)
+			log.WithError(err).WithField("path", s.StateFile).Error("scheduler.save")
+			return "", fmt.Errorf("%w: %s", ErrNotSaved, err)
+		}
+		log.WithFields(cmd).WithField("id", oneOff.ID).Info("scheduler.remove")
+		return fmt.Sprintf("Removed #%d `%s`", oneOff.ID, oneOff.Command), nil
+	default:
+		return "", ErrScheduleUsage
+	}
+}
+
+func (s *Scheduler) list() string {
+	builder := &strings.Builder{}
+	if len(s.recurring) > 0 {
+		_, _ = builder.WriteString("Recurring:")
+		for i, id := range s.recurring {
+			entry := s.cron.Entry(id)
+			_, _ = fmt.Fprintf(builder, "\n- `%s` %s (next <t:%d:R>)", s.Entries[i].Schedule, s.Entries[i].Action, entry.Next.Unix())
+		}
+	}
+	if len(s.oneOffs) > 0 {
+		if builder.Len() > 0 {
+			_, _ = builder.WriteString("\n")
+		}
+		_, _ = builder.WriteString("One-off:")
+		for _, oneOff := range s.sortedOneOffs() {
+			_, _ = fmt.Fprintf(builder, "\n- #%d `%s` <t:%d:f>", oneOff.ID, oneOff.Command, oneOff.At.Unix())
+		}
+	}
+	if builder.Len() == 0 {
+		return "Nothing scheduled"
+	}
+	return builder.String()
+}
+
+// add schedules a one-off command; s.mu must be held.
+func (s *Scheduler) add(oneOff *OneOff) {
+	oneOff.entryID = s.cron.Schedule(once(oneOff.At), cron.FuncJob(func() { s.runOneOff(oneOff.ID) }))
+	s.oneOffs[oneOff.ID] = oneOff
+	if oneOff.ID >= s.nextID {
+		s.nextID = oneOff.ID + 1
+	}
+}
+
+// remove unschedules a one-off command; s.mu must be held.
+func (s *Scheduler) remove(oneOff *OneOff) {
+	s.cron.Remove(oneOff.entryID)
+	delete(s.oneOffs, oneOff.ID)
+}
+
+func (s *Scheduler) runOneOff(id uint) {
+	s.mu.Lock()
+	oneOff, found := s.oneOffs[id]
+	if found {
+		s.remove(oneOff)
+		if err := s.save(); err != nil {
+			log.WithError(err).WithField("path", s.StateFile).Warn("scheduler.save")
+		}
+	}
+	s.mu.Unlock()
+
+	if found {
+		s.run(Action{Command: oneOff.Command})
+	}
+}
+
+func (s *Scheduler) sortedOneOffs() []*OneOff {
+	oneOffs := make([]*OneOff, 0, len(s.oneOffs))
+	for _, oneOff := range s.oneOffs {
+		oneOffs = append(oneOffs, oneOff)
+	}
+	sort.Slice(oneOffs, func(i, j int) bool { return oneOffs[i].At.Before(oneOffs[j].At) })
+	return oneOffs
+}
+
+// load restores the persisted one-off commands; s.mu must be held.
+func (s *Scheduler) load() error {
+	content, err := os.ReadFile(s.StateFile)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	var oneOffs []*OneOff
+	if err = json.Unmarshal(content, &oneOffs); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, oneOff := range oneOffs {
+		if oneOff.At.Before(now) {
+			log.WithField("id", oneOff.ID).WithField("at", oneOff.At).WithField("command", oneOff.Command).Warn("scheduler.missed")
+			continue
+		}
+		s.add(oneOff)
+	}
+	return nil
+}
+
+// save persists the one-off commands; s.mu must be held.
+func (s *Scheduler) save() error {
+	file, err := os.Create(s.StateFile)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	enc := json.NewEncoder(file)
+	enc.SetIndent("", "  ")
+	return enc.Encode(s.sortedOneOffs())
+}
+
+// parseTime accepts a delay (e.g. 30m), a time of day (e.g. 04:30) or a date and time.
+func parseTime(value string, now time.Time) (time.Time, error) {
+	if delay, err := time.ParseDuration(value); err == nil {
+		if delay <= 0 {
+			return time.Time{}, ErrPastTime
+		}
+		return now.Add(delay), nil
+	}
+
+	if clock, err := time.ParseInLocation("15:04", value, now.Location()); err == nil {
+		at := time.Date(now.Year(), now.Month(), now.Day(), clock.Hour(), clock.Minute(), 0, 0, now.Location())
+		if !at.After(now) {
+			at = at.AddDate(0, 0, 1)
+		}
+		return at, nil
+	}
+
+	for _, layout := range timeLayouts {
+		if at, err := time.ParseInLocation(layout, value, now.Location()); err == nil {
+			if !at.After(now) {
+				return time.Time{}, ErrPastTime
+			}
+			return at, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("%w: %q", ErrInvalidTime, value)
+}
+
+func (o once) Next(t time.Time) time.Time {
+	if t.Before(time.Time(o)) {
+		return time.Time(o)
+	}
+	return time.Time{}
+}
+
+func (e *ActionFailed) Fields() log.Fields {
+	return log.Fields{"action": e.Action.String(), "error": e.Reason}
+}
+
+func (e *ActionFailed) DiscordNotification() string {
+	return fmt.Sprintf("**Scheduled `%s` failed**: %s", e.Action, e.Reason)
+}
@@ -0,0 +1,157 @@
+package scheduler_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Adirelle/mcvisor/pkg/commands"
+	"github.com/Adirelle/mcvisor/pkg/events"
+	"github.com/Adirelle/mcvisor/pkg/scheduler"
+)
+
+func TestParseTime(t *testing.T) {
+	t.Parallel()
+
+	location := time.FixedZone("test", 2*60*60)
+	now := time.Date(2022, 6, 15, 10, 30, 0, 0, location)
+	tests := []struct {
+		value    string
+		expected time.Time
+		err      error
+	}{
+		{"30m", now.Add(30 * time.Minute), nil},
+		{"1h30m", now.Add(90 * time.Minute), nil},
+		{"0s", time.Time{}, scheduler.ErrPastTime},
+		{"-5m", time.Time{}, scheduler.ErrPastTime},
+		{"12:00", time.Date(2022, 6, 15, 12, 0, 0, 0, location), nil},
+		{"04:00", time.Date(2022, 6, 16, 4, 0, 0, 0, location), nil},
+		{"10:30", time.Date(2022, 6, 16, 10, 30, 0, 0, location), nil},
+		{"2022-06-20T08:15", time.Date(2022, 6, 20, 8, 15, 0, 0, location), nil},
+		{"2022-06-20 08:15", time.Date(2022, 6, 20, 8, 15, 0, 0, location), nil},
+		{"2022-06-20T08:15:00Z", time.Date(2022, 6, 20, 8, 15, 0, 0, time.UTC), nil},
+		{"2022-06-01T08:15", time.Time{}, scheduler.ErrPastTime},
+		{"tomorrow", time.Time{}, scheduler.ErrInvalidTime},
+		{"25:00", time.Time{}, scheduler.ErrInvalidTime},
+	}
+	for _, test := range tests {
+		actual, err := scheduler.ParseTime(test.value, now)
+		if !errors.Is(err, test.err) {
+			t.Errorf("%q: expected error %v, got %v", test.value, test.err, err)
+		} else if !actual.Equal(test.expected) {
+			t.Errorf("%q: expected %s, got %s", test.value, test.expected, actual)
+		}
+	}
+}
+
+func TestOnceNext(t *testing.T) {
+	t.Parallel()
+
+	at := time.Date(2022, 6, 15, 10, 30, 0, 0, time.UTC)
+	schedule := scheduler.Once(at)
+	if next := schedule.Next(at.Add(-time.Minute)); !next.Equal(at) {
+		t.Errorf("expected %s, got %s", at, next)
+	}
+	for _, now := range []time.Time{at, at.Add(time.Minute)} {
+		if next := schedule.Next(now); !next.IsZero() {
+			t.Errorf("expected no next time after %s, got %s", now, next)
+		}
+	}
+}
+
+// startScheduler runs a scheduler until the end of the test. The scheduler registers its command globally, so the
+// tests using it must not run in parallel.
+func startScheduler(t *testing.T, config *scheduler.Config) *scheduler.Scheduler {
+	t.Helper()
+	s := scheduler.NewScheduler(config, events.NewDispatcher())
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_ = s.Serve(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+
+	for deadline := time.Now().Add(5 * time.Second); ; {
+		if _, err := s.HandleScheduleCommand(commands.NewCommand("schedule list", commands.System)); !errors.Is(err, scheduler.ErrNotRunning) {
+			return s
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the scheduler did not start")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func readStateFile(t *testing.T, path string) (oneOffs []*scheduler.OneOff) {
+	t.Helper()
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = json.Unmarshal(content, &oneOffs); err != nil {
+		t.Fatal(err)
+	}
+	return
+}
+
+func TestSchedulerStateFile(t *testing.T) {
+	config := scheduler.NewConfig(t.TempDir())
+	now := time.Now().Truncate(time.Second)
+	state := []*scheduler.OneOff{
+		{ID: 3, At: now.Add(-time.Hour), Command: "missed"},
+		{ID: 7, At: now.Add(time.Hour), Command: "restart"},
+	}
+	content, _ := json.Marshal(state)
+	if err := os.WriteFile(config.StateFile, content, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	s := startScheduler(t, config)
+	reply, err := s.HandleScheduleCommand(commands.NewCommand("schedule list", commands.System))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(reply, "#7 `restart`") || strings.Contains(reply, "missed") {
+		t.Errorf("expected only the future command to be restored, got %q", reply)
+	}
+
+	reply, err = s.HandleScheduleCommand(commands.NewCommand("schedule add 2h console say hello", commands.System))
+	if err != nil || !strings.HasPrefix(reply, "Scheduled #8 `console say hello`") {
+		t.Fatalf("unexpected reply: %q, %v", reply, err)
+	}
+	oneOffs := readStateFile(t, config.StateFile)
+	if len(oneOffs) != 2 || oneOffs[0].ID != 7 || oneOffs[1].ID != 8 || oneOffs[1].Command != "console say hello" {
+		t.Errorf("unexpected state: %+v", oneOffs)
+	}
+
+	if _, err = s.HandleScheduleCommand(commands.NewCommand("schedule remove 7", commands.System)); err != nil {
+		t.Fatal(err)
+	}
+	if oneOffs = readStateFile(t, config.StateFile); len(oneOffs) != 1 || oneOffs[0].ID != 8 {
+		t.Errorf("unexpected state: %+v", oneOffs)
+	}
+	if _, err = s.HandleScheduleCommand(commands.NewCommand("schedule remove 7", commands.System)); !errors.Is(err, scheduler.ErrUnknownJob) {
+		t.Errorf("expected ErrUnknownJob, got %v", err)
+	}
+}
+
+func TestSchedulerSaveFailure(t *testing.T) {
+	config := scheduler.NewConfig(filepath.Join(t.TempDir(), "missing"))
+
+	s := startScheduler(t, config)
+	if _, err := s.HandleScheduleCommand(commands.NewCommand("schedule add 1h restart", commands.System)); !errors.Is(err, scheduler.ErrNotSaved) {
+		t.Errorf("expected ErrNotSaved, got %v", err)
+	}
+	if reply, _ := s.HandleScheduleCommand(commands.NewCommand("schedule list", commands.System)); reply != "Nothing scheduled" {
+		t.Errorf("expected the command to be rolled back, got %q", reply)
+	}
+}
@@ -0,0 +1,109 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+type (
+	// InstanceLock is an exclusive lock on a file, held until Release is called or the process exits.
+	InstanceLock struct {
+		Path   string
+		Holder LockHolder
+		// Stale describes the previous holder if it exited without releasing the lock, e.g. after a crash.
+		Stale *LockHolder
+		file  *os.File
+	}
+
+	// LockHolder identifies the process holding a lock. It is written in the lock file.
+	LockHolder struct {
+		PID     int       `json:"pid"`
+		Host    string    `json:"host"`
+		Started time.Time `json:"started"`
+	}
+
+	// LockedError is returned when the lock is held by another process.
+	LockedError struct {
+		Path string
+		// Holder is nil if the lock file could not be read.
+		Holder *LockHolder
+	}
+)
+
+// errLockHeld is returned by lockFile when another process holds the lock.
+var errLockHeld = errors.New("lock is held by another process")
+
+// AcquireLock takes the lock, failing immediately with a *LockedError if another process holds it.
+func AcquireLock(path string) (*InstanceLock, error) {
+	file, err := lockFile(path)
+	if errors.Is(err, errLockHeld) {
+		return nil, &LockedError{path, readLockHolder(path)}
+	} else if err != nil {
+		return nil, fmt.Errorf("could not lock %s: %w", path, err)
+	}
+
+	l := &InstanceLock{Path: path, file: file}
+	l.Holder.PID = os.Getpid()
+	l.Holder.Host, _ = os.Hostname()
+	l.Holder.Started = time.Now().Truncate(time.Second)
+
+	// The file is removed on release, so any content has been left by a process that did not release it.
+	if content, err := io.ReadAll(file); err == nil && len(content) > 0 {
+		l.Stale = &LockHolder{}
+		if json.Unmarshal(content, l.Stale) != nil {
+			l.Stale = &LockHolder{}
+		}
+	}
+
+	content, _ := json.Marshal(&l.Holder)
+	if err := l.write(content); err != nil {
+		_ = l.Release()
+		return nil, fmt.Errorf("could not write %s: %w", path, err)
+	}
+	return l, nil
+}
+
+func (l *InstanceLock) write(content []byte) error {
+	if err := l.file.Truncate(0); err != nil {
+		return err
+	}
+	if _, err := l.file.WriteAt(append(content, '\n'), 0); err != nil {
+		return err
+	}
+	return l.file.Sync()
+}
+
+// Release removes the lock file and releases the lock.
+func (l *InstanceLock) Release() error {
+	return releaseFile(l.file, l.Path)
+}
+
+func readLockHolder(path string) *LockHolder {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	holder := &LockHolder{}
+	if json.Unmarshal(content, holder) != nil {
+		return nil
+	}
+	return holder
+}
+
+func (h LockHolder) String() string {
+	if h.PID == 0 {
+		return "unknown process"
+	}
+	return fmt.Sprintf("PID %d on %s, started at %s", h.PID, h.Host, h.Started.Format(time.RFC3339))
+}
+
+func (e *LockedError) Error() string {
+	if e.Holder == nil {
+		return fmt.Sprintf("%s is locked by another process", e.Path)
+	}
+	return fmt.Sprintf("%s is locked by another process (%s)", e.Path, e.Holder)
+}
@@ -0,0 +1,63 @@
+package utils_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Adirelle/mcvisor/pkg/utils"
+)
+
+func TestAcquireLock(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "test.lock")
+	lock, err := utils.AcquireLock(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lock.Stale != nil {
+		t.Errorf("unexpected stale lock: %v", lock.Stale)
+	}
+
+	_, err = utils.AcquireLock(path)
+	var locked *utils.LockedError
+	if !errors.As(err, &locked) {
+		t.Fatalf("expected a LockedError, got %v", err)
+	}
+	if locked.Holder == nil || locked.Holder.PID != os.Getpid() {
+		t.Errorf("expected the holder to be %d, got %v", os.Getpid(), locked.Holder)
+	}
+
+	if err := lock.Release(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected the lock file to be removed, got %v", err)
+	}
+	lock, err = utils.AcquireLock(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = lock.Release()
+}
+
+func TestAcquireStaleLock(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "test.lock")
+	content := `{"pid":12345,"host":"elsewhere","started":"2022-05-01T10:00:00Z"}`
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	lock, err := utils.AcquireLock(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lock.Release()
+	if lock.Stale == nil || lock.Stale.PID != 12345 || lock.Stale.Host != "elsewhere" {
+		t.Errorf("expected the stale holder to be detected, got %v", lock.Stale)
+	}
+}
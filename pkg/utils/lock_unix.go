@@ -0,0 +1,44 @@
+//go:build !windows
+
+package utils
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+func lockFile(path string) (*os.File, error) {
+	for {
+		file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+		if err != nil {
+			return nil, err
+		}
+		if err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+			_ = file.Close()
+			if errors.Is(err, syscall.EWOULDBLOCK) {
+				return nil, errLockHeld
+			}
+			return nil, err
+		}
+		// The previous holder may have removed the file between the opening and the locking: try again then.
+		locked, err := file.Stat()
+		if err != nil {
+			_ = file.Close()
+			return nil, err
+		}
+		if current, err := os.Stat(path); err == nil && os.SameFile(locked, current) {
+			return file, nil
+		}
+		_ = file.Close()
+	}
+}
+
+// releaseFile removes the file before unlocking it, so that no other process can lock the removed file.
+func releaseFile(file *os.File, path string) error {
+	err := os.Remove(path)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}